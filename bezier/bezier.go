@@ -1063,3 +1063,64 @@ func lli4(p1, p2, p3, p4 Point) (success bool, point Point) {
 	y4 := p4.Y
 	return lli8(x1, y1, x2, y2, x3, y3, x4, y4)
 }
+
+// the number of Legendre-Gauss nodes used to integrate the arc length
+const lengthOrder = 24
+
+// the Legendre-Gauss nodes (on -1..1) and weights
+var lengthNodes, lengthWeights = legendreGauss(lengthOrder)
+
+// computes the roots of the legendre polynomial of order n, and the
+// quadrature weights, with newton's method
+func legendreGauss(n int) ([]float64, []float64) {
+	nodes := make([]float64, n)
+	weights := make([]float64, n)
+	for i := 0; i < n; i++ {
+		x := math.Cos(math.Pi * (float64(i) + .75) / (float64(n) + .5))
+		dp := 0.0
+		for iter := 0; iter < 100; iter++ {
+			// evaluate the polynomial and its derivative at x
+			p0, p1 := 1.0, x
+			for k := 2; k <= n; k++ {
+				p0, p1 = p1, ((2*float64(k)-1)*x*p1-(float64(k)-1)*p0)/float64(k)
+			}
+			dp = float64(n) * (x*p1 - p0) / (x*x - 1)
+			dx := p1 / dp
+			x -= dx
+			if math.Abs(dx) < 1e-15 {
+				break
+			}
+		}
+		nodes[i] = x
+		weights[i] = 2 / ((1 - x*x) * dp * dp)
+	}
+	return nodes, weights
+}
+
+// the arc length of the curve, by Legendre-Gauss quadrature of the
+// derivative
+func gaussLength(curve CubicCurve) float64 {
+	length := 0.0
+	for i, x := range lengthNodes {
+		d := Derivative(curve, (x+1)/2)
+		length += lengthWeights[i] * math.Sqrt(d.X*d.X+d.Y*d.Y)
+	}
+	return length / 2
+}
+
+// The arc length of the curve.  The curve is split in half until the
+// length of the halves matches the length of the whole, so sharp
+// bends and cusps are measured accurately
+func Length(curve CubicCurve) float64 {
+	return adaptiveLength(curve, gaussLength(curve), 0)
+}
+
+func adaptiveLength(curve CubicCurve, whole float64, depth int) float64 {
+	left, right := SplitCurve(curve, .5)
+	l := gaussLength(left)
+	r := gaussLength(right)
+	if depth >= 12 || math.Abs(l+r-whole) <= 1e-12*math.Max(1, whole) {
+		return l + r
+	}
+	return adaptiveLength(left, l, depth+1) + adaptiveLength(right, r, depth+1)
+}
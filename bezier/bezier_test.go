@@ -282,3 +282,44 @@ func TestOffset(t *testing.T) {
 		t.Errorf("Expected %f but got %f", 149.7214569927344314, cubicCurveToArray(curves[6])[3].X)
 	}
 }
+
+func TestLength(t *testing.T) {
+	// a straight line with evenly spaced control points
+	line := CubicCurve{
+		Start:        NewPoint(0, 0),
+		StartControl: NewPoint(1, 1),
+		EndControl:   NewPoint(2, 2),
+		End:          NewPoint(3, 3),
+	}
+	if math.Abs(Length(line)-3*math.Sqrt2) > 1e-12 {
+		t.Errorf("Expected %f got %f", 3*math.Sqrt2, Length(line))
+	}
+	// a quarter circle approximation, radius 1
+	k := 0.5522847498
+	arc := CubicCurve{
+		Start:        NewPoint(1, 0),
+		StartControl: NewPoint(1, k),
+		EndControl:   NewPoint(k, 1),
+		End:          NewPoint(0, 1),
+	}
+	if math.Abs(Length(arc)-math.Pi/2) > 1e-3 {
+		t.Errorf("Expected about %f got %f", math.Pi/2, Length(arc))
+	}
+	// a cusp, the curve goes out and comes back
+	cusp := CubicCurve{
+		Start:        NewPoint(0, 0),
+		StartControl: NewPoint(2, 0),
+		EndControl:   NewPoint(-1, 0),
+		End:          NewPoint(1, 0),
+	}
+	flat := 0.0
+	prev := FindPoint(cusp, 0)
+	for i := 1; i <= 100000; i++ {
+		p := FindPoint(cusp, float64(i)/100000)
+		flat += math.Hypot(p.X-prev.X, p.Y-prev.Y)
+		prev = p
+	}
+	if math.Abs(Length(cusp)-flat) > 1e-6 {
+		t.Errorf("Expected %f got %f", flat, Length(cusp))
+	}
+}
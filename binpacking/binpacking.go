@@ -2,6 +2,7 @@ package binpacking
 
 import (
 	"math"
+	"sort"
 )
 
 type BinBoundary interface {
@@ -24,7 +25,7 @@ type Bin struct {
 }
 
 type Container struct {
-	Root   *Bin
+	Packer Packer
 	Width  float64
 	Height float64
 	X      float64
@@ -45,9 +46,21 @@ func (p *paddedPackable) GetHeight() float64 {
 	return (p.padding * 2) + p.boundary.GetHeight()
 }
 
+func (p *paddedPackable) AllowedRotation() Rotation {
+	if c, ok := p.boundary.(RotationConstrained); ok {
+		return c.AllowedRotation()
+	}
+	return RotateFree
+}
+
+// creates a new container that uses the Guillotine strategy
 func NewContainer(x float64, y float64, width float64, height float64) *Container {
+	return NewStrategyContainer(Guillotine, x, y, width, height)
+}
+
+func NewStrategyContainer(strategy Strategy, x float64, y float64, width float64, height float64) *Container {
 	return &Container{
-		Root:   NewBin(x, y, width, height, false),
+		Packer: NewPacker(strategy, x, y, width, height),
 		Width:  width,
 		Height: height,
 		X:      x,
@@ -58,35 +71,75 @@ func NewContainer(x float64, y float64, width float64, height float64) *Containe
 // creates a new container with a single element.  It will not be possible to add
 // to this container.
 func NewSingleObjectContainer(obj interface{}, x float64, y float64, width float64, height float64) *Container {
-	container := NewContainer(x, y, width, height)
-	container.Root.HasObject = true
-	container.Root.Object = obj
-	return container
+	root := NewBin(x, y, width, height, false)
+	root.HasObject = true
+	root.Object = obj
+	return &Container{
+		Packer: root,
+		Width:  width,
+		Height: height,
+		X:      x,
+		Y:      y,
+	}
 }
 
 // finds all the empty bins, useful for finding how
-// much space is left over
+// much space is left over.  Depending on the strategy
+// these may overlap
 func (c *Container) GetEmptyBins() []*Bin {
-	return c.Root.getEmpties([]*Bin{})
+	return c.Packer.FreeBins()
+}
+
+// the empty bins that do not overlap, largest first.  When free
+// bins overlap only the largest is kept.  Bins smaller than minSize in
+// either direction are skipped.  These are the offcuts left after cutting
+func (c *Container) GetOffcuts(minSize float64) []*Bin {
+	return DisjointBins(c.GetEmptyBins(), minSize)
+}
+
+// the bins that do not overlap, largest first.  Bins smaller than minSize
+// in either direction are skipped.
+func DisjointBins(bins []*Bin, minSize float64) []*Bin {
+	sorted := append([]*Bin{}, bins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Width*sorted[i].Height > sorted[j].Width*sorted[j].Height
+	})
+	disjoint := []*Bin{}
+	for _, b := range sorted {
+		if b.Width < minSize || b.Height < minSize || b.Width <= 0 || b.Height <= 0 {
+			continue
+		}
+		overlaps := false
+		for _, d := range disjoint {
+			if intersects(b, d) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			disjoint = append(disjoint, b)
+		}
+	}
+	return disjoint
 }
 
 // returns the total area in unit^2 of emptyness
 func (c *Container) GetEmptyArea() float64 {
-	area := 0.0
-	for _, b := range c.GetEmptyBins() {
-		area += b.Width * b.Height
+	area := c.Width * c.Height
+	for _, b := range c.Packer.UsedBins() {
+		area -= b.Width * b.Height
 	}
 	return area
 }
 
 func (c *Container) IsEmpty() bool {
-	return !c.Root.HasChildren && !c.Root.HasObject
+	return len(c.Packer.UsedBins()) == 0
 }
 
 // Inserts the packable into the container and returns the bin it was placed in
 // if possible
 func (c *Container) Insert(object interface{}, boundary BinBoundary) (bool, Bin) {
-	inserted, bin := c.Root.Insert(object, boundary)
+	inserted, bin := c.Packer.Insert(object, boundary)
 	if !inserted {
 		bin = &Bin{}
 	}
@@ -94,6 +147,20 @@ func (c *Container) Insert(object interface{}, boundary BinBoundary) (bool, Bin)
 	return inserted, *bin
 }
 
+// reserves the area of the container, the area is clipped to the container.
+// returns false if it is completely outside
+func (c *Container) Reserve(object interface{}, x, y, width, height float64) bool {
+	x0 := math.Max(x, c.X)
+	y0 := math.Max(y, c.Y)
+	x1 := math.Min(x+width, c.X+c.Width)
+	y1 := math.Min(y+height, c.Y+c.Height)
+	if x1 <= x0 || y1 <= y0 {
+		return false
+	}
+	c.Packer.Reserve(object, x0, y0, x1-x0, y1-y0)
+	return true
+}
+
 // inserts into the container with the specified padding on all sides
 func (c *Container) InsertWithPadding(object interface{}, boundary BinBoundary, padding float64) (bool, Bin) {
 
@@ -127,6 +194,27 @@ func NewBin(x float64, y float64, width float64, height float64, rotated bool) *
 	}
 }
 
+// the empty bins in this tree
+func (b *Bin) FreeBins() []*Bin {
+	return b.getEmpties([]*Bin{})
+}
+
+// the bins in this tree that contain an object
+func (b *Bin) UsedBins() []*Bin {
+	return b.getUsed([]*Bin{})
+}
+
+func (b *Bin) getUsed(collect []*Bin) []*Bin {
+	if b.HasObject {
+		return append(collect, b)
+	}
+	if b.HasChildren {
+		collect = b.LeftChild.getUsed(collect)
+		collect = b.RightChild.getUsed(collect)
+	}
+	return collect
+}
+
 func (b *Bin) getEmpties(collect []*Bin) []*Bin {
 	if b.HasObject {
 		return collect
@@ -141,6 +229,50 @@ func (b *Bin) getEmpties(collect []*Bin) []*Bin {
 	return collect
 }
 
+// splits the free bins in this tree around the area, the part of each
+// free bin inside the area is filled with the object
+func (b *Bin) Reserve(object interface{}, x, y, width, height float64) {
+	area := NewBin(x, y, width, height, false)
+	for _, free := range b.FreeBins() {
+		if intersects(free, area) {
+			free.reserve(object, area)
+		}
+	}
+}
+
+func (b *Bin) reserve(object interface{}, area *Bin) {
+	split := func(left, right *Bin) {
+		b.HasChildren = true
+		b.LeftChild = left
+		b.RightChild = right
+	}
+	if area.X > b.X {
+		// split on vertical axis, the area is on the right
+		split(NewBin(b.X, b.Y, area.X-b.X, b.Height, false),
+			NewBin(area.X, b.Y, b.X+b.Width-area.X, b.Height, false))
+		b.RightChild.reserve(object, area)
+	} else if area.X+area.Width < b.X+b.Width {
+		// split on vertical axis, the area is on the left
+		split(NewBin(b.X, b.Y, area.X+area.Width-b.X, b.Height, false),
+			NewBin(area.X+area.Width, b.Y, b.X+b.Width-area.X-area.Width, b.Height, false))
+		b.LeftChild.reserve(object, area)
+	} else if area.Y > b.Y {
+		// split on horizontal axis, the area is on the bottom
+		split(NewBin(b.X, b.Y, b.Width, area.Y-b.Y, false),
+			NewBin(b.X, area.Y, b.Width, b.Y+b.Height-area.Y, false))
+		b.RightChild.reserve(object, area)
+	} else if area.Y+area.Height < b.Y+b.Height {
+		// split on horizontal axis, the area is on the top
+		split(NewBin(b.X, b.Y, b.Width, area.Y+area.Height-b.Y, false),
+			NewBin(b.X, area.Y+area.Height, b.Width, b.Y+b.Height-area.Y-area.Height, false))
+		b.LeftChild.reserve(object, area)
+	} else {
+		// the bin is inside the area
+		b.Object = object
+		b.HasObject = true
+	}
+}
+
 // is this split on the horizontal axis: i.e. left means top, right means bottom
 func (b *Bin) IsHorizontalSplit() bool {
 	if !b.HasChildren {
@@ -175,15 +307,22 @@ func (b *Bin) Insert(object interface{}, boundary BinBoundary) (bool, *Bin) {
 
 	// this node doesnt have any children
 
-	if b.Height < height || b.Width < width {
-		// too small, try to rotate
-		rotated = true
-		width = boundary.GetHeight()
-		height = boundary.GetWidth()
-		if b.Height < height || b.Width < width {
-			// still too small, return
-			return false, nil
+	// try unrotated first, then rotated if it is too small
+	fits := false
+	for _, rotated = range allowedRotations(boundary) {
+		width = boundary.GetWidth()
+		height = boundary.GetHeight()
+		if rotated {
+			width, height = height, width
 		}
+		if b.Height >= height && b.Width >= width {
+			fits = true
+			break
+		}
+	}
+	if !fits {
+		// too small, return
+		return false, nil
 	}
 
 	if b.HasObject {
@@ -19,3 +19,177 @@ func (m MockBin) GetHeight() float64 {
 func TestBinPacking(t *testing.T) {
 	
 }
+
+func testBoxes() []MockBin {
+	return []MockBin{
+		{4, 3}, {4, 3}, {2, 5}, {6, 1}, {3, 3}, {1, 1}, {2, 2}, {5, 2},
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	for name, strategy := range strategyNames {
+		c := NewStrategyContainer(strategy, 1, 1, 10, 8)
+		placed := []Bin{}
+		usedArea := 0.0
+		for i, b := range testBoxes() {
+			inserted, bin := c.Insert(i, b)
+			if !inserted {
+				continue
+			}
+			if bin.Width*bin.Height != b.Width*b.Height {
+				t.Errorf("%s: bin %+v does not match %+v", name, bin, b)
+			}
+			if bin.X < 1 || bin.Y < 1 || bin.X+bin.Width > 11 || bin.Y+bin.Height > 9 {
+				t.Errorf("%s: bin %+v is outside the container", name, bin)
+			}
+			for _, p := range placed {
+				if intersects(&p, &bin) {
+					t.Errorf("%s: bin %+v overlaps %+v", name, bin, p)
+				}
+			}
+			placed = append(placed, bin)
+			usedArea += bin.Width * bin.Height
+		}
+		if len(placed) == 0 {
+			t.Errorf("%s: nothing was placed", name)
+		}
+		if c.GetEmptyArea() != 80-usedArea {
+			t.Errorf("%s: expected empty area %.2f got %.2f", name, 80-usedArea, c.GetEmptyArea())
+		}
+	}
+}
+
+func TestMaxRectsFillsSheet(t *testing.T) {
+	boxes := []MockBin{{2, 3}, {3, 2}, {3, 1}}
+	insertAll := func(strategy Strategy) bool {
+		c := NewStrategyContainer(strategy, 0, 0, 4, 4)
+		for i, b := range boxes {
+			inserted, _ := c.Insert(i, b)
+			if !inserted {
+				return false
+			}
+		}
+		return true
+	}
+	// the guillotine tree can not place the last part, since the
+	// first split is permanent
+	if insertAll(Guillotine) {
+		t.Errorf("Expected guillotine to run out of space")
+	}
+	for _, strategy := range []Strategy{MaxRectsBestShortSideFit, MaxRectsBestAreaFit, MaxRectsBottomLeft, Skyline} {
+		if !insertAll(strategy) {
+			t.Errorf("%s: unable to insert all the boxes", strategy)
+		}
+	}
+}
+
+func TestStrategyNames(t *testing.T) {
+	if MustStrategy("maxrects_baf", Guillotine) != MaxRectsBestAreaFit {
+		t.Errorf("Expected maxrects_baf")
+	}
+	if MustStrategy("nope", Skyline) != Skyline {
+		t.Errorf("Expected default strategy")
+	}
+}
+
+func TestDisjointBins(t *testing.T) {
+	bins := []*Bin{
+		NewBin(0, 0, 2, 4, false),
+		NewBin(0, 0, 4, 3, false),
+		NewBin(3, 3, 1, 1, false),
+		NewBin(0, 3, 4, 0.1, false),
+	}
+	disjoint := DisjointBins(bins, 0.5)
+	if len(disjoint) != 2 {
+		t.Fatalf("Expected 2 offcuts, got %d", len(disjoint))
+	}
+	if disjoint[0].Width != 4 || disjoint[0].Height != 3 {
+		t.Errorf("Expected the largest bin first, got %fx%f", disjoint[0].Width, disjoint[0].Height)
+	}
+	if disjoint[1].X != 3 || disjoint[1].Y != 3 {
+		t.Errorf("Expected the bin at 3,3, got %f,%f", disjoint[1].X, disjoint[1].Y)
+	}
+}
+
+// a bin that may only be packed with the given rotation
+type constrainedBin struct {
+	MockBin
+	rotation Rotation
+}
+
+func (c constrainedBin) AllowedRotation() Rotation {
+	return c.rotation
+}
+
+func TestRotationConstraints(t *testing.T) {
+	for name, strategy := range strategyNames {
+		// only fits the sheet rotated
+		tall := MockBin{2, 6}
+		c := NewStrategyContainer(strategy, 0, 0, 6, 4)
+		if inserted, _ := c.InsertWithPadding(0, constrainedBin{tall, RotateNone}, 0); inserted {
+			t.Errorf("%s: expected the unrotatable bin not to fit", name)
+		}
+		inserted, bin := c.InsertWithPadding(0, constrainedBin{tall, RotateFree}, 0)
+		if !inserted || !bin.Rotated {
+			t.Errorf("%s: expected the bin to be rotated %+v", name, bin)
+		}
+
+		// fits either way, but must be rotated
+		c = NewStrategyContainer(strategy, 0, 0, 6, 6)
+		inserted, bin = c.Insert(0, constrainedBin{MockBin{2, 3}, Rotate90})
+		if !inserted || !bin.Rotated || bin.Width != 3 || bin.Height != 2 {
+			t.Errorf("%s: expected a rotated bin, got %+v", name, bin)
+		}
+	}
+	if MustRotation("90", RotateFree) != Rotate90 || MustRotation("sideways", RotateNone) != RotateNone {
+		t.Errorf("Unexpected rotation names")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	for name, strategy := range strategyNames {
+		c := NewStrategyContainer(strategy, 0, 0, 10, 10)
+		reserved := []*Bin{NewBin(0, 0, 10, 2, false), NewBin(6, 6, 4, 4, false)}
+		for _, r := range reserved {
+			c.Reserve(r, r.X, r.Y, r.Width, r.Height)
+		}
+		if c.Reserve(0, 11, 0, 2, 2) {
+			t.Errorf("%s: expected an area outside the container not to be reserved", name)
+		}
+		placed := 0
+		for i := 0; i < 20; i++ {
+			inserted, bin := c.Insert(i, MockBin{3, 3})
+			if !inserted {
+				break
+			}
+			placed++
+			for _, r := range reserved {
+				if intersects(&bin, r) {
+					t.Errorf("%s: bin %+v overlaps the reserved area %+v", name, bin, r)
+				}
+			}
+		}
+		if placed < 4 {
+			t.Errorf("%s: expected at least 4 bins around the reserved areas, got %d", name, placed)
+		}
+	}
+}
+
+func TestOffcutsExcludeReserved(t *testing.T) {
+	for name, strategy := range strategyNames {
+		c := NewStrategyContainer(strategy, 0, 0, 10, 10)
+		keepOut := NewBin(0, 4, 6, 2, false)
+		c.Reserve(keepOut, keepOut.X, keepOut.Y, keepOut.Width, keepOut.Height)
+		c.Insert(0, MockBin{3, 3})
+		area := 0.0
+		for _, b := range c.GetOffcuts(0) {
+			area += b.Width * b.Height
+			if intersects(b, keepOut) {
+				t.Errorf("%s: offcut %+v overlaps the keep out area", name, b)
+			}
+		}
+		if area == 0 {
+			t.Errorf("%s: expected offcuts around the keep out area", name)
+		}
+	}
+}
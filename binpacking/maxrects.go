@@ -0,0 +1,142 @@
+package binpacking
+
+import "math"
+
+// MaxRects packer.
+// Keeps a list of the maximal free rectangles, which may overlap.  Each
+// placement is chosen from all the free rectangles in both orientations,
+// then every free rectangle it intersects is split.  Unlike the guillotine
+// tree no split is permanent, so the result is much less sensitive to
+// insertion order.
+// see Jukka Jylänki, A Thousand Ways to Pack the Bin
+type MaxRectsPacker struct {
+	strategy Strategy
+	free     []*Bin
+	used     []*Bin
+}
+
+func NewMaxRectsPacker(strategy Strategy, x float64, y float64, width float64, height float64) *MaxRectsPacker {
+	return &MaxRectsPacker{
+		strategy: strategy,
+		free:     []*Bin{NewBin(x, y, width, height, false)},
+		used:     []*Bin{},
+	}
+}
+
+func (m *MaxRectsPacker) UsedBins() []*Bin {
+	return m.used
+}
+
+func (m *MaxRectsPacker) FreeBins() []*Bin {
+	return m.free
+}
+
+// scores placing a width x height rect in the free rect, lower is better
+func (m *MaxRectsPacker) score(free *Bin, width, height float64) (float64, float64) {
+	leftoverW := free.Width - width
+	leftoverH := free.Height - height
+	shortSide := math.Min(leftoverW, leftoverH)
+	longSide := math.Max(leftoverW, leftoverH)
+	switch m.strategy {
+	case MaxRectsBestAreaFit:
+		return free.Width*free.Height - width*height, shortSide
+	case MaxRectsBottomLeft:
+		return free.Y + height, free.X
+	}
+	return shortSide, longSide
+}
+
+func (m *MaxRectsPacker) Insert(object interface{}, boundary BinBoundary) (bool, *Bin) {
+	if invalidBoundary(boundary) {
+		return false, nil
+	}
+	var best *Bin
+	bestScore1 := math.Inf(1)
+	bestScore2 := math.Inf(1)
+	for _, free := range m.free {
+		for _, rotated := range allowedRotations(boundary) {
+			width := boundary.GetWidth()
+			height := boundary.GetHeight()
+			if rotated {
+				width, height = height, width
+			}
+			if free.Width < width || free.Height < height {
+				continue
+			}
+			s1, s2 := m.score(free, width, height)
+			if s1 < bestScore1 || (s1 == bestScore1 && s2 < bestScore2) {
+				bestScore1 = s1
+				bestScore2 = s2
+				best = placedBin(object, free.X, free.Y, width, height, rotated)
+			}
+		}
+	}
+	if best == nil {
+		return false, nil
+	}
+	m.place(best)
+	return true, best
+}
+
+func (m *MaxRectsPacker) Reserve(object interface{}, x, y, width, height float64) {
+	m.place(placedBin(object, x, y, width, height, false))
+}
+
+// splits the free rectangles around the newly used bin
+func (m *MaxRectsPacker) place(used *Bin) {
+	m.free = splitFree(m.free, used)
+	m.used = append(m.used, used)
+}
+
+// splits the free rectangles around the used bin, returns the maximal
+// free rectangles that do not overlap it
+func splitFree(rects []*Bin, used *Bin) []*Bin {
+	free := []*Bin{}
+	for _, f := range rects {
+		if !intersects(f, used) {
+			free = append(free, f)
+			continue
+		}
+		// the maximal rectangles on each side of used
+		if used.X > f.X {
+			free = append(free, NewBin(f.X, f.Y, used.X-f.X, f.Height, false))
+		}
+		if used.X+used.Width < f.X+f.Width {
+			free = append(free, NewBin(used.X+used.Width, f.Y, f.X+f.Width-used.X-used.Width, f.Height, false))
+		}
+		if used.Y > f.Y {
+			free = append(free, NewBin(f.X, f.Y, f.Width, used.Y-f.Y, false))
+		}
+		if used.Y+used.Height < f.Y+f.Height {
+			free = append(free, NewBin(f.X, used.Y+used.Height, f.Width, f.Y+f.Height-used.Y-used.Height, false))
+		}
+	}
+
+	// remove any free rectangles contained in another
+	ret := []*Bin{}
+	for i, a := range free {
+		contained := false
+		for j, b := range free {
+			if i != j && contains(b, a) && (!contains(a, b) || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
+
+func intersects(a, b *Bin) bool {
+	return a.X < b.X+b.Width && b.X < a.X+a.Width &&
+		a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
+
+// true if a contains b
+func contains(a, b *Bin) bool {
+	return b.X >= a.X && b.Y >= a.Y &&
+		b.X+b.Width <= a.X+a.Width &&
+		b.Y+b.Height <= a.Y+a.Height
+}
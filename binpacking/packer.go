@@ -0,0 +1,154 @@
+package binpacking
+
+import "math"
+
+// A packing algorithm.  Places boundaries within a fixed area and keeps
+// track of the used and free space.
+type Packer interface {
+	// finds a place for the boundary, the returned bin has the position,
+	// and the width and height after any rotation.
+	// returns (true, bin) or (false, nil)
+	Insert(object interface{}, boundary BinBoundary) (bool, *Bin)
+	// the bins that contain an object
+	UsedBins() []*Bin
+	// the empty areas, depending on the algorithm these may overlap
+	FreeBins() []*Bin
+	// marks the area as used by the object, nothing else is placed over
+	// it.  Used for parts at a fixed position and areas to keep out of
+	Reserve(object interface{}, x, y, width, height float64)
+}
+
+// Which packing algorithm to use
+type Strategy int32
+
+const (
+	// the original guillotine tree, each placement permanently
+	// splits the free space in two.
+	// see http://blackpawn.com/texts/lightmaps/
+	Guillotine Strategy = 0
+	// MaxRects, place where the shorter leftover side is smallest
+	MaxRectsBestShortSideFit Strategy = 1
+	// MaxRects, place in the free rectangle with the smallest area
+	MaxRectsBestAreaFit Strategy = 2
+	// MaxRects, place as close to the top left as possible
+	MaxRectsBottomLeft Strategy = 3
+	// Skyline, keeps the top edge of the packed parts and places
+	// each part as low as possible on it
+	Skyline Strategy = 4
+)
+
+var strategyNames = map[string]Strategy{
+	"guillotine":    Guillotine,
+	"maxrects_bssf": MaxRectsBestShortSideFit,
+	"maxrects_baf":  MaxRectsBestAreaFit,
+	"maxrects_bl":   MaxRectsBottomLeft,
+	"skyline":       Skyline,
+}
+
+func NewStrategy(name string) (Strategy, bool) {
+	s, ok := strategyNames[name]
+	return s, ok
+}
+
+func MustStrategy(name string, defaultStrategy Strategy) Strategy {
+	s, ok := NewStrategy(name)
+	if !ok {
+		return defaultStrategy
+	}
+	return s
+}
+
+func (s Strategy) String() string {
+	for name, strategy := range strategyNames {
+		if strategy == s {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// How a boundary may be rotated when it is packed
+type Rotation int32
+
+const (
+	// either way, whichever fits best
+	RotateFree Rotation = 0
+	// never rotated
+	RotateNone Rotation = 1
+	// always rotated by 90deg
+	Rotate90 Rotation = 2
+)
+
+var rotationNames = map[string]Rotation{
+	"free": RotateFree,
+	"none": RotateNone,
+	"90":   Rotate90,
+}
+
+func NewRotation(name string) (Rotation, bool) {
+	r, ok := rotationNames[name]
+	return r, ok
+}
+
+func MustRotation(name string, defaultRotation Rotation) Rotation {
+	r, ok := NewRotation(name)
+	if !ok {
+		return defaultRotation
+	}
+	return r
+}
+
+func (r Rotation) String() string {
+	for name, rotation := range rotationNames {
+		if rotation == r {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// Boundaries that implement this are only packed with the allowed rotation,
+// all others are RotateFree
+type RotationConstrained interface {
+	AllowedRotation() Rotation
+}
+
+// the rotations to try for the boundary, unrotated first
+func allowedRotations(boundary BinBoundary) []bool {
+	if c, ok := boundary.(RotationConstrained); ok {
+		switch c.AllowedRotation() {
+		case RotateNone:
+			return []bool{false}
+		case Rotate90:
+			return []bool{true}
+		}
+	}
+	return []bool{false, true}
+}
+
+// creates an empty packer for the area
+func NewPacker(strategy Strategy, x float64, y float64, width float64, height float64) Packer {
+	switch strategy {
+	case MaxRectsBestShortSideFit, MaxRectsBestAreaFit, MaxRectsBottomLeft:
+		return NewMaxRectsPacker(strategy, x, y, width, height)
+	case Skyline:
+		return NewSkylinePacker(x, y, width, height)
+	}
+	return NewBin(x, y, width, height, false)
+}
+
+// true if the boundary can not be packed
+func invalidBoundary(boundary BinBoundary) bool {
+	width := boundary.GetWidth()
+	height := boundary.GetHeight()
+	return width <= 0 || height <= 0 ||
+		math.IsNaN(width) || math.IsNaN(height)
+}
+
+// creates the bin for a placed object
+func placedBin(object interface{}, x, y, width, height float64, rotated bool) *Bin {
+	b := NewBin(x, y, width, height, rotated)
+	b.HasObject = true
+	b.Object = object
+	return b
+}
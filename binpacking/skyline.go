@@ -0,0 +1,171 @@
+package binpacking
+
+import "math"
+
+// Skyline packer.
+// Tracks the top edge of the packed area as a list of horizontal
+// segments, each part is placed at the lowest position on the skyline
+// (bottom left), ties go to the leftmost.  Space trapped below the
+// skyline is never reused, but packing is fast and tends to leave a
+// single large offcut.  Reserved areas are obstacles, parts that would
+// overlap one are moved below it.
+type SkylinePacker struct {
+	x      float64
+	y      float64
+	width  float64
+	height float64
+	// segments ordered by x, always covering the full width
+	skyline []skylineSegment
+	used    []*Bin
+	// the reserved areas
+	reserved []*Bin
+}
+
+type skylineSegment struct {
+	x     float64
+	y     float64
+	width float64
+}
+
+func NewSkylinePacker(x float64, y float64, width float64, height float64) *SkylinePacker {
+	return &SkylinePacker{
+		x:       x,
+		y:       y,
+		width:   width,
+		height:  height,
+		skyline: []skylineSegment{{x: x, y: y, width: width}},
+		used:    []*Bin{},
+	}
+}
+
+func (s *SkylinePacker) UsedBins() []*Bin {
+	return s.used
+}
+
+// the free space above each skyline segment, split around the reserved
+// areas
+func (s *SkylinePacker) FreeBins() []*Bin {
+	free := []*Bin{}
+	for _, seg := range s.skyline {
+		h := s.y + s.height - seg.y
+		if h > 0 {
+			free = append(free, NewBin(seg.x, seg.y, seg.width, h, false))
+		}
+	}
+	for _, r := range s.reserved {
+		free = splitFree(free, r)
+	}
+	return free
+}
+
+// the y position a rect of the given width would sit at if placed
+// at segment i, returns false if it does not fit
+func (s *SkylinePacker) fit(i int, width, height float64) (float64, bool) {
+	x := s.skyline[i].x
+	if x+width > s.x+s.width {
+		return 0, false
+	}
+	y := s.skyline[i].y
+	remaining := width
+	for j := i; remaining > 1e-9; j++ {
+		if j >= len(s.skyline) {
+			return 0, false
+		}
+		y = math.Max(y, s.skyline[j].y)
+		if y+height > s.y+s.height {
+			return 0, false
+		}
+		remaining -= s.skyline[j].width
+	}
+	// move below any reserved areas it overlaps
+	for moved := true; moved; {
+		moved = false
+		placed := NewBin(x, y, width, height, false)
+		for _, r := range s.reserved {
+			if intersects(placed, r) {
+				y = r.Y + r.Height
+				moved = true
+				break
+			}
+		}
+	}
+	if y+height > s.y+s.height {
+		return 0, false
+	}
+	return y, true
+}
+
+func (s *SkylinePacker) Reserve(object interface{}, x, y, width, height float64) {
+	b := placedBin(object, x, y, width, height, false)
+	s.reserved = append(s.reserved, b)
+	s.used = append(s.used, b)
+}
+
+func (s *SkylinePacker) Insert(object interface{}, boundary BinBoundary) (bool, *Bin) {
+	if invalidBoundary(boundary) {
+		return false, nil
+	}
+	var best *Bin
+	bestIndex := -1
+	bestTop := math.Inf(1)
+	bestX := math.Inf(1)
+	for i := range s.skyline {
+		for _, rotated := range allowedRotations(boundary) {
+			width := boundary.GetWidth()
+			height := boundary.GetHeight()
+			if rotated {
+				width, height = height, width
+			}
+			y, ok := s.fit(i, width, height)
+			if !ok {
+				continue
+			}
+			top := y + height
+			x := s.skyline[i].x
+			if top < bestTop || (top == bestTop && x < bestX) {
+				bestTop = top
+				bestX = x
+				bestIndex = i
+				best = placedBin(object, x, y, width, height, rotated)
+			}
+		}
+	}
+	if best == nil {
+		return false, nil
+	}
+	s.place(bestIndex, best)
+	return true, best
+}
+
+// raises the skyline under the placed bin
+func (s *SkylinePacker) place(index int, b *Bin) {
+	newSeg := skylineSegment{x: b.X, y: b.Y + b.Height, width: b.Width}
+	skyline := append([]skylineSegment{}, s.skyline[:index]...)
+	skyline = append(skyline, newSeg)
+	right := b.X + b.Width
+	for _, seg := range s.skyline[index:] {
+		segRight := seg.x + seg.width
+		if segRight <= right {
+			// covered by the new segment
+			continue
+		}
+		if seg.x < right {
+			// partially covered
+			seg.width = segRight - right
+			seg.x = right
+		}
+		skyline = append(skyline, seg)
+	}
+
+	// merge neighbors at the same height
+	s.skyline = []skylineSegment{}
+	for _, seg := range skyline {
+		last := len(s.skyline) - 1
+		if last >= 0 && s.skyline[last].y == seg.y {
+			s.skyline[last].width += seg.width
+			continue
+		}
+		s.skyline = append(s.skyline, seg)
+	}
+	s.used = append(s.used, b)
+}
@@ -23,6 +23,12 @@ type SVGParser interface {
 	ParseSVG(svg string, logger *util.HfdLog) (path.Path, error)
 }
 
+type DXFParser interface {
+	// parses the dxf, only entities on the requested layers are
+	// included.  If layers is empty then all layers are used
+	ParseDXF(dxf string, layers []string, logger *util.HfdLog) (path.Path, error)
+}
+
 type ComponentFactory interface {
 	CreateComponent(componentType string, dm *dynmap.DynMap, dc *DocumentContext) (Component, error)
 	// The list of component types this Factory should be used for
@@ -50,6 +56,7 @@ type Factories struct {
 	fileLoader               FileLoader
 	precision                int
 	svgParser                SVGParser
+	dxfParser                DXFParser
 }
 
 var appContext *Factories
@@ -80,6 +87,7 @@ func (c *Factories) Init(componentFactories []ComponentFactory,
 	documentParser DocumentParser,
 	fileLoader FileLoader,
 	svgParser SVGParser,
+	dxfParser DXFParser,
 ) {
 	c.componentFactories = nil
 	for _, cf := range componentFactories {
@@ -97,6 +105,7 @@ func (c *Factories) Init(componentFactories []ComponentFactory,
 	c.documentParser = documentParser
 	c.fileLoader = fileLoader
 	c.svgParser = svgParser
+	c.dxfParser = dxfParser
 }
 
 func (c *Factories) AddTransformFactory(tf TransformFactory) {
@@ -144,6 +153,10 @@ func (c *Factories) ParseSVG(svg string, logger *util.HfdLog) (path.Path, error)
 	return c.svgParser.ParseSVG(svg, logger)
 }
 
+func (c *Factories) ParseDXF(dxf string, layers []string, logger *util.HfdLog) (path.Path, error) {
+	return c.dxfParser.ParseDXF(dxf, layers, logger)
+}
+
 // Makes a component from the DynMap,
 // there must be a field called "type"
 func (c *Factories) MakeComponent(dm *dynmap.DynMap, dc *DocumentContext) (Component, error) {
@@ -183,6 +196,7 @@ func (c *Factories) MakeBasicComponent(dm *dynmap.DynMap) *BasicComponent {
 		originalMap: be.originalMap,
 		params:      be.params,
 		defaults:    be.defaults,
+		hidden:      dm.MustBool("hidden", false),
 	}
 }
 func (c *Factories) MakePartTransformer(transformType string, dm *dynmap.DynMap, part *Part) (PartTransformer, error) {
@@ -302,6 +316,12 @@ func ParseDocument(dm *dynmap.DynMap, logger *util.HfdLog) (*Document, error) {
 		customComponents: map[string]Component{},
 		Params:           dynmap.New(),
 	}
+	dc.docParams = dm.MustDynMap("params", dynmap.New())
+	materialParams, err := materialParams(dc.docParams)
+	if err != nil {
+		return nil, err
+	}
+	dc.MaterialParams = materialParams
 	for _, importDm := range dm.MustDynMapSlice("imports", []*dynmap.DynMap{}) {
 		pth := importDm.MustString("path", "")
 		importType := importDm.MustString("type", "component")
@@ -315,6 +335,10 @@ func ParseDocument(dm *dynmap.DynMap, logger *util.HfdLog) (*Document, error) {
 				if len(customType) > 0 {
 					varName := importDm.MustString(fmt.Sprintf("alias.%s", customType), customType)
 					dc.customComponents[varName] = e.(Component)
+					// the imported material is overridden by the params
+					if newDoc.Context.MaterialParams != nil {
+						e.Defaults().Merge(newDoc.Context.MaterialParams)
+					}
 					// merge all the document params as the defaults of the
 					// for the current element
 					e.Defaults().Merge(newDoc.Params())
@@ -337,6 +361,22 @@ func ParseDocument(dm *dynmap.DynMap, logger *util.HfdLog) (*Document, error) {
 			}
 			s := path.SvgString(p, AppContext().Precision())
 			dc.Params.Put(varName, s)
+		} else if importType == "dxf" {
+			dxfBytes, err := AppContext().FileLoader().LoadBytes(pth)
+			if err != nil {
+				return nil, err
+			}
+			varName, ok := importDm.GetString("alias")
+			if !ok {
+				return nil, fmt.Errorf("Error, alias must be provided for dxf import: %s", pth)
+			}
+			layers := importDm.MustStringSlice("layers", []string{})
+			p, err := AppContext().ParseDXF(string(dxfBytes), layers, logger)
+			if err != nil {
+				return nil, fmt.Errorf("Error trying to import %s.  Error: %s", pth, err.Error())
+			}
+			s := path.SvgString(p, AppContext().Precision())
+			dc.Params.Put(varName, s)
 		}
 	}
 
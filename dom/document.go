@@ -1,6 +1,7 @@
 package dom
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/dustismo/heavyfishdesign/dynmap"
@@ -9,6 +10,22 @@ import (
 type DocumentContext struct {
 	customComponents map[string]Component
 	Params           *dynmap.DynMap
+	// params from the document material, nil if there is no material
+	MaterialParams *dynmap.DynMap
+	// the document params, used to find the materials of parts
+	docParams *dynmap.DynMap
+}
+
+// looks up the param in the context params, then the material params
+func (dc *DocumentContext) lookup(param string) (interface{}, bool) {
+	if dc == nil {
+		return nil, false
+	}
+	v, found := dc.Params.Get(param)
+	if !found && dc.MaterialParams != nil {
+		v, found = dc.MaterialParams.Get(param)
+	}
+	return v, found
 }
 
 // Creates a custom component if possible
@@ -96,3 +113,35 @@ func (d *Document) FindPartByID(id string) (*Part, bool) {
 	}
 	return nil, false
 }
+
+// the document params with their metadata, sorted by name.
+// Params can be a plain value or a map with value, description and data_type,
+// each is returned as a map with name, value, description and data_type
+func (d *Document) ParamInfos() []*dynmap.DynMap {
+	params := d.Params()
+	names := []string{}
+	for name := range params.Map {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := []*dynmap.DynMap{}
+	for _, name := range names {
+		v, _ := params.Get(name)
+		info := dynmap.New()
+		info.Put("name", name)
+		if dynmap.IsDynMapConvertable(v) {
+			meta, _ := dynmap.ToDynMap(v)
+			if !meta.Contains("value") {
+				// a nested map, not a param with metadata
+				continue
+			}
+			v, _ = meta.Get("value")
+			info.Put("description", meta.MustString("description", ""))
+			info.Put("data_type", meta.MustString("data_type", ""))
+		}
+		info.Put("value", v)
+		infos = append(infos, info)
+	}
+	return infos
+}
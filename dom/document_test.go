@@ -0,0 +1,31 @@
+package dom
+
+import (
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+func TestParamInfos(t *testing.T) {
+	doc, err := ParseDocumentFromJson(`{
+		"params": {
+			"width": {"value": 5, "description": "box width", "data_type": "float"},
+			"height": 2
+		}
+	}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	infos := doc.ParamInfos()
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 params, got %d", len(infos))
+	}
+	if infos[0].MustString("name", "") != "height" || infos[0].MustInt("value", 0) != 2 {
+		t.Errorf("Unexpected param %s", infos[0].ToJSON())
+	}
+	if infos[1].MustString("description", "") != "box width" ||
+		infos[1].MustString("data_type", "") != "float" ||
+		infos[1].MustFloat64("value", 0) != 5 {
+		t.Errorf("Unexpected param %s", infos[1].ToJSON())
+	}
+}
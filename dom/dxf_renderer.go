@@ -0,0 +1,276 @@
+package dom
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// DXF output.
+// We write an R12 (AC1009) ascii DXF since it is the most widely supported
+// version.  Lines become LINE entities, circular arcs become ARC (or CIRCLE)
+// entities, curves and elliptical arcs are flattened into POLYLINEs
+// based on the document CurveTolerance.  Each part gets its own layer
+// named after the part id, score and engrave paths get a layer with the
+// operation appended (my_part_score).  Labels are written to the LABELS layer.
+
+const dxfLabelLayer = "LABELS"
+
+// $INSUNITS values
+func dxfUnits(u Units) int {
+	switch u.Abv {
+	case "in":
+		return 1
+	case "mm":
+		return 4
+	}
+	return 0
+}
+
+// layer names cannot contain a number of special characters
+func dxfLayerName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>/\":;?*|=,' `, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+type dxfWriter struct {
+	writer    io.Writer
+	precision int
+	// document height, dxf y axis is inverted from svg
+	height float64
+}
+
+func (w *dxfWriter) code(code int, value string) {
+	fmt.Fprintf(w.writer, "%d\n%s\n", code, value)
+}
+
+func (w *dxfWriter) float(code int, value float64) {
+	w.code(code, fmt.Sprintf("%.*f", w.precision, value))
+}
+
+func (w *dxfWriter) int(code int, value int) {
+	w.code(code, fmt.Sprintf("%d", value))
+}
+
+// writes the x,y coordinates of the point with the given base
+// group code (10, 11, etc)
+func (w *dxfWriter) point(code int, p path.Point) {
+	w.float(code, p.X)
+	w.float(code+10, w.height-p.Y)
+	w.float(code+20, 0)
+}
+
+func (w *dxfWriter) line(layer string, start, end path.Point) {
+	w.code(0, "LINE")
+	w.code(8, layer)
+	w.point(10, start)
+	w.point(11, end)
+}
+
+// writes a circular arc.  dxf arcs go counterclockwise from the start
+// angle to the end angle, with the y axis up.
+func (w *dxfWriter) arc(layer string, a path.ArcSegment) {
+	if a.IsFull() {
+		w.code(0, "CIRCLE")
+		w.code(8, layer)
+		w.point(10, a.Center)
+		w.float(40, a.RadiusX)
+		return
+	}
+	// the y axis is inverted, so angles are negated
+	start := -(a.StartAngle + a.Rotation)
+	end := -(a.EndAngle() + a.Rotation)
+	if a.Sweep > 0 {
+		start, end = end, start
+	}
+	degrees := func(radians float64) float64 {
+		d := math.Mod(radians*180/math.Pi, 360)
+		if d < 0 {
+			d += 360
+		}
+		return d
+	}
+	w.code(0, "ARC")
+	w.code(8, layer)
+	w.point(10, a.Center)
+	w.float(40, a.RadiusX)
+	w.float(50, degrees(start))
+	w.float(51, degrees(end))
+}
+
+func (w *dxfWriter) polyline(layer string, points []path.Point) {
+	closed := 0
+	if len(points) > 2 && points[0].EqualsPrecision(points[len(points)-1], w.precision) {
+		closed = 1
+		points = points[:len(points)-1]
+	}
+	w.code(0, "POLYLINE")
+	w.code(8, layer)
+	w.int(66, 1)
+	w.point(10, path.NewPoint(0, w.height))
+	w.int(70, closed)
+	for _, p := range points {
+		w.code(0, "VERTEX")
+		w.code(8, layer)
+		w.point(10, p)
+	}
+	w.code(0, "SEQEND")
+	w.code(8, layer)
+}
+
+func (w *dxfWriter) text(layer string, p path.Point, height float64, rotation float64, txt string) {
+	w.code(0, "TEXT")
+	w.code(8, layer)
+	w.point(10, p)
+	w.float(40, height)
+	w.code(1, txt)
+	if rotation != 0 {
+		w.float(50, rotation)
+	}
+}
+
+// writes the part path, lines and circular arcs are written as is,
+// consecutive curves are joined into a single polyline.
+func (w *dxfWriter) path(layer string, pth path.Path, tolerance float64) {
+	polyline := []path.Point{}
+	flush := func() {
+		if len(polyline) > 1 {
+			w.polyline(layer, polyline)
+		}
+		polyline = []path.Point{}
+	}
+	curve := func(c path.CurveSegment) {
+		points := path.FlattenCurve(c, tolerance)
+		if len(polyline) > 0 {
+			points = points[1:]
+		}
+		polyline = append(polyline, points...)
+	}
+	for _, seg := range pth.Segments() {
+		switch s := seg.(type) {
+		case path.CurveSegment:
+			curve(s)
+		case path.ArcSegment:
+			if s.IsCircle() {
+				flush()
+				w.arc(layer, s)
+				break
+			}
+			for _, c := range s.ToCurves() {
+				curve(c)
+			}
+		case path.LineSegment:
+			flush()
+			w.line(layer, s.Start(), s.End())
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+func dxfOperationLayerName(partId string, op Operation) string {
+	if op == Cut {
+		return dxfLayerName(partId)
+	}
+	return dxfLayerName(fmt.Sprintf("%s_%s", partId, op))
+}
+
+// the layer color for each operation
+func dxfOperationColor(op Operation) int {
+	switch op {
+	case Score:
+		return 8 // grey
+	case Engrave:
+		return 5 // blue
+	}
+	return 7 // black / white
+}
+
+// the layer names and colors
+func (d *SVGDocument) layers() ([]string, map[string]int) {
+	names := []string{}
+	colors := map[string]int{}
+	for _, r := range d.renderables {
+		for _, op := range r.renderedPart.OperationPaths() {
+			name := dxfOperationLayerName(r.renderedPart.Part.Id(), op.Operation)
+			names = append(names, name)
+			colors[name] = dxfOperationColor(op.Operation)
+		}
+	}
+	return path.StringArrayDeDup(names), colors
+}
+
+// writes the whole document as DXF
+func (d *SVGDocument) WriteDXF(ctx RenderContext, writer io.Writer) error {
+	w := &dxfWriter{
+		writer:    writer,
+		precision: d.Precision,
+		height:    d.Height,
+	}
+
+	// header
+	w.code(0, "SECTION")
+	w.code(2, "HEADER")
+	w.code(9, "$ACADVER")
+	w.code(1, "AC1009")
+	w.code(9, "$INSUNITS")
+	w.int(70, dxfUnits(d.Units))
+	w.code(9, "$EXTMIN")
+	w.point(10, path.NewPoint(0, d.Height))
+	w.code(9, "$EXTMAX")
+	w.point(10, path.NewPoint(d.Width, 0))
+	w.code(0, "ENDSEC")
+
+	// layer table
+	layers, colors := d.layers()
+	layers = append(layers, dxfLabelLayer)
+	colors[dxfLabelLayer] = 5 // blue
+	w.code(0, "SECTION")
+	w.code(2, "TABLES")
+	w.code(0, "TABLE")
+	w.code(2, "LAYER")
+	w.int(70, len(layers))
+	for _, l := range layers {
+		w.code(0, "LAYER")
+		w.code(2, l)
+		w.int(70, 0)
+		w.int(62, colors[l])
+		w.code(6, "CONTINUOUS")
+	}
+	w.code(0, "ENDTAB")
+	w.code(0, "ENDSEC")
+
+	// entities
+	w.code(0, "SECTION")
+	w.code(2, "ENTITIES")
+	for _, r := range d.renderables {
+		for _, op := range r.renderedPart.OperationPaths() {
+			pth, err := r.documentOperationPath(op.Path)
+			if err != nil {
+				return err
+			}
+			w.path(dxfOperationLayerName(r.renderedPart.Part.Id(), op.Operation), pth, d.CurveTolerance)
+		}
+
+		if len(r.renderedPart.Label.Text) > 0 {
+			textPos, err := r.documentLabelPosition()
+			if err != nil {
+				return err
+			}
+			// dxf angles are counterclockwise
+			rotation := math.Mod(360-r.labelAngle(), 360)
+			w.text(dxfLabelLayer, textPos, d.Units.FromMM(3), rotation, r.renderedPart.Label.Text)
+		}
+	}
+	w.code(0, "ENDSEC")
+	w.code(0, "EOF")
+	return nil
+}
@@ -0,0 +1,68 @@
+package dom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+func testRenderedPart(id string, svg string) *RenderedPart {
+	dm := dynmap.New()
+	dm.Put("id", id)
+	pth, _ := path.ParsePathFromSvg(svg)
+	tl, br, _ := path.BoundingBoxTrimWhitespace(pth, AppContext().SegmentOperators())
+	return &RenderedPart{
+		Part:   &Part{BasicComponent: AppContext().MakeBasicComponent(dm)},
+		Path:   pth,
+		Width:  br.X - tl.X,
+		Height: br.Y - tl.Y,
+	}
+}
+
+func TestDocRenderableRotate(t *testing.T) {
+	dr := &docRenderable{
+		position:         path.NewPoint(1, 1),
+		renderedPart:     testRenderedPart("test", "M 0 0 L 4 0 L 4 2"),
+		rotate:           true,
+		segmentOperators: AppContext().SegmentOperators(),
+	}
+	pth, err := dr.documentPath()
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	expected := "M 3.000 1.000 L 3.000 5.000 L 1.000 5.000"
+	actual := path.SvgString(pth, 3)
+	if expected != actual {
+		t.Errorf("Expected: %s\nActual: %s", expected, actual)
+	}
+}
+
+func TestWriteDXF(t *testing.T) {
+	doc := NewSVGDocument(10, 10, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	_, err := doc.Add(testRenderedPart("my part", "M 0 0 L 2 0 C 3 0 3 1 2 1 L 0 1"), RenderContext{})
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	buf := &bytes.Buffer{}
+	err = doc.WriteDXF(RenderContext{}, buf)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	dxf := buf.String()
+	if strings.Count(dxf, "\nLINE\n") != 2 {
+		t.Errorf("Expected 2 LINE entities\n%s", dxf)
+	}
+	if strings.Count(dxf, "\nPOLYLINE\n") != 1 {
+		t.Errorf("Expected 1 POLYLINE entity\n%s", dxf)
+	}
+	if !strings.Contains(dxf, "\nmy_part\n") {
+		t.Errorf("Expected layer named my_part\n%s", dxf)
+	}
+	if !strings.HasSuffix(dxf, "EOF\n") {
+		t.Errorf("Expected dxf to end with EOF")
+	}
+}
@@ -0,0 +1,478 @@
+package dom
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// Laser job estimate.
+// Estimates how long each sheet takes to run on a laser and what the job
+// costs.  Paths are measured exactly, curves by their arc length.  Each
+// continuous section of a path is broken into runs at sharp corners, the
+// head accelerates to the operation speed and decelerates again for every
+// run, and every section costs one pierce.  Between sections the head
+// travels in a straight line at the travel speed, sections are run in
+// the order they are written, operation by operation, starting at the
+// origin.  Machine speeds are in mm/sec, distances are reported in the
+// document units and times in seconds.  A material group can set its own
+// speeds, sheets of the group are run with them.
+
+// corners sharper than this (in degrees) bring the head to a stop
+const estimateCornerAngle = 30.0
+
+type MachineProfile struct {
+	Name string
+	// cutting speed for each operation, in mm/sec
+	Speed map[Operation]float64
+	// speed of moves with the laser off, in mm/sec
+	TravelSpeed float64
+	// in mm/sec², 0 means the head changes speed instantly
+	Acceleration float64
+	// seconds spent firing at the start of each section
+	PierceDelay float64
+	// cost of machine time per hour
+	HourlyRate float64
+}
+
+// the built in machine profiles
+var machineProfiles = map[string]MachineProfile{
+	"generic": {
+		Name:         "generic",
+		Speed:        map[Operation]float64{Cut: 20, Score: 100, Engrave: 300},
+		TravelSpeed:  300,
+		Acceleration: 3000,
+		PierceDelay:  .05,
+	},
+	"glowforge_basic": {
+		Name:         "glowforge_basic",
+		Speed:        map[Operation]float64{Cut: 8, Score: 60, Engrave: 200},
+		TravelSpeed:  400,
+		Acceleration: 2000,
+		PierceDelay:  .1,
+	},
+	"glowforge_pro": {
+		Name:         "glowforge_pro",
+		Speed:        map[Operation]float64{Cut: 12, Score: 80, Engrave: 250},
+		TravelSpeed:  500,
+		Acceleration: 3000,
+		PierceDelay:  .1,
+	},
+	"k40": {
+		Name:         "k40",
+		Speed:        map[Operation]float64{Cut: 6, Score: 40, Engrave: 150},
+		TravelSpeed:  200,
+		Acceleration: 1000,
+		PierceDelay:  .2,
+	},
+}
+
+// the names of the built in machine profiles
+func MachineProfileNames() []string {
+	names := []string{}
+	for name := range machineProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// returns a copy of the named machine profile
+func NewMachineProfile(name string) (MachineProfile, bool) {
+	m, ok := machineProfiles[strings.ToLower(name)]
+	if !ok {
+		return MachineProfile{}, false
+	}
+	speed := map[Operation]float64{}
+	for op, s := range m.Speed {
+		speed[op] = s
+	}
+	m.Speed = speed
+	return m, true
+}
+
+// reads the machine profile from the document params.  The machine param
+// names the profile, and the speed, travel_speed, acceleration,
+// pierce_delay and machine_rate params override its values.  The speed
+// params are the same ones used for lbrn2 output, for instance cut_speed
+func machineProfileFromAttr(attr *Attr) (MachineProfile, error) {
+	name := attr.MustString("machine", "generic")
+	m, ok := NewMachineProfile(name)
+	if !ok {
+		return m, fmt.Errorf("Unknown machine %s, expected one of %s", name, strings.Join(MachineProfileNames(), ", "))
+	}
+	for _, op := range Operations {
+		m.Speed[op] = attr.MustFloat64(string(op)+"_speed", m.Speed[op])
+	}
+	m.TravelSpeed = attr.MustFloat64("travel_speed", m.TravelSpeed)
+	m.Acceleration = attr.MustFloat64("acceleration", m.Acceleration)
+	m.PierceDelay = attr.MustFloat64("pierce_delay", m.PierceDelay)
+	m.HourlyRate = attr.MustFloat64("machine_rate", m.HourlyRate)
+	for _, op := range Operations {
+		if m.Speed[op] <= 0 {
+			return m, fmt.Errorf("Machine %s %s speed must be greater than 0", m.Name, op)
+		}
+	}
+	if m.TravelSpeed <= 0 {
+		return m, fmt.Errorf("Machine %s travel speed must be greater than 0", m.Name)
+	}
+	return m, nil
+}
+
+// the time to move the distance (in mm) starting and ending at rest.
+// the head accelerates up to speed, or as close as it can get in half
+// the distance, then decelerates
+func (m MachineProfile) moveTime(distance, speed float64) float64 {
+	if distance <= 0 {
+		return 0
+	}
+	if m.Acceleration <= 0 {
+		return distance / speed
+	}
+	// distance needed to reach full speed and stop again
+	ramp := speed * speed / m.Acceleration
+	if distance < ramp {
+		return 2 * math.Sqrt(distance/m.Acceleration)
+	}
+	return distance/speed + speed/m.Acceleration
+}
+
+func (m MachineProfile) ToDynMap() *dynmap.DynMap {
+	speed := dynmap.New()
+	for _, op := range Operations {
+		speed.Put(string(op), m.Speed[op])
+	}
+	dm := dynmap.New()
+	dm.Put("name", m.Name)
+	dm.Put("speed", speed)
+	dm.Put("travel_speed", m.TravelSpeed)
+	dm.Put("acceleration", m.Acceleration)
+	dm.Put("pierce_delay", m.PierceDelay)
+	dm.Put("machine_rate", m.HourlyRate)
+	return dm
+}
+
+// the estimate for one sheet, or the totals of all the sheets
+type SheetEstimate struct {
+	// the index of the sheet, -1 for the totals
+	Index    int
+	Stock    string
+	Material string
+	// length of the paths for each operation
+	Length map[Operation]float64
+	// seconds spent on each operation, not including pierces
+	CutTime        map[Operation]float64
+	TravelDistance float64
+	TravelTime     float64
+	Pierces        int
+	PierceTime     float64
+	MaterialCost   float64
+	MachineCost    float64
+}
+
+type JobEstimate struct {
+	Units   Units
+	Machine MachineProfile
+	Sheets  []*SheetEstimate
+	Total   *SheetEstimate
+}
+
+func newSheetEstimate(index int) *SheetEstimate {
+	return &SheetEstimate{
+		Index:   index,
+		Length:  map[Operation]float64{},
+		CutTime: map[Operation]float64{},
+	}
+}
+
+// the total seconds the machine runs
+func (s *SheetEstimate) Time() float64 {
+	t := s.TravelTime + s.PierceTime
+	for _, c := range s.CutTime {
+		t += c
+	}
+	return t
+}
+
+func (s *SheetEstimate) Cost() float64 {
+	return s.MaterialCost + s.MachineCost
+}
+
+// converts the distances from the units to the other units
+func (s *SheetEstimate) convert(from, to Units) {
+	scale := to.FromMM(1) / from.FromMM(1)
+	for op := range s.Length {
+		s.Length[op] *= scale
+	}
+	s.TravelDistance *= scale
+}
+
+// adds the other estimate to this one
+func (s *SheetEstimate) add(other *SheetEstimate) {
+	for op, l := range other.Length {
+		s.Length[op] += l
+	}
+	for op, t := range other.CutTime {
+		s.CutTime[op] += t
+	}
+	s.TravelDistance += other.TravelDistance
+	s.TravelTime += other.TravelTime
+	s.Pierces += other.Pierces
+	s.PierceTime += other.PierceTime
+	s.MaterialCost += other.MaterialCost
+	s.MachineCost += other.MachineCost
+}
+
+func (s *SheetEstimate) ToDynMap() *dynmap.DynMap {
+	dm := dynmap.New()
+	if s.Index >= 0 {
+		dm.Put("sheet", s.Index)
+		dm.Put("stock", s.Stock)
+		if len(s.Material) > 0 {
+			dm.Put("material", s.Material)
+		}
+	}
+	length := dynmap.New()
+	cutTime := dynmap.New()
+	for _, op := range Operations {
+		if l, ok := s.Length[op]; ok {
+			length.Put(string(op), l)
+			cutTime.Put(string(op), s.CutTime[op])
+		}
+	}
+	dm.Put("length", length)
+	dm.Put("cut_time", cutTime)
+	dm.Put("travel_distance", s.TravelDistance)
+	dm.Put("travel_time", s.TravelTime)
+	dm.Put("pierces", s.Pierces)
+	dm.Put("pierce_time", s.PierceTime)
+	dm.Put("time", s.Time())
+	dm.Put("material_cost", s.MaterialCost)
+	dm.Put("machine_cost", s.MachineCost)
+	dm.Put("cost", s.Cost())
+	return dm
+}
+
+func (e *JobEstimate) ToDynMap() *dynmap.DynMap {
+	sheets := []*dynmap.DynMap{}
+	for _, s := range e.Sheets {
+		sheets = append(sheets, s.ToDynMap())
+	}
+	total := e.Total.ToDynMap()
+	total.Put("sheets", len(e.Sheets))
+	dm := dynmap.New()
+	dm.Put("units", e.Units.Abv)
+	dm.Put("machine", e.Machine.ToDynMap())
+	dm.Put("sheets", sheets)
+	dm.Put("total", total)
+	return dm
+}
+
+// formats the seconds as h:mm:ss
+func formatDuration(seconds float64) string {
+	s := int(math.Round(seconds))
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, (s/60)%60, s%60)
+}
+
+// writes the estimate as plain text
+func (e *JobEstimate) WriteText(w io.Writer) {
+	write := func(title string, s *SheetEstimate) {
+		fmt.Fprintf(w, "%s\n", title)
+		for _, op := range Operations {
+			if l, ok := s.Length[op]; ok {
+				fmt.Fprintf(w, "  %-8s %s (%.3f %s)\n", op, formatDuration(s.CutTime[op]), l, e.Units.Abv)
+			}
+		}
+		fmt.Fprintf(w, "  travel   %s (%.3f %s)\n", formatDuration(s.TravelTime), s.TravelDistance, e.Units.Abv)
+		fmt.Fprintf(w, "  pierce   %s (%d pierces)\n", formatDuration(s.PierceTime), s.Pierces)
+		fmt.Fprintf(w, "  time     %s\n", formatDuration(s.Time()))
+		fmt.Fprintf(w, "  material %.2f\n", s.MaterialCost)
+		fmt.Fprintf(w, "  machine  %.2f\n", s.MachineCost)
+		fmt.Fprintf(w, "  cost     %.2f\n", s.Cost())
+	}
+	fmt.Fprintf(w, "machine: %s\n", e.Machine.Name)
+	for _, s := range e.Sheets {
+		title := fmt.Sprintf("sheet %d: %s", s.Index, s.Stock)
+		if len(s.Material) > 0 {
+			title += " " + s.Material
+		}
+		write(title, s)
+	}
+	write(fmt.Sprintf("total: %d sheets", len(e.Sheets)), e.Total)
+}
+
+// the direction the segment leaves its start and arrives at its end, in radians.
+// ok is false if the segment has no length
+func segmentDirections(seg path.Segment) (start, end float64, ok bool) {
+	if a, isArc := seg.(path.ArcSegment); isArc {
+		curves := a.ToCurves()
+		if len(curves) == 0 {
+			return 0, 0, false
+		}
+		start, _, ok = segmentDirections(curves[0])
+		_, end, _ = segmentDirections(curves[len(curves)-1])
+		return start, end, ok
+	}
+	points := []path.Point{seg.Start()}
+	if c, isCurve := seg.(path.Curve); isCurve {
+		points = append(points, c.ControlStart(), c.ControlEnd())
+	}
+	points = append(points, seg.End())
+	angle := func(a, b path.Point) float64 {
+		return math.Atan2(b.Y-a.Y, b.X-a.X)
+	}
+	first, last := -1, -1
+	for i := 1; i < len(points); i++ {
+		if !points[i].Equals(points[0]) {
+			first = i
+			break
+		}
+	}
+	for i := len(points) - 2; i >= 0; i-- {
+		if !points[i].Equals(points[len(points)-1]) {
+			last = i
+			break
+		}
+	}
+	if first < 0 || last < 0 {
+		return 0, 0, false
+	}
+	return angle(points[0], points[first]), angle(points[last], points[len(points)-1]), true
+}
+
+// the lengths of the runs in the section, the section is broken into
+// runs wherever the direction changes by more than estimateCornerAngle
+func sectionRuns(section path.Path) []float64 {
+	runs := []float64{}
+	run := 0.0
+	var direction float64
+	started := false
+	for _, seg := range section.Segments() {
+		if path.IsMove(seg) {
+			continue
+		}
+		start, end, ok := segmentDirections(seg)
+		if !ok {
+			continue
+		}
+		if started {
+			turn := math.Abs(math.Remainder(start-direction, 2*math.Pi))
+			if turn*180/math.Pi > estimateCornerAngle {
+				runs = append(runs, run)
+				run = 0
+			}
+		}
+		run += path.SegmentLength(seg)
+		direction = end
+		started = true
+	}
+	if started {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// the first and last points drawn in the section
+func sectionEnds(section path.Path) (start, end path.Point) {
+	started := false
+	for _, seg := range section.Segments() {
+		if path.IsMove(seg) {
+			continue
+		}
+		if !started {
+			start = seg.Start()
+			started = true
+		}
+		end = seg.End()
+	}
+	return start, end
+}
+
+// estimates the time and cost to run the document on the machine.
+// price is the cost of the sheet
+func (d *SVGDocument) Estimate(index int, machine MachineProfile, price float64) (*SheetEstimate, error) {
+	s := newSheetEstimate(index)
+	s.Stock = fmt.Sprintf("%gx%g", d.Width, d.Height)
+	if d.Stock != nil {
+		s.Stock = d.Stock.Name
+	}
+	s.Material = d.Material
+	// machine speeds are in mm
+	scale := InchToMM(1) / d.Units.FromInch(1)
+	position := path.NewPoint(0, 0)
+	for _, op := range d.Operations() {
+		for _, r := range d.renderables {
+			for _, o := range r.renderedPart.OperationPaths() {
+				if o.Operation != op {
+					continue
+				}
+				pth, err := r.documentOperationPath(o.Path)
+				if err != nil {
+					return nil, err
+				}
+				for _, section := range path.SplitPathOnMove(pth) {
+					runs := sectionRuns(section)
+					if len(runs) == 0 {
+						continue
+					}
+					start, end := sectionEnds(section)
+					travel := path.Distance(position, start)
+					s.TravelDistance += travel
+					s.TravelTime += machine.moveTime(travel*scale, machine.TravelSpeed)
+					for _, run := range runs {
+						s.Length[op] += run
+						s.CutTime[op] += machine.moveTime(run*scale, machine.Speed[op])
+					}
+					s.Pierces++
+					s.PierceTime += machine.PierceDelay
+					position = end
+				}
+			}
+		}
+	}
+	s.MaterialCost = price
+	s.MachineCost = s.Time() / 3600 * machine.HourlyRate
+	return s, nil
+}
+
+// estimates the time and cost of every document in the plan set.  The
+// sheet price is the stock price, or the material_price param for
+// sheets that are not from stock.  Each sheet uses the machine profile
+// of its material group, the estimate lists the document profile
+func (p *PlanSet) Estimate() (*JobEstimate, error) {
+	attr := p.doc.Attr()
+	machine, err := machineProfileFromAttr(attr)
+	if err != nil {
+		return nil, err
+	}
+	e := &JobEstimate{
+		Units:   MustUnits(attr.MustString("measurement_units", "in"), Inches),
+		Machine: machine,
+		Sheets:  []*SheetEstimate{},
+		Total:   newSheetEstimate(-1),
+	}
+	for i, d := range p.svgDocs {
+		groupAttr := p.documentGroup(d).attr()
+		groupMachine, err := machineProfileFromAttr(groupAttr)
+		if err != nil {
+			return nil, err
+		}
+		price := groupAttr.MustFloat64("material_price", 0)
+		if d.Stock != nil && d.Stock.Price > 0 {
+			price = d.Stock.Price
+		}
+		s, err := d.Estimate(i, groupMachine, price)
+		if err != nil {
+			return nil, err
+		}
+		s.convert(d.Units, e.Units)
+		e.Sheets = append(e.Sheets, s)
+		e.Total.add(s)
+	}
+	return e, nil
+}
@@ -0,0 +1,133 @@
+package dom
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+func TestEstimate(t *testing.T) {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-6
+	}
+	m, _ := NewMachineProfile("generic")
+	m.Acceleration = 100
+	if !near(m.moveTime(100, 10), 10.1) || !near(m.moveTime(.25, 10), .1) {
+		t.Errorf("Unexpected move times %f %f", m.moveTime(100, 10), m.moveTime(.25, 10))
+	}
+
+	pth, err := path.ParsePathFromSvg("M 0 0 L 10 0 L 10 10 C 10 15 5 20 0 20 L 0 0")
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if runs := sectionRuns(pth); len(runs) != 3 || !near(runs[0], 10) {
+		t.Errorf("Expected 3 runs, got %v", runs)
+	}
+
+	doc := NewSVGDocument(100, 100, MilliMeters)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	added, err := doc.Add(testRenderedPart("square", "M 0 0 L 10 0 L 10 10 L 0 10 L 0 0"), RenderContext{})
+	if err != nil || !added {
+		t.Fatalf("Expected square to be added (%v)", err)
+	}
+	d, err := ParseDocumentFromJson(`{"params": {
+		"measurement_units": "mm",
+		"machine": "k40",
+		"cut_speed": 10,
+		"travel_speed": 100,
+		"acceleration": 0,
+		"pierce_delay": 1,
+		"machine_rate": 3600,
+		"material_price": 5
+	}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	p := NewPlanSet(d)
+	p.svgDocs = []*SVGDocument{doc, doc}
+	estimate, err := p.Estimate()
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	s := estimate.Sheets[0]
+	if !near(s.CutTime[Cut], 4) || s.Pierces != 1 || !near(s.PierceTime, 1) {
+		t.Errorf("Unexpected sheet estimate %s", s.ToDynMap().ToJSON())
+	}
+	if s.TravelDistance <= 0 || !near(s.TravelTime, s.TravelDistance/100) {
+		t.Errorf("Unexpected travel %f %f", s.TravelDistance, s.TravelTime)
+	}
+	if !near(s.MaterialCost, 5) || !near(s.MachineCost, s.Time()) {
+		t.Errorf("Unexpected costs %f %f", s.MaterialCost, s.MachineCost)
+	}
+	if !near(estimate.Total.Cost(), 2*s.Cost()) || estimate.Machine.Name != "k40" {
+		t.Errorf("Unexpected total estimate %s", estimate.ToDynMap().ToJSON())
+	}
+	buf := &bytes.Buffer{}
+	estimate.WriteText(buf)
+	if !strings.Contains(buf.String(), "total: 2 sheets") {
+		t.Errorf("Unexpected text estimate\n%s", buf.String())
+	}
+
+	d, err = ParseDocumentFromJson(`{"params": {"machine": "unknown"}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if _, err := NewPlanSet(d).Estimate(); err == nil {
+		t.Errorf("Expected an error for an unknown machine")
+	}
+}
+
+// sheets of a material group use its units and speeds, the totals are in
+// the document units
+func TestEstimateMaterialGroups(t *testing.T) {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-6
+	}
+	d, err := ParseDocumentFromJson(`{"params": {
+		"cut_speed": 10,
+		"acceleration": 0,
+		"materials": {"acrylic": {"measurement_units": "mm", "cut_speed": 5}}
+	}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	previous := AppContext().FileLoader()
+	defer AppContext().SetFileLoader(previous)
+	AppContext().SetFileLoader(mapFileLoader{})
+	p := NewPlanSet(d)
+	group, err := p.materialGroup("acrylic")
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	p.groups = []*PlanSet{group}
+	// the same 1 inch square on each sheet
+	inDoc := NewSVGDocument(10, 10, Inches)
+	mmDoc := NewSVGDocument(254, 254, MilliMeters)
+	mmDoc.Material = "acrylic"
+	for _, doc := range []*SVGDocument{inDoc, mmDoc} {
+		doc.SegmentOperators = AppContext().SegmentOperators()
+		s := doc.Units.FromInch(1)
+		pth := fmt.Sprintf("M 0 0 L %f 0 L %f %f L 0 %f L 0 0", s, s, s, s)
+		added, err := doc.Add(testRenderedPart("square", pth), RenderContext{})
+		if err != nil || !added {
+			t.Fatalf("Expected square to be added (%v)", err)
+		}
+	}
+	p.svgDocs = []*SVGDocument{inDoc, mmDoc}
+	estimate, err := p.Estimate()
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	in, mm := estimate.Sheets[0], estimate.Sheets[1]
+	if !near(in.Length[Cut], 4) || !near(mm.Length[Cut], 4) || !near(estimate.Total.Length[Cut], 8) {
+		t.Errorf("Expected 4in of cuts on each sheet, got %f %f", in.Length[Cut], mm.Length[Cut])
+	}
+	if !near(in.CutTime[Cut], 101.6/10) || !near(mm.CutTime[Cut], 101.6/5) {
+		t.Errorf("Expected the acrylic cut speed on its sheet, got %f %f", in.CutTime[Cut], mm.CutTime[Cut])
+	}
+}
@@ -0,0 +1,325 @@
+package dom
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/transforms"
+)
+
+// GCode output for CNC routers.
+// Closed contours are offset by the tool radius, outward for outer contours
+// and inward for holes, holes are cut before the contour that surrounds them.
+// Open contours are cut on the line.  Each contour is cut in multiple
+// passes of at most PassDepth.  Score and engrave paths are cut on the
+// line in a single pass at EngraveDepth, before any of the cuts.  Curves are written as G2/G3 arcs where
+// they can be fit within the document CurveTolerance.
+// The origin is the bottom left of the material, Z 0 is the material surface.
+
+type GCodeSettings struct {
+	ToolDiameter float64
+	// total depth to cut, usually the material thickness
+	CutDepth float64
+	// max depth of a single pass
+	PassDepth float64
+	// depth for score and engrave paths
+	EngraveDepth float64
+	// feed rate for cutting moves, in units per minute
+	FeedRate float64
+	// feed rate for plunging into the material, in units per minute
+	PlungeRate float64
+	// height above the material for rapid moves
+	SafeHeight float64
+	// spindle rpm, if 0 no spindle commands are written
+	SpindleSpeed float64
+}
+
+// default settings are for a 1/8" endmill
+func NewGCodeSettings(unit Units) GCodeSettings {
+	return GCodeSettings{
+		ToolDiameter: unit.FromMM(3.175),
+		CutDepth:     unit.FromMM(5),
+		PassDepth:    unit.FromMM(1.5),
+		EngraveDepth: unit.FromMM(.5),
+		FeedRate:     unit.FromMM(1000),
+		PlungeRate:   unit.FromMM(300),
+		SafeHeight:   unit.FromMM(5),
+	}
+}
+
+// reads the gcode settings from the document params
+func gcodeSettingsFromAttr(attr *Attr, unit Units) GCodeSettings {
+	s := NewGCodeSettings(unit)
+	return GCodeSettings{
+		ToolDiameter: attr.MustFloat64("tool_diameter", s.ToolDiameter),
+		CutDepth:     attr.MustFloat64("cut_depth", attr.MustFloat64("material_thickness", s.CutDepth)),
+		PassDepth:    attr.MustFloat64("pass_depth", s.PassDepth),
+		EngraveDepth: attr.MustFloat64("engrave_depth", s.EngraveDepth),
+		FeedRate:     attr.MustFloat64("feed_rate", s.FeedRate),
+		PlungeRate:   attr.MustFloat64("plunge_rate", s.PlungeRate),
+		SafeHeight:   attr.MustFloat64("safe_height", s.SafeHeight),
+		SpindleSpeed: attr.MustFloat64("spindle_speed", s.SpindleSpeed),
+	}
+}
+
+// the depths of each pass, the last is always the full CutDepth
+func (s GCodeSettings) passDepths() []float64 {
+	if s.PassDepth <= 0 || s.CutDepth <= s.PassDepth {
+		return []float64{s.CutDepth}
+	}
+	passes := int(math.Ceil(s.CutDepth/s.PassDepth - 1e-9))
+	depths := []float64{}
+	for i := 1; i <= passes; i++ {
+		depths = append(depths, math.Min(s.PassDepth*float64(i), s.CutDepth))
+	}
+	return depths
+}
+
+// a single continuous toolpath
+type gcodeContour struct {
+	pth    path.Path
+	closed bool
+	// how many closed contours surround this one
+	depth int
+}
+
+type gcodeWriter struct {
+	writer    io.Writer
+	precision int
+	// document height, the gcode y axis is inverted from svg
+	height   float64
+	settings GCodeSettings
+}
+
+func (w *gcodeWriter) line(format string, args ...interface{}) {
+	fmt.Fprintf(w.writer, format+"\n", args...)
+}
+
+func (w *gcodeWriter) f(v float64) string {
+	return fmt.Sprintf("%.*f", w.precision, v)
+}
+
+func (w *gcodeWriter) xy(p path.Point) string {
+	return fmt.Sprintf("X%s Y%s", w.f(p.X), w.f(w.height-p.Y))
+}
+
+func (w *gcodeWriter) retract() {
+	w.line("G0 Z%s", w.f(w.settings.SafeHeight))
+}
+
+// writes the cutting moves for the segment, the tool is expected
+// to already be at the segment start
+func (w *gcodeWriter) segment(seg path.Segment, tolerance float64) {
+	switch s := seg.(type) {
+	case path.CurveSegment:
+		for _, a := range path.FitCurveArcs(s, tolerance) {
+			w.arc(a)
+		}
+	case path.ArcSegment:
+		if !s.IsCircle() {
+			for _, c := range s.ToCurves() {
+				w.segment(c, tolerance)
+			}
+			return
+		}
+		ends := []path.Point{s.EndPoint}
+		if s.IsFull() {
+			// a full circle is cut in two halves
+			ends = []path.Point{s.PointAtAngle(s.StartAngle + s.Sweep/2), s.EndPoint}
+		}
+		start := s.StartPoint
+		for _, end := range ends {
+			w.arc(path.FittedArc{
+				Start:     start,
+				End:       end,
+				Center:    s.Center,
+				Clockwise: s.Sweep > 0,
+			})
+			start = end
+		}
+	case path.LineSegment:
+		w.line("G1 %s", w.xy(s.End()))
+	}
+}
+
+// writes the move for the arc
+func (w *gcodeWriter) arc(a path.FittedArc) {
+	if a.IsLine {
+		w.line("G1 %s", w.xy(a.End))
+		return
+	}
+	cmd := "G3"
+	if a.Clockwise {
+		cmd = "G2"
+	}
+	// I and J are relative to the start, y is inverted
+	w.line("%s %s I%s J%s", cmd, w.xy(a.End),
+		w.f(a.Center.X-a.Start.X), w.f(a.Start.Y-a.Center.Y))
+}
+
+// cuts the contour with a pass at each of the depths
+func (w *gcodeWriter) contour(c gcodeContour, depths []float64, tolerance float64) {
+	segs := path.TrimMove(c.pth.Segments())
+	if len(segs) == 0 {
+		return
+	}
+	start := segs[0].Start()
+	for i, depth := range depths {
+		if i == 0 || !c.closed {
+			// open contours go back to the start for each pass
+			w.retract()
+			w.line("G0 %s", w.xy(start))
+		}
+		w.line("G1 Z%s F%s", w.f(-depth), w.f(w.settings.PlungeRate))
+		w.line("G1 F%s", w.f(w.settings.FeedRate))
+		for _, s := range segs {
+			w.segment(s, tolerance)
+		}
+	}
+	w.retract()
+}
+
+// splits the path into contours, offset by the tool radius
+func (d *SVGDocument) gcodeContours(pth path.Path, settings GCodeSettings) ([]gcodeContour, error) {
+	// join up any sections that meet
+	pth, err := transforms.ReorderTransform{Precision: d.Precision}.PathTransform(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	contours := []gcodeContour{}
+	boxes := [][2]path.Point{}
+	for _, p := range path.SplitPathOnMove(pth) {
+		segs := path.TrimMove(p.Segments())
+		if len(segs) == 0 {
+			continue
+		}
+		tl, br, err := path.BoundingBoxTrimWhitespace(p, d.SegmentOperators)
+		if err != nil {
+			return nil, err
+		}
+		start, end := segs[0].Start(), segs[len(segs)-1].End()
+		contours = append(contours, gcodeContour{
+			pth:    p,
+			closed: start.EqualsPrecision(end, d.Precision),
+		})
+		boxes = append(boxes, [2]path.Point{tl, br})
+	}
+
+	// anything inside an odd number of closed contours is a hole
+	for i := range contours {
+		for j, other := range contours {
+			if i == j || !other.closed {
+				continue
+			}
+			inside := path.PrecisionPointInBoundingBox(boxes[j][0], boxes[j][1], boxes[i][0], d.Precision) &&
+				path.PrecisionPointInBoundingBox(boxes[j][0], boxes[j][1], boxes[i][1], d.Precision)
+			same := boxes[i][0].EqualsPrecision(boxes[j][0], d.Precision) &&
+				boxes[i][1].EqualsPrecision(boxes[j][1], d.Precision)
+			if inside && !same {
+				contours[i].depth++
+			}
+		}
+	}
+
+	radius := settings.ToolDiameter / 2
+	offsetContours := []gcodeContour{}
+	for _, c := range contours {
+		if !c.closed || radius <= 0 {
+			offsetContours = append(offsetContours, c)
+			continue
+		}
+		var sizeShouldBe transforms.SizeShouldBe = transforms.Larger
+		if c.depth%2 == 1 {
+			sizeShouldBe = transforms.Smaller
+		}
+		p, err := transforms.OffsetTransform{
+			Precision:        d.Precision,
+			Distance:         radius,
+			SegmentOperators: d.SegmentOperators,
+			SizeShouldBe:     sizeShouldBe,
+		}.PathTransform(c.pth)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range path.SplitPathOnMove(p) {
+			offsetContours = append(offsetContours, gcodeContour{
+				pth:    op,
+				closed: c.closed,
+				depth:  c.depth,
+			})
+		}
+	}
+
+	// cut the innermost contours first, so parts are still held
+	// in place by the surrounding material
+	sort.SliceStable(offsetContours, func(i, j int) bool {
+		return offsetContours[i].depth > offsetContours[j].depth
+	})
+	return offsetContours, nil
+}
+
+// writes the whole document as gcode
+func (d *SVGDocument) WriteGCode(ctx RenderContext, writer io.Writer) error {
+	w := &gcodeWriter{
+		writer:    writer,
+		precision: d.Precision,
+		height:    d.Height,
+		settings:  d.GCode,
+	}
+	w.line("(Generated by github.com/dustismo/heavyfishdesign)")
+	w.line("(tool diameter %s, cut depth %s)", w.f(d.GCode.ToolDiameter), w.f(d.GCode.CutDepth))
+	switch d.Units.Abv {
+	case "mm":
+		w.line("G21")
+	default:
+		w.line("G20")
+	}
+	w.line("G90")
+	w.line("G17")
+	w.retract()
+	if d.GCode.SpindleSpeed > 0 {
+		w.line("M3 S%.0f", d.GCode.SpindleSpeed)
+	}
+
+	// operations are run in order, so all the engraving is done before
+	// any parts are cut free
+	for _, op := range d.Operations() {
+		for _, r := range d.renderables {
+			for _, o := range r.renderedPart.OperationPaths() {
+				if o.Operation != op {
+					continue
+				}
+				pth, err := r.documentOperationPath(o.Path)
+				if err != nil {
+					return err
+				}
+				if op == Cut {
+					contours, err := d.gcodeContours(pth, d.GCode)
+					if err != nil {
+						return fmt.Errorf("Error creating toolpath for part %s: %s", r.renderedPart.Part.Id(), err.Error())
+					}
+					w.line("(part %s)", r.renderedPart.Part.Id())
+					for _, c := range contours {
+						w.contour(c, d.GCode.passDepths(), d.CurveTolerance)
+					}
+					continue
+				}
+				w.line("(part %s %s)", r.renderedPart.Part.Id(), op)
+				for _, p := range path.SplitPathOnMove(pth) {
+					w.contour(gcodeContour{pth: p}, []float64{d.GCode.EngraveDepth}, d.CurveTolerance)
+				}
+			}
+		}
+	}
+
+	if d.GCode.SpindleSpeed > 0 {
+		w.line("M5")
+	}
+	w.line("G0 X%s Y%s", w.f(0), w.f(0))
+	w.line("M2")
+	return nil
+}
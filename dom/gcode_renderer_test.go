@@ -0,0 +1,68 @@
+package dom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+func TestGCodePassDepths(t *testing.T) {
+	s := GCodeSettings{CutDepth: .5, PassDepth: .2}
+	depths := s.passDepths()
+	if len(depths) != 3 || depths[2] != .5 {
+		t.Errorf("Expected 3 passes ending at .5, got %v", depths)
+	}
+}
+
+func TestWriteGCode(t *testing.T) {
+	doc := NewSVGDocument(10, 10, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	doc.GCode = GCodeSettings{
+		ToolDiameter: .2,
+		CutDepth:     .25,
+		PassDepth:    .125,
+		FeedRate:     40,
+		PlungeRate:   10,
+		SafeHeight:   .2,
+	}
+	// a 4x4 square with a 2x2 square hole
+	_, err := doc.Add(testRenderedPart("plate", "M 0 0 L 4 0 L 4 4 L 0 4 L 0 0 M 1 1 L 3 1 L 3 3 L 1 3 L 1 1"), RenderContext{})
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	contours, err := doc.gcodeContours(doc.renderables[0].renderedPart.Path, doc.GCode)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	if len(contours) != 2 {
+		t.Fatalf("Expected 2 contours, got %d", len(contours))
+	}
+	if contours[0].depth != 1 {
+		t.Errorf("Expected the hole to be cut first")
+	}
+	for i, expected := range []float64{1.8, 4.2} {
+		// the offset should add or remove the tool radius on each side
+		ctl, cbr, err := path.BoundingBoxTrimWhitespace(contours[i].pth, doc.SegmentOperators)
+		if err != nil {
+			t.Errorf("Error %s", err)
+		}
+		if w := cbr.X - ctl.X; w < expected-.001 || w > expected+.001 {
+			t.Errorf("Expected contour %d to be %.3f wide, got %.3f", i, expected, w)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err = doc.WriteGCode(RenderContext{}, buf)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	gcode := buf.String()
+	if strings.Count(gcode, "G1 Z-0.125") != 2 || strings.Count(gcode, "G1 Z-0.250") != 2 {
+		t.Errorf("Expected 2 passes for each contour\n%s", gcode)
+	}
+	if !strings.HasPrefix(strings.Split(gcode, "\n")[2], "G20") {
+		t.Errorf("Expected inches\n%s", gcode)
+	}
+}
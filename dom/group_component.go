@@ -52,6 +52,9 @@ func (cc *GroupComponent) Render(ctx RenderContext) (path.Path, RenderContext, e
 
 	context := ctx.Clone()
 	for _, e := range cc.components {
+		if e.Hidden() {
+			continue
+		}
 		p, c, err := e.Render(context)
 		if err != nil {
 			return p, c, err
@@ -0,0 +1,141 @@
+package dom
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+)
+
+// returns all the files the document depends on, starting with the
+// document itself.  This follows the same references that ParseDocument
+// does: the filename reference, the component, svg and dxf imports, the
+// material of the document, the materials param and the parts, and the
+// stock file.  params override the document params, the same as the
+// params passed on the command line, nil for none.
+func ImportGraph(filename string, params *dynmap.DynMap) ([]string, error) {
+	files := []string{}
+	err := importGraph(filename, params, map[string]bool{}, &files)
+	return files, err
+}
+
+func importGraph(filename string, overrides *dynmap.DynMap, visited map[string]bool, files *[]string) error {
+	add := func(pth string) bool {
+		if len(pth) == 0 || visited[pth] {
+			return false
+		}
+		visited[pth] = true
+		*files = append(*files, pth)
+		return true
+	}
+	if !add(filename) {
+		return nil
+	}
+	dm, err := loadImportGraphMap(filename)
+	if err != nil {
+		return err
+	}
+	// merge in the referenced files, the same as ParseDocument
+	chain := map[string]bool{filename: true}
+	for refFilename := dm.MustString("filename", ""); len(refFilename) > 0; refFilename = dm.MustString("filename", "") {
+		if chain[refFilename] {
+			return fmt.Errorf("Error, %s references itself through %s", filename, refFilename)
+		}
+		chain[refFilename] = true
+		add(refFilename)
+		d1, err := loadImportGraphMap(refFilename)
+		if err != nil {
+			return err
+		}
+		dm.Remove("filename")
+		for _, imp := range dm.MustDynMapSlice("imports", []*dynmap.DynMap{}) {
+			d1.AddToSlice("imports", imp)
+		}
+		dm.Remove("imports")
+		dm = d1.Merge(dm)
+	}
+
+	for _, importDm := range dm.MustDynMapSlice("imports", []*dynmap.DynMap{}) {
+		pth := importDm.MustString("path", "")
+		if importDm.MustString("type", "component") == "component" {
+			if len(pth) == 0 {
+				continue
+			}
+			err = importGraph(pth, nil, visited, files)
+			if err != nil {
+				return err
+			}
+		} else {
+			add(pth)
+		}
+	}
+
+	params := dm.MustDynMap("params", dynmap.New())
+	if overrides != nil {
+		params.Merge(overrides)
+	}
+	dir, _ := paramValue(params, "material_dir")
+	materialDir := dynmap.MustString(dir, defaultMaterialDir)
+	if v, ok := paramValue(params, "material"); ok && len(dynmap.ToString(v)) > 0 {
+		add(materialFilename(dynmap.ToString(v), materialDir))
+	}
+	if v, ok := paramValue(params, "stock_file"); ok {
+		add(dynmap.ToString(v))
+	}
+
+	// the library material and stock of each material group, see
+	// materialGroupParams
+	materials := dynmap.New()
+	if v, ok := paramValue(params, "materials"); ok {
+		if mp, ok := dynmap.ToDynMap(v); ok {
+			materials = mp
+		}
+	}
+	addGroup := func(name string) {
+		entry := materials.MustDynMap(name, dynmap.New())
+		add(materialFilename(entry.MustString("material", name), entry.MustString("material_dir", materialDir)))
+		add(entry.MustString("stock_file", ""))
+	}
+	names := []string{}
+	for name := range materials.Map {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addGroup(name)
+	}
+	for _, name := range partMaterials(dm.MustDynMapSlice("components", []*dynmap.DynMap{})) {
+		addGroup(name)
+	}
+	return nil
+}
+
+func loadImportGraphMap(filename string) (*dynmap.DynMap, error) {
+	b, err := AppContext().FileLoader().LoadBytes(filename)
+	if err != nil {
+		return nil, err
+	}
+	dm, err := ParseToHFDMap(string(b), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", filename, err.Error())
+	}
+	return dm, nil
+}
+
+// the materials named by the parts in the components, in order
+func partMaterials(components []*dynmap.DynMap) []string {
+	names := []string{}
+	for _, c := range components {
+		if c.MustString("type", "") == "part" {
+			material, ok := paramValue(c.MustDynMap("params", dynmap.New()), "material")
+			if !ok {
+				material, ok = c.Get("material")
+			}
+			if ok && len(dynmap.ToString(material)) > 0 {
+				names = append(names, dynmap.ToString(material))
+			}
+		}
+		names = append(names, partMaterials(c.MustDynMapSlice("components", []*dynmap.DynMap{}))...)
+	}
+	return names
+}
@@ -0,0 +1,81 @@
+package dom
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+)
+
+type mapFileLoader map[string]string
+
+func (m mapFileLoader) LoadBytes(filename string) ([]byte, error) {
+	s, ok := m[filename]
+	if !ok {
+		return nil, fmt.Errorf("file not found %s", filename)
+	}
+	return []byte(s), nil
+}
+
+func TestImportGraph(t *testing.T) {
+	previous := AppContext().FileLoader()
+	defer AppContext().SetFileLoader(previous)
+	AppContext().SetFileLoader(mapFileLoader{
+		"gus.hfd": `{"filename": "box.hfd", "imports": [{"path": "handle.hfd"}]}`,
+		"box.hfd": `{"imports": [
+			{"path": "handle.hfd"},
+			{"path": "logo.svg", "type": "svg", "alias": "logo"}
+		]}`,
+		"handle.hfd": `{"imports": [{"path": "box.hfd"}]}`,
+	})
+
+	files, err := ImportGraph("gus.hfd", nil)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	expected := []string{"gus.hfd", "box.hfd", "handle.hfd", "logo.svg"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected: %v\nActual: %v", expected, files)
+	}
+}
+
+func TestImportGraphMaterials(t *testing.T) {
+	previous := AppContext().FileLoader()
+	defer AppContext().SetFileLoader(previous)
+	AppContext().SetFileLoader(mapFileLoader{
+		"shelf.hfd": `{"filename": "base.hfd", "params": {
+			"material_dir": "shop",
+			"materials": {"back": {"material": "hardboard"}, "door": {}}
+		}}`,
+		"base.hfd": `{"params": {"material": "ply", "stock_file": "stock.json"},
+			"components": [
+				{"type": "part", "material": "acrylic"},
+				{"type": "part", "params": {"material": "back"}}
+			]}`,
+	})
+
+	files, err := ImportGraph("shelf.hfd", nil)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	expected := []string{"shelf.hfd", "base.hfd", "shop/ply.json", "stock.json",
+		"shop/hardboard.json", "shop/door.json", "shop/acrylic.json"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected: %v\nActual: %v", expected, files)
+	}
+
+	// the command line params override the document
+	params := dynmap.New()
+	params.Put("material", "oak")
+	params.Put("stock_file", "shop_stock.json")
+	files, err = ImportGraph("base.hfd", params)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	expected = []string{"base.hfd", "materials/oak.json", "shop_stock.json",
+		"materials/acrylic.json", "materials/back.json"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected: %v\nActual: %v", expected, files)
+	}
+}
@@ -0,0 +1,298 @@
+package dom
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/dustismo/heavyfishdesign/binpacking"
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+// Keep-out areas and pinned parts.
+// The keep_out document param lists the areas of the sheets that parts can
+// not be placed on, like a clamp or a damaged corner.  Each is a rectangle
+// or an svg path, on every sheet or only on the sheet with the given index:
+//
+//	"keep_out": [
+//		{"x": 0, "y": 0, "width": 20, "height": 1},
+//		{"path": "M 18 10 L 20 10 L 20 12 L 18 10", "sheet": 0}
+//	]
+//
+// Parts with a layout.position are pinned, the top left of the part is
+// placed at that position on the sheet with the index layout.sheet
+// (default 0).  Keep-out areas and pinned parts are placed on each sheet
+// before anything else, the other parts are packed around them.  The
+// rectangle packers keep out of the bounding box of a path, nesting keeps
+// out of the path itself.
+
+// an area of the sheet that parts can not be placed on
+type KeepOut struct {
+	// the index of the sheet, or -1 for every sheet
+	Sheet int
+	Path  path.Path
+}
+
+func NewKeepOut(dm *dynmap.DynMap) (*KeepOut, error) {
+	k := &KeepOut{
+		Sheet: dm.MustInt("sheet", -1),
+	}
+	svg, ok := dm.GetString("path")
+	if !ok {
+		x, okX := dm.GetFloat64("x")
+		y, okY := dm.GetFloat64("y")
+		w, okW := dm.GetFloat64("width")
+		h, okH := dm.GetFloat64("height")
+		if !okX || !okY || !okW || !okH || w <= 0 || h <= 0 {
+			return nil, fmt.Errorf("Keep out needs a path or an x, y, width and height: %s", dm.ToJSON())
+		}
+		svg = fmt.Sprintf("M %g %g L %g %g L %g %g L %g %g L %g %g", x, y, x+w, y, x+w, y+h, x, y+h, x, y)
+	}
+	pth, err := path.ParsePathFromSvg(svg)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse keep out path %s: %s", svg, err.Error())
+	}
+	if len(pth.Segments()) == 0 {
+		return nil, fmt.Errorf("Keep out path is empty: %s", dm.ToJSON())
+	}
+	k.Path = pth
+	return k, nil
+}
+
+// parses the list of keep out areas
+func ParseKeepOuts(dms []*dynmap.DynMap) ([]*KeepOut, error) {
+	keepOuts := []*KeepOut{}
+	for _, dm := range dms {
+		k, err := NewKeepOut(dm)
+		if err != nil {
+			return nil, err
+		}
+		keepOuts = append(keepOuts, k)
+	}
+	return keepOuts, nil
+}
+
+// true if the area is kept out of the sheet with the index
+func (k *KeepOut) OnSheet(index int) bool {
+	return k.Sheet < 0 || k.Sheet == index
+}
+
+// the outlines of the area.  Each closed polyline is an outline, anything
+// else is replaced by its bounding box
+func (k *KeepOut) polygons(tolerance float64) [][]path.Point {
+	polygons := [][]path.Point{}
+	for _, polyline := range path.FlattenPath(k.Path, tolerance) {
+		if len(polyline) < 2 {
+			continue
+		}
+		first, last := polyline[0], polyline[len(polyline)-1]
+		if len(polyline) > 3 && path.Distance(first, last) <= tolerance {
+			polygons = append(polygons, polyline[:len(polyline)-1])
+			continue
+		}
+		min, max := polygonBounds(polyline)
+		polygons = append(polygons, []path.Point{
+			min,
+			path.NewPoint(max.X, min.Y),
+			max,
+			path.NewPoint(min.X, max.Y),
+		})
+	}
+	return polygons
+}
+
+// a part at a fixed position
+type Pin struct {
+	// the index of the sheet
+	Sheet int
+	// the top left of the part on the sheet
+	Position path.Point
+}
+
+// the pin from the part's layout.position and layout.sheet, nil if the
+// part does not have a position
+func layoutPin(part *Part) *Pin {
+	attr := part.Attr()
+	position, ok := attr.Point("layout.position")
+	if !ok {
+		return nil
+	}
+	return &Pin{
+		Sheet:    attr.MustInt("layout.sheet", 0),
+		Position: position,
+	}
+}
+
+// the area used by the pinned part, including the padding
+func pinnedBounds(p *RenderedPart, padding float64) (path.Point, path.Point) {
+	w, h := p.Width, p.Height
+	if p.Rotation == binpacking.Rotate90 {
+		w, h = h, w
+	}
+	pos := p.Pin.Position
+	return path.NewPoint(pos.X-padding, pos.Y-padding),
+		path.NewPoint(pos.X+w+padding, pos.Y+h+padding)
+}
+
+// true if the two rectangles overlap, they can touch
+func rectsOverlap(aMin, aMax, bMin, bMax path.Point) bool {
+	return aMin.X < bMax.X && bMin.X < aMax.X &&
+		aMin.Y < bMax.Y && bMin.Y < aMax.Y
+}
+
+// keeps the parts out of the area
+func (d *SVGDocument) AddKeepOut(k *KeepOut) {
+	d.KeepOuts = append(d.KeepOuts, k)
+	for _, polygon := range k.polygons(d.CurveTolerance) {
+		if d.Nest {
+			s := &nestShape{
+				outer: orientPolygon(polygon, true),
+			}
+			s.min, s.max = polygonBounds(polygon)
+			s.inside = s.insidePoint()
+			d.nested = append(d.nested, s)
+			continue
+		}
+		min, max := polygonBounds(polygon)
+		d.layoutContainer.Reserve(k, min.X, min.Y, max.X-min.X, max.Y-min.Y)
+	}
+}
+
+// adds the part at its pinned position, the part is rotated only if its
+// layout rotation is 90
+func (d *SVGDocument) AddPinned(p *RenderedPart) {
+	r := &docRenderable{
+		renderedPart:     p,
+		position:         p.Pin.Position,
+		segmentOperators: d.SegmentOperators,
+	}
+	if d.Nest {
+		if p.Rotation == binpacking.Rotate90 {
+			r.angle = 90
+		}
+		s := d.nestShape(p, r.angle)
+		// the top left of the rotated part
+		min := path.NewPoint(math.Inf(1), math.Inf(1))
+		for _, c := range []path.Point{
+			path.NewPoint(0, 0),
+			path.NewPoint(p.Width, 0),
+			path.NewPoint(p.Width, p.Height),
+			path.NewPoint(0, p.Height),
+		} {
+			c = path.Rotate(r.angle, c)
+			min = path.NewPoint(math.Min(min.X, c.X), math.Min(min.Y, c.Y))
+		}
+		placed := s.translate(path.NewPoint(
+			p.Pin.Position.X-min.X-s.offset.X,
+			p.Pin.Position.Y-min.Y-s.offset.Y))
+		d.nested = append(d.nested, placed)
+		r.position = placed.offset
+	} else {
+		r.rotate = p.Rotation == binpacking.Rotate90
+		min, max := pinnedBounds(p, d.Padding)
+		d.layoutContainer.Reserve(r, min.X, min.Y, max.X-min.X, max.Y-min.Y)
+	}
+	d.renderables = append(d.renderables, r)
+}
+
+// draws the keep out areas as a guide, used for previews
+func (d *SVGDocument) writeKeepOuts(writer io.Writer) {
+	if !d.ShowKeepOuts || len(d.KeepOuts) == 0 {
+		return
+	}
+	d.writeSVG(writer, "<g id=\"keep_out\">")
+	for _, k := range d.KeepOuts {
+		d.writeSVG(writer, fmt.Sprintf("<path d=\"%s\" style=\"%s\" />",
+			path.SvgString(k.Path, d.Precision),
+			fmt.Sprintf("fill:#ff000022;stroke:#ff0000;stroke-width:%.3f;stroke-dasharray:%.3f", d.Units.FromMM(.3), d.Units.FromMM(2))))
+	}
+	d.writeSVG(writer, "</g>")
+}
+
+// loads the keep out areas from the keep_out param
+func (p *PlanSet) loadKeepOuts() ([]*KeepOut, error) {
+	return ParseKeepOuts(p.doc.Params().MustDynMapSlice("keep_out", []*dynmap.DynMap{}))
+}
+
+// places the keep out areas and pinned parts for the sheet with the index
+func (p *PlanSet) prepareSheet(d *SVGDocument, index int) {
+	for _, k := range p.keepOuts {
+		if k.OnSheet(index) {
+			d.AddKeepOut(k)
+		}
+	}
+	for _, part := range p.pinned {
+		if part.Pin.Sheet == index {
+			d.AddPinned(part)
+		}
+	}
+}
+
+// the last sheet with a pinned part, -1 if there are none
+func (p *PlanSet) lastPinnedSheet() int {
+	last := -1
+	for _, part := range p.pinned {
+		if part.Pin.Sheet > last {
+			last = part.Pin.Sheet
+		}
+	}
+	return last
+}
+
+// true if the rectangle and the polygon overlap, they can touch
+func rectOverlapsPolygon(min, max path.Point, polygon []path.Point) bool {
+	pMin, pMax := polygonBounds(polygon)
+	if !rectsOverlap(min, max, pMin, pMax) {
+		return false
+	}
+	rect := []path.Point{min, path.NewPoint(max.X, min.Y), max, path.NewPoint(min.X, max.Y)}
+	if polygonsCross(rect, polygon) {
+		return true
+	}
+	center := path.NewPoint((min.X+max.X)/2, (min.Y+max.Y)/2)
+	for _, p := range polygon {
+		if path.PointInPolygon(p, rect) > 0 {
+			return true
+		}
+	}
+	return path.PointInPolygon(center, polygon) > 0
+}
+
+// logs pinned parts that are off the sheet or overlap each other or a
+// keep out area.  These are still placed where they are pinned, except a
+// part pinned to a negative sheet is placed on sheet 0
+func (p *PlanSet) checkPins(log *util.HfdLog) {
+	attr := p.attr()
+	width := attr.MustFloat64("material_width", 20)
+	height := attr.MustFloat64("material_height", 12)
+	units := MustUnits(attr.MustString("measurement_units", "in"), Inches)
+	tolerance := attr.MustFloat64("curve_tolerance", units.FromMM(.025))
+	for i, part := range p.pinned {
+		min, max := pinnedBounds(part, 0)
+		if part.Pin.Sheet < 0 {
+			log.Errorf("Part %s is pinned to sheet %d, sheets start at 0", part.Part.Id(), part.Pin.Sheet)
+			part.Pin.Sheet = 0
+		}
+		if !p.UsesStock() && (min.X < 0 || min.Y < 0 || max.X > width || max.Y > height) {
+			log.Errorf("Part %s is pinned outside of the sheet", part.Part.Id())
+		}
+		for _, other := range p.pinned[:i] {
+			oMin, oMax := pinnedBounds(other, 0)
+			if other.Pin.Sheet == part.Pin.Sheet && rectsOverlap(min, max, oMin, oMax) {
+				log.Errorf("Pinned parts %s and %s overlap", other.Part.Id(), part.Part.Id())
+			}
+		}
+		for _, k := range p.keepOuts {
+			if !k.OnSheet(part.Pin.Sheet) {
+				continue
+			}
+			for _, polygon := range k.polygons(tolerance) {
+				if rectOverlapsPolygon(min, max, polygon) {
+					log.Errorf("Pinned part %s overlaps a keep out area", part.Part.Id())
+				}
+			}
+		}
+	}
+}
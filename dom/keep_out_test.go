@@ -0,0 +1,109 @@
+package dom
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+func TestParseKeepOuts(t *testing.T) {
+	dm, _ := dynmap.ParseJSON(`{"keep_out": [
+		{"x": 0, "y": 0, "width": 2, "height": 1},
+		{"path": "M 0 0 L 1 1 L 0 1 L 0 0", "sheet": 1},
+		{"x": 1}
+	]}`)
+	dms := dm.MustDynMapSlice("keep_out", nil)
+	_, err := ParseKeepOuts(dms)
+	if err == nil {
+		t.Errorf("Expected error for a keep out without a size")
+	}
+	keepOuts, err := ParseKeepOuts(dms[:2])
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if !keepOuts[0].OnSheet(3) || keepOuts[1].OnSheet(0) || !keepOuts[1].OnSheet(1) {
+		t.Errorf("Unexpected keep out sheets")
+	}
+}
+
+func TestKeepOutLayout(t *testing.T) {
+	for _, strategy := range []string{"guillotine", "maxrects_bssf", "skyline", "nest"} {
+		doc, err := ParseDocumentFromJson(fmt.Sprintf(`{
+			"params": {
+				"doc_padding": 0,
+				"material_width": 10,
+				"material_height": 10,
+				"layout_strategy": "%s",
+				"keep_out": [{"x": 0, "y": 0, "width": 10, "height": 2}]
+			}
+		}`, strategy), util.NewLog())
+		if err != nil {
+			t.Fatalf("Error %s", err)
+		}
+		p := NewPlanSet(doc)
+		p.keepOuts, err = p.loadKeepOuts()
+		if err != nil {
+			t.Fatalf("Error %s", err)
+		}
+		pinned := testRenderedPart("pinned", "M 0 0 L 4 0 L 4 4 L 0 4 L 0 0")
+		pinned.Pin = &Pin{Sheet: 0, Position: path.NewPoint(6, 6)}
+		other := testRenderedPart("other", "M 0 0 L 1 0 L 1 1 L 0 1 L 0 0")
+		other.Pin = &Pin{Sheet: 1, Position: path.NewPoint(5, 5)}
+		p.pinned = []*RenderedPart{pinned, other}
+
+		err = p.layout(RenderContext{}, testSquares(3))
+		if err != nil {
+			t.Fatalf("%s: Error %s", strategy, err)
+		}
+		if len(p.svgDocs) != 2 {
+			t.Fatalf("%s: expected a second sheet for the pinned part, got %d", strategy, len(p.svgDocs))
+		}
+		d := p.svgDocs[0]
+		if len(d.renderables) != 4 {
+			t.Errorf("%s: expected all the squares on the first sheet, got %d parts", strategy, len(d.renderables))
+		}
+		bounds := [][2]path.Point{}
+		for _, r := range d.renderables {
+			tl, br := testDocumentBounds(t, r)
+			if r.renderedPart == pinned && (!tl.Equals(path.NewPoint(6, 6)) || !br.Equals(path.NewPoint(10, 10))) {
+				t.Errorf("%s: expected the pinned part at 6,6 got %v", strategy, tl)
+			}
+			if tl.Y < 2-1e-6 {
+				t.Errorf("%s: part %s is in the keep out area %v", strategy, r.renderedPart.Part.Id(), tl)
+			}
+			for _, b := range bounds {
+				if rectsOverlap(tl, br, b[0], b[1]) {
+					t.Errorf("%s: part %s overlaps another part", strategy, r.renderedPart.Part.Id())
+				}
+			}
+			bounds = append(bounds, [2]path.Point{tl, br})
+		}
+
+		d.ShowKeepOuts = true
+		buf := &bytes.Buffer{}
+		d.WriteSVG(RenderContext{}, buf)
+		if !strings.Contains(buf.String(), `<g id="keep_out">`) {
+			t.Errorf("%s: expected the keep out guide in the svg", strategy)
+		}
+	}
+}
+
+func TestCheckPinsNegativeSheet(t *testing.T) {
+	doc, err := ParseDocumentFromJson(`{"params": {"material_width": 10, "material_height": 10}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	p := NewPlanSet(doc)
+	pinned := testRenderedPart("pinned", "M 0 0 L 4 0 L 4 4 L 0 4 L 0 0")
+	pinned.Pin = &Pin{Sheet: -2, Position: path.NewPoint(1, 1)}
+	p.pinned = []*RenderedPart{pinned}
+	p.checkPins(util.NewLog())
+	if pinned.Pin.Sheet != 0 || p.lastPinnedSheet() != 0 {
+		t.Errorf("Expected the part moved to sheet 0, got %d", pinned.Pin.Sheet)
+	}
+}
@@ -0,0 +1,179 @@
+package dom
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/dustismo/heavyfishdesign/binpacking"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+// Layout optimization.
+// The parts are packed several times, each time in a different order, and
+// the layout with the least total sheet area (the fewest sheets when they
+// are all the same size) is kept.  The document order is packed first and
+// is only replaced by a layout that needs less, so designs that already fit
+// render the same way they always have.
+// Everything is deterministic (the random orderings use a fixed seed) so
+// the same design always renders the same way.
+
+// seed for the random orderings
+const layoutSeed = 1
+
+// the orderings to try, the document order is always first so it
+// wins any ties
+func layoutOrderings(parts []*RenderedPart, randomPasses int) [][]*RenderedPart {
+	sorted := func(less func(a, b *RenderedPart) bool) []*RenderedPart {
+		ps := append([]*RenderedPart{}, parts...)
+		sort.SliceStable(ps, func(i, j int) bool {
+			return less(ps[i], ps[j])
+		})
+		return ps
+	}
+	orderings := [][]*RenderedPart{
+		parts,
+		// area descending
+		sorted(func(a, b *RenderedPart) bool {
+			return a.Width*a.Height > b.Width*b.Height
+		}),
+		// longest side descending
+		sorted(func(a, b *RenderedPart) bool {
+			return math.Max(a.Width, a.Height) > math.Max(b.Width, b.Height)
+		}),
+		// height descending
+		sorted(func(a, b *RenderedPart) bool {
+			return a.Height > b.Height
+		}),
+	}
+	r := rand.New(rand.NewSource(layoutSeed))
+	for i := 0; i < randomPasses; i++ {
+		ps := append([]*RenderedPart{}, parts...)
+		r.Shuffle(len(ps), func(i, j int) {
+			ps[i], ps[j] = ps[j], ps[i]
+		})
+		orderings = append(orderings, ps)
+	}
+	return orderings
+}
+
+// the total area of all the sheets
+func layoutArea(docs []*SVGDocument) float64 {
+	area := 0.0
+	for _, d := range docs {
+		area += d.Width * d.Height
+	}
+	return area
+}
+
+// true if layout a uses less sheet area than b, or the same area on fewer
+// sheets
+func betterLayout(a, b []*SVGDocument) bool {
+	if layoutArea(a) != layoutArea(b) {
+		return layoutArea(a) < layoutArea(b)
+	}
+	return len(a) < len(b)
+}
+
+// Layout constraints.
+// By default a part is rotated 90 degrees whenever that fits better.  Parts
+// can limit this with the layout.rotation attribute (free, none or 90), or
+// with layout.grain (horizontal or vertical), the direction the grain has to
+// run on the part as it is drawn.  The grain of the sheet is the
+// material_grain document param (none, horizontal or vertical), and a part
+// with a grain is rotated only when its grain differs from the sheet's.
+
+// the direction of the wood grain
+type Grain int32
+
+const (
+	NoGrain         Grain = 0
+	HorizontalGrain Grain = 1
+	VerticalGrain   Grain = 2
+)
+
+var grainNames = map[string]Grain{
+	"none":       NoGrain,
+	"horizontal": HorizontalGrain,
+	"vertical":   VerticalGrain,
+}
+
+func NewGrain(in string) (Grain, bool) {
+	g, ok := grainNames[in]
+	return g, ok
+}
+
+func MustGrain(in string, defaultGrain Grain) Grain {
+	g, ok := NewGrain(in)
+	if !ok {
+		return defaultGrain
+	}
+	return g
+}
+
+// the grain of the sheets, from the material_grain param
+func materialGrain(attr *Attr, log *util.HfdLog) Grain {
+	name := attr.MustString("material_grain", "none")
+	grain, ok := NewGrain(name)
+	if !ok {
+		log.Errorf("Unknown material_grain %s, expected none, horizontal or vertical", name)
+	}
+	return grain
+}
+
+// how the part may be rotated on a sheet with the given grain.  Unknown
+// values and conflicts between layout.rotation and layout.grain are logged,
+// the grain wins any conflict.
+func layoutRotation(part *Part, sheetGrain Grain, log *util.HfdLog) binpacking.Rotation {
+	attr := part.Attr()
+	rotationName := attr.MustString("layout.rotation", "free")
+	rotation, ok := binpacking.NewRotation(rotationName)
+	if !ok {
+		log.Errorf("Unknown layout.rotation %s for part %s, expected free, none or 90", rotationName, part.Id())
+	}
+	grainName := attr.MustString("layout.grain", "none")
+	grain, ok := NewGrain(grainName)
+	if !ok {
+		log.Errorf("Unknown layout.grain %s for part %s, expected horizontal or vertical", grainName, part.Id())
+	}
+	if grain == NoGrain {
+		return rotation
+	}
+	if sheetGrain == NoGrain {
+		log.Infof("Part %s has a %s grain but the material_grain param is not set, ignoring it", part.Id(), grainName)
+		return rotation
+	}
+	grainRotation := binpacking.RotateNone
+	if grain != sheetGrain {
+		grainRotation = binpacking.Rotate90
+	}
+	if rotation != binpacking.RotateFree && rotation != grainRotation {
+		log.Errorf("Part %s has layout.rotation %s but its %s grain needs rotation %s on the sheet, using %s",
+			part.Id(), rotation, grainName, grainRotation, grainRotation)
+	}
+	return grainRotation
+}
+
+// true if the layout rotation of the part comes from its grain, see
+// layoutRotation
+func layoutGrainRotation(part *Part, sheetGrain Grain) bool {
+	grain, _ := NewGrain(part.Attr().MustString("layout.grain", "none"))
+	return grain != NoGrain && sheetGrain != NoGrain
+}
+
+// logs the oversized parts that would have fit if they were allowed to rotate
+func logRotationViolations(docs []*SVGDocument, log *util.HfdLog) {
+	for _, d := range docs {
+		if !d.oversized || len(d.renderables) == 0 {
+			continue
+		}
+		p := d.renderables[0].renderedPart
+		w, h := p.Width+2*d.Padding, p.Height+2*d.Padding
+		fitsUnrotated := w <= d.Width && h <= d.Height
+		fitsRotated := h <= d.Width && w <= d.Height
+		if (p.Rotation == binpacking.RotateNone && fitsRotated) ||
+			(p.Rotation == binpacking.Rotate90 && fitsUnrotated) {
+			log.Errorf("Part %s does not fit on the sheet with layout rotation %s, it would fit with the other rotation", p.Part.Id(), p.Rotation)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package dom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/binpacking"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+func TestLayoutOrderings(t *testing.T) {
+	parts := []*RenderedPart{
+		testRenderedPart("small", "M 0 0 L 1 0 L 1 1"),
+		testRenderedPart("tall", "M 0 0 L 1 0 L 1 4"),
+		testRenderedPart("big", "M 0 0 L 3 0 L 3 3"),
+	}
+	orderings := layoutOrderings(parts, 3)
+	if len(orderings) != 7 {
+		t.Fatalf("Expected 7 orderings, got %d", len(orderings))
+	}
+	ids := func(ps []*RenderedPart) string {
+		s := ""
+		for _, p := range ps {
+			s += p.Part.Id() + " "
+		}
+		return s
+	}
+	expected := []string{
+		"small tall big ",
+		"big tall small ",
+		"tall big small ",
+		"tall big small ",
+	}
+	for i, e := range expected {
+		if ids(orderings[i]) != e {
+			t.Errorf("Ordering %d expected: %s\nActual: %s", i, e, ids(orderings[i]))
+		}
+	}
+	// the random orderings are the same every time
+	again := layoutOrderings(parts, 3)
+	for i := range orderings {
+		if ids(orderings[i]) != ids(again[i]) {
+			t.Errorf("Expected ordering %d to be deterministic", i)
+		}
+	}
+}
+
+func TestBetterLayout(t *testing.T) {
+	newDoc := func(parts ...*RenderedPart) *SVGDocument {
+		doc := NewSVGDocument(4, 4, Inches)
+		doc.SegmentOperators = AppContext().SegmentOperators()
+		doc.Padding = 0
+		for _, p := range parts {
+			doc.Add(p, RenderContext{})
+		}
+		return doc
+	}
+	big := testRenderedPart("big", "M 0 0 L 3 0 L 3 3")
+	small := testRenderedPart("small", "M 0 0 L 1 0 L 1 1")
+
+	one := []*SVGDocument{newDoc(big, small)}
+	two := []*SVGDocument{newDoc(big), newDoc(small)}
+	if !betterLayout(one, two) || betterLayout(two, one) {
+		t.Errorf("Expected fewer sheets to be better")
+	}
+	// same sheets, the earlier ordering is kept
+	fullFirst := []*SVGDocument{newDoc(big, small), newDoc(small)}
+	emptyFirst := []*SVGDocument{newDoc(small), newDoc(big, small)}
+	if betterLayout(fullFirst, emptyFirst) || betterLayout(emptyFirst, fullFirst) {
+		t.Errorf("Expected layouts on the same sheets to tie")
+	}
+}
+
+func TestLayoutRotation(t *testing.T) {
+	part := func(layout string) *Part {
+		doc, err := ParseDocumentFromJson(fmt.Sprintf(`{"parts": [{"id": "panel", "layout": %s}]}`, layout), util.NewLog())
+		if err != nil {
+			t.Fatalf("Error %s", err)
+		}
+		return doc.Parts[0]
+	}
+	log := util.NewLog()
+	log.LogToStdOut = util.Fatal
+	vertical := part(`{"grain": "vertical"}`)
+	if r := layoutRotation(vertical, HorizontalGrain, log); r != binpacking.Rotate90 {
+		t.Errorf("Expected the part to be rotated to match the grain, got %s", r)
+	}
+	if r := layoutRotation(vertical, VerticalGrain, log); r != binpacking.RotateNone {
+		t.Errorf("Expected the part not to be rotated, got %s", r)
+	}
+	if r := layoutRotation(vertical, NoGrain, log); r != binpacking.RotateFree {
+		t.Errorf("Expected the grain to be ignored without a material_grain, got %s", r)
+	}
+	if log.HasErrors() {
+		t.Errorf("Expected no errors %v", log.Messages)
+	}
+	conflict := part(`{"grain": "vertical", "rotation": "none"}`)
+	if r := layoutRotation(conflict, HorizontalGrain, log); r != binpacking.Rotate90 || !log.HasErrors() {
+		t.Errorf("Expected the grain to win and the conflict to be logged, got %s", r)
+	}
+}
+
+func TestLayoutRotationConstraint(t *testing.T) {
+	tall := testRenderedPart("tall", "M 0 0 L 2 0 L 2 6 L 0 6 L 0 0")
+	tall.Rotation = binpacking.RotateNone
+	for _, nest := range []bool{false, true} {
+		doc := NewSVGDocument(6, 4, Inches)
+		doc.SegmentOperators = AppContext().SegmentOperators()
+		doc.Padding = 0
+		doc.Nest = nest
+		doc.Add(testRenderedPart("square", "M 0 0 L 1 0 L 1 1 L 0 1 L 0 0"), RenderContext{})
+		added, err := doc.Add(tall, RenderContext{})
+		if err != nil || added {
+			t.Errorf("nest %v: expected the part not to be rotated to fit (%v)", nest, err)
+		}
+
+		wide := testRenderedPart("wide", "M 0 0 L 3 0 L 3 2 L 0 2 L 0 0")
+		wide.Rotation = binpacking.Rotate90
+		added, err = doc.Add(wide, RenderContext{})
+		r := doc.renderables[len(doc.renderables)-1]
+		if err != nil || !added || !(r.rotate || r.angle == 90 || r.angle == 270) {
+			t.Errorf("nest %v: expected the part to be rotated (%v)", nest, err)
+		}
+	}
+}
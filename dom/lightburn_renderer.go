@@ -0,0 +1,176 @@
+package dom
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// LightBurn project (.lbrn2) output.
+// Each document is written as its own project.  The project has a cut
+// setting (layer) for each laser operation, so the file is ready to run
+// without reassigning paths.  LightBurn works in mm with the y axis up.
+
+// laser speed and power for a single operation
+type LaserSettings struct {
+	// speed in mm/sec
+	Speed float64
+	// power in percent 0 - 100
+	Power float64
+}
+
+func NewLaserSettings() map[Operation]LaserSettings {
+	return map[Operation]LaserSettings{
+		Cut:     {Speed: 20, Power: 60},
+		Score:   {Speed: 100, Power: 20},
+		Engrave: {Speed: 300, Power: 20},
+	}
+}
+
+// reads the laser settings from the document params, for instance
+// cut_speed and cut_power
+func laserSettingsFromAttr(attr *Attr) map[Operation]LaserSettings {
+	settings := NewLaserSettings()
+	for _, op := range Operations {
+		s := settings[op]
+		settings[op] = LaserSettings{
+			Speed: attr.MustFloat64(string(op)+"_speed", s.Speed),
+			Power: attr.MustFloat64(string(op)+"_power", s.Power),
+		}
+	}
+	return settings
+}
+
+func lightburnCutIndex(operation Operation) int {
+	for i, op := range Operations {
+		if op == operation {
+			return i
+		}
+	}
+	return 0
+}
+
+type lightburnVertex struct {
+	p path.Point
+	// control point leaving the vertex
+	c0 *path.Point
+	// control point entering the vertex
+	c1 *path.Point
+}
+
+type lightburnWriter struct {
+	writer    io.Writer
+	precision int
+	// document units to mm
+	scale float64
+	// document height, lightburn y axis is inverted from svg
+	height float64
+}
+
+func (w *lightburnWriter) f(v float64) string {
+	return fmt.Sprintf("%.*f", w.precision, v)
+}
+
+func (w *lightburnWriter) x(p path.Point) float64 {
+	return p.X * w.scale
+}
+
+func (w *lightburnWriter) y(p path.Point) float64 {
+	return (w.height - p.Y) * w.scale
+}
+
+func (w *lightburnWriter) cutSetting(index int, name Operation, s LaserSettings) {
+	cutType := "Cut"
+	if name == Engrave {
+		cutType = "Scan"
+	}
+	fmt.Fprintf(w.writer, "    <CutSetting type=\"%s\">\n", cutType)
+	fmt.Fprintf(w.writer, "        <index Value=\"%d\"/>\n", index)
+	fmt.Fprintf(w.writer, "        <name Value=\"%s\"/>\n", name)
+	fmt.Fprintf(w.writer, "        <minPower Value=\"%s\"/>\n", w.f(s.Power))
+	fmt.Fprintf(w.writer, "        <maxPower Value=\"%s\"/>\n", w.f(s.Power))
+	fmt.Fprintf(w.writer, "        <maxPower2 Value=\"%s\"/>\n", w.f(s.Power))
+	fmt.Fprintf(w.writer, "        <speed Value=\"%s\"/>\n", w.f(s.Speed))
+	fmt.Fprintf(w.writer, "        <priority Value=\"%d\"/>\n", index)
+	fmt.Fprintf(w.writer, "    </CutSetting>\n")
+}
+
+// writes a single continuous section of a path as a shape
+func (w *lightburnWriter) shape(cutIndex int, segments []path.Segment) {
+	if len(segments) == 0 {
+		return
+	}
+	vertices := []*lightburnVertex{{p: segments[0].Start()}}
+	prims := []string{}
+	for _, seg := range segments {
+		start := vertices[len(vertices)-1]
+		end := &lightburnVertex{p: seg.End()}
+		primType := "L"
+		if c, ok := seg.(path.CurveSegment); ok {
+			cs := c.ControlPointStart
+			ce := c.ControlPointEnd
+			start.c0 = &cs
+			end.c1 = &ce
+			primType = "B"
+		}
+		vertices = append(vertices, end)
+		prims = append(prims, fmt.Sprintf("%s%d %d", primType, len(vertices)-2, len(vertices)-1))
+	}
+
+	// closed shapes end on the first vertex
+	last := vertices[len(vertices)-1]
+	if len(vertices) > 2 && last.p.EqualsPrecision(vertices[0].p, w.precision) {
+		vertices[0].c1 = last.c1
+		vertices = vertices[:len(vertices)-1]
+		prims[len(prims)-1] = fmt.Sprintf("%s%d 0", prims[len(prims)-1][:1], len(vertices)-1)
+	}
+
+	verts := strings.Builder{}
+	for _, v := range vertices {
+		fmt.Fprintf(&verts, "V%s %s", w.f(w.x(v.p)), w.f(w.y(v.p)))
+		if v.c0 != nil {
+			fmt.Fprintf(&verts, "c0x%sc0y%s", w.f(w.x(*v.c0)), w.f(w.y(*v.c0)))
+		}
+		if v.c1 != nil {
+			fmt.Fprintf(&verts, "c1x%sc1y%s", w.f(w.x(*v.c1)), w.f(w.y(*v.c1)))
+		}
+	}
+	fmt.Fprintf(w.writer, "    <Shape Type=\"Path\" CutIndex=\"%d\">\n", cutIndex)
+	fmt.Fprintf(w.writer, "        <XForm>1 0 0 1 0 0</XForm>\n")
+	fmt.Fprintf(w.writer, "        <VertList>%s</VertList>\n", verts.String())
+	fmt.Fprintf(w.writer, "        <PrimList>%s</PrimList>\n", strings.Join(prims, ""))
+	fmt.Fprintf(w.writer, "    </Shape>\n")
+}
+
+// writes the document as a LightBurn project
+func (d *SVGDocument) WriteLightBurn(ctx RenderContext, writer io.Writer) error {
+	w := &lightburnWriter{
+		writer:    writer,
+		precision: d.Precision,
+		scale:     InchToMM(1) / d.Units.FromInch(1),
+		height:    d.Height,
+	}
+	fmt.Fprintf(writer, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(writer, "<!-- Generated by github.com/dustismo/heavyfishdesign -->\n")
+	fmt.Fprintf(writer, "<LightBurnProject AppVersion=\"1.0.00\" FormatVersion=\"1\" MaterialHeight=\"0\" MirrorX=\"False\" MirrorY=\"False\">\n")
+	for i, op := range Operations {
+		w.cutSetting(i, op, d.Laser[op])
+	}
+
+	for _, r := range d.renderables {
+		for _, op := range r.renderedPart.OperationPaths() {
+			pth, err := r.documentOperationPath(op.Path)
+			if err != nil {
+				return err
+			}
+			cutIndex := lightburnCutIndex(op.Operation)
+			for _, p := range path.SplitPathOnMove(pth) {
+				w.shape(cutIndex, path.ArcsToCurves(path.TrimMove(p.Segments())))
+			}
+		}
+	}
+	fmt.Fprintf(writer, "</LightBurnProject>\n")
+	return nil
+}
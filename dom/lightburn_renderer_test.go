@@ -0,0 +1,58 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestWriteLightBurn(t *testing.T) {
+	doc := NewSVGDocument(10, 10, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	doc.Padding = 0
+	_, err := doc.Add(testRenderedPart("part", "M 0 0 L 2 0 C 3 0 3 1 2 1 L 0 1 L 0 0"), RenderContext{})
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	buf := &bytes.Buffer{}
+	err = doc.WriteLightBurn(RenderContext{}, buf)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+
+	project := struct {
+		CutSettings []struct {
+			Name struct {
+				Value string `xml:"Value,attr"`
+			} `xml:"name"`
+		} `xml:"CutSetting"`
+		Shapes []struct {
+			CutIndex int    `xml:"CutIndex,attr"`
+			VertList string `xml:"VertList"`
+			PrimList string `xml:"PrimList"`
+		} `xml:"Shape"`
+	}{}
+	err = xml.Unmarshal(buf.Bytes(), &project)
+	if err != nil {
+		t.Fatalf("Error %s\n%s", err, buf.String())
+	}
+	if len(project.CutSettings) != 3 {
+		t.Errorf("Expected 3 cut settings, got %d", len(project.CutSettings))
+	}
+	if len(project.Shapes) != 1 {
+		t.Fatalf("Expected 1 shape, got %d", len(project.Shapes))
+	}
+	shape := project.Shapes[0]
+	if project.CutSettings[shape.CutIndex].Name.Value != "cut" {
+		t.Errorf("Expected shape to be on the cut layer")
+	}
+	expected := "L0 1B1 2L2 3L3 0"
+	if shape.PrimList != expected {
+		t.Errorf("Expected: %s\nActual: %s", expected, shape.PrimList)
+	}
+	// y axis is flipped and converted to mm
+	expected = "V0.000 254.000V50.800 254.000c0x76.200c0y254.000V50.800 228.600c1x76.200c1y228.600V0.000 228.600"
+	if len(shape.VertList) < len(expected) || shape.VertList[:len(expected)] != expected {
+		t.Errorf("Expected: %s\nActual: %s", expected, shape.VertList)
+	}
+}
@@ -0,0 +1,207 @@
+package dom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+)
+
+// Material library.
+// Materials are json files in the materials/ directory (or the directory
+// named by the material_dir param), loaded through the FileLoader.  A
+// document selects one with the material param, for instance
+// "material": "lowes_ply_5mm" loads materials/lowes_ply_5mm.json.
+// The material fields are looked up after the document params, so a
+// document can still override any of them, and before the defaults.
+// Sizes are converted from the material units to the document units.
+// Parts can name a different material, see materialGroupParams.
+//
+//	{
+//	    "name": "lowes_ply_5mm",
+//	    "units": "mm",
+//	    "thickness": 5.2,
+//	    "kerf": 0.1,
+//	    "width": 600,
+//	    "height": 300,
+//	    "laser": {"cut": {"speed": 8, "power": 100}},
+//	    "params": {"material_grain": "horizontal"}
+//	}
+
+const defaultMaterialDir = "materials"
+
+// the params each material size field provides
+var materialSizeParams = map[string]string{
+	"thickness": "material_thickness",
+	"kerf":      "offset",
+	"width":     "material_width",
+	"height":    "material_height",
+}
+
+type Material struct {
+	Name  string
+	Units Units
+	// sizes in the material units, by field name
+	Sizes map[string]float64
+	// laser settings for each operation, only the operations listed
+	// in the material file
+	Laser map[Operation]LaserSettings
+	// any other params, used as is
+	Params *dynmap.DynMap
+}
+
+func NewMaterial(name string, dm *dynmap.DynMap) (*Material, error) {
+	unitsStr := dm.MustString("units", "mm")
+	units, ok := NewUnits(unitsStr)
+	if !ok {
+		return nil, fmt.Errorf("Unknown units %s for material %s", unitsStr, name)
+	}
+	m := &Material{
+		Name:   dm.MustString("name", name),
+		Units:  units,
+		Sizes:  map[string]float64{},
+		Laser:  map[Operation]LaserSettings{},
+		Params: dm.MustDynMap("params", dynmap.New()),
+	}
+	for field := range materialSizeParams {
+		if !dm.Contains(field) {
+			continue
+		}
+		v, ok := dm.GetFloat64(field)
+		if !ok || v < 0 {
+			return nil, fmt.Errorf("Material %s %s must be a number that is not negative", name, field)
+		}
+		m.Sizes[field] = v
+	}
+	defaults := NewLaserSettings()
+	for _, op := range Operations {
+		l, ok := dm.GetDynMap("laser." + string(op))
+		if !ok {
+			continue
+		}
+		m.Laser[op] = LaserSettings{
+			Speed: l.MustFloat64("speed", defaults[op].Speed),
+			Power: l.MustFloat64("power", defaults[op].Power),
+		}
+	}
+	return m, nil
+}
+
+// the file the named material is loaded from
+func materialFilename(name, dir string) string {
+	if strings.HasSuffix(name, ".json") {
+		return name
+	}
+	return fmt.Sprintf("%s/%s.json", strings.TrimRight(dir, "/"), name)
+}
+
+// loads the named material from the material directory
+func LoadMaterial(name, dir string) (*Material, error) {
+	filename := materialFilename(name, dir)
+	b, err := AppContext().FileLoader().LoadBytes(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load material %s: %s", name, err.Error())
+	}
+	dm, err := dynmap.ParseJSON(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse material %s: %s", filename, err.Error())
+	}
+	return NewMaterial(name, dm)
+}
+
+// the material as document params, sizes are converted to the
+// requested units
+func (m *Material) ToParams(units Units) *dynmap.DynMap {
+	params := m.Params.Clone()
+	scale := units.FromMM(1) / m.Units.FromMM(1)
+	for field, v := range m.Sizes {
+		params.Put(materialSizeParams[field], v*scale)
+	}
+	for op, l := range m.Laser {
+		params.Put(string(op)+"_speed", l.Speed)
+		params.Put(string(op)+"_power", l.Power)
+	}
+	return params
+}
+
+// the value of a document param, without its metadata
+func paramValue(params *dynmap.DynMap, name string) (interface{}, bool) {
+	v, ok := params.Get(name)
+	if ok && dynmap.IsDynMapConvertable(v) {
+		meta, _ := dynmap.ToDynMap(v)
+		if meta.Contains("value") {
+			return meta.Get("value")
+		}
+	}
+	return v, ok
+}
+
+// loads the material named by the document params, returns the
+// material params in the document units.  Returns nil if the document
+// does not name a material
+func materialParams(params *dynmap.DynMap) (*dynmap.DynMap, error) {
+	v, ok := paramValue(params, "material")
+	if !ok {
+		return nil, nil
+	}
+	name := dynmap.ToString(v)
+	if len(name) == 0 {
+		return nil, nil
+	}
+	dir, _ := paramValue(params, "material_dir")
+	m, err := LoadMaterial(name, dynmap.MustString(dir, defaultMaterialDir))
+	if err != nil {
+		return nil, err
+	}
+	units, _ := paramValue(params, "measurement_units")
+	return m.ToParams(MustUnits(dynmap.MustString(units, "in"), Inches)), nil
+}
+
+// the params for the parts of a material group.  The group can have an
+// entry in the materials param, with its own params and optionally the
+// library material it uses, otherwise the group name is the library
+// material.  Entry params win over the library material, and both win
+// over the document params.  Returns nil for the document material when it
+// has no entry, the document params already include it
+func materialGroupParams(docParams *dynmap.DynMap, name string) (*dynmap.DynMap, error) {
+	if len(name) == 0 {
+		return nil, nil
+	}
+	params := dynmap.New()
+	hasEntry := false
+	if v, ok := paramValue(docParams, "materials"); ok {
+		if materials, ok := dynmap.ToDynMap(v); ok {
+			if entry, ok := materials.GetDynMap(name); ok {
+				params = entry.Clone()
+				hasEntry = true
+			}
+		}
+	}
+	docMaterial, _ := paramValue(docParams, "material")
+	if !hasEntry && name == dynmap.MustString(docMaterial, "") {
+		return nil, nil
+	}
+	units, ok := params.Get("measurement_units")
+	if !ok {
+		units, _ = paramValue(docParams, "measurement_units")
+	}
+	dir, ok := params.Get("material_dir")
+	if !ok {
+		dir, _ = paramValue(docParams, "material_dir")
+	}
+	library, named := params.GetString("material")
+	if !named {
+		library = name
+	}
+	m, err := LoadMaterial(library, dynmap.MustString(dir, defaultMaterialDir))
+	if err != nil && (named || !hasEntry) {
+		return nil, err
+	}
+	if err == nil {
+		for k, v := range m.ToParams(MustUnits(dynmap.MustString(units, "in"), Inches)).Map {
+			params.PutIfAbsent(k, v)
+		}
+	}
+	params.Put("material", name)
+	return params, nil
+}
@@ -0,0 +1,81 @@
+package dom
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+func TestMaterial(t *testing.T) {
+	previous := AppContext().FileLoader()
+	defer AppContext().SetFileLoader(previous)
+	AppContext().SetFileLoader(mapFileLoader{
+		"materials/ply.json": `{
+			"units": "mm",
+			"thickness": 5.08,
+			"kerf": 0.254,
+			"width": 508,
+			"laser": {"cut": {"speed": 8}},
+			"params": {"material_grain": "horizontal"}
+		}`,
+		"shop/acrylic.json": `{"units": "in", "thickness": 0.125}`,
+		"bad.json":          `{"units": "furlongs"}`,
+		"design.hfd":        `{"params": {"material": "ply"}}`,
+	})
+
+	doc, err := ParseDocumentFromJson(`{"params": {
+		"material": "ply",
+		"material_width": 10
+	}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	attr := doc.Attr()
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-9
+	}
+	if v := attr.MustFloat64("material_thickness", 0); !near(v, .2) {
+		t.Errorf("Expected material thickness .2 got %f", v)
+	}
+	if v := attr.MustFloat64("offset", 0); !near(v, .01) {
+		t.Errorf("Expected offset .01 got %f", v)
+	}
+	if v := attr.MustFloat64("material_width", 0); v != 10 {
+		t.Errorf("Expected the document material width to win, got %f", v)
+	}
+	if v := attr.MustFloat64("cut_speed", 0); v != 8 {
+		t.Errorf("Expected cut speed 8 got %f", v)
+	}
+	if v := attr.MustString("material_grain", ""); v != "horizontal" {
+		t.Errorf("Expected horizontal grain got %s", v)
+	}
+	if _, ok := attr.Float64("material_height"); ok {
+		t.Errorf("Expected no material height")
+	}
+
+	doc, err = ParseDocumentFromJson(`{"params": {
+		"material": {"value": "acrylic", "description": "the material"},
+		"material_dir": "shop/",
+		"measurement_units": "mm"
+	}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if v := doc.Attr().MustFloat64("material_thickness", 0); !near(v, 3.175) {
+		t.Errorf("Expected material thickness 3.175 got %f", v)
+	}
+
+	files, err := ImportGraph("design.hfd", nil)
+	if err != nil || !reflect.DeepEqual(files, []string{"design.hfd", "materials/ply.json"}) {
+		t.Errorf("Unexpected import graph %v (%v)", files, err)
+	}
+
+	for _, name := range []string{"missing", "bad.json"} {
+		_, err = ParseDocumentFromJson(`{"params": {"material": "`+name+`"}}`, util.NewLog())
+		if err == nil {
+			t.Errorf("Expected an error for material %s", name)
+		}
+	}
+}
@@ -53,6 +53,9 @@ type Component interface {
 	// Note: this typical calls render, so care should be taken when calling this
 	Measure() (float64, float64, error)
 	Children() []Element
+	// hidden components are not drawn by their parent, they can still be
+	// used by other components, for instance as boolean operands
+	Hidden() bool
 }
 
 type ParamLookerUpper interface {
@@ -146,6 +149,7 @@ type BasicComponent struct {
 	children    []Element
 	ctx         RenderContext
 	rendering   bool
+	hidden      bool
 }
 
 func (b *BasicComponent) Id() string {
@@ -230,7 +234,15 @@ func (b *BasicComponent) RenderContext() (RenderContext, bool) {
 
 // internal method to handle the transforms
 func (b *BasicComponent) HandleTransforms(self Component, pth path.Path, ctx RenderContext) (path.Path, RenderContext, error) {
-	// handle the transforms..
+	return b.applyTransforms(self, pth, ctx, func(t path.PathTransform, p path.Path) (path.Path, error) {
+		return t.PathTransform(p)
+	})
+}
+
+// applies each of the transforms to the rendered path with apply, which
+// returns the transformed path.  This finishes the render, the cursor is
+// moved to the end of the transformed path
+func (b *BasicComponent) applyTransforms(self Component, pth path.Path, ctx RenderContext, apply func(t path.PathTransform, p path.Path) (path.Path, error)) (path.Path, RenderContext, error) {
 	p := pth
 	context := ctx.Clone()
 
@@ -239,7 +251,7 @@ func (b *BasicComponent) HandleTransforms(self Component, pth path.Path, ctx Ren
 		return nil, context, err
 	}
 	for _, t := range transforms {
-		p1, err := t.PathTransform(p)
+		p1, err := apply(t, p)
 		if err != nil {
 			return p, ctx, err
 		}
@@ -261,6 +273,10 @@ func (b *BasicComponent) SetParent(p Element) {
 	b.parent = p
 }
 
+func (b *BasicComponent) Hidden() bool {
+	return b.hidden
+}
+
 func (b *BasicComponent) Children() []Element {
 	return b.children
 }
@@ -0,0 +1,1138 @@
+package dom
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/binpacking"
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// True shape nesting.
+// Instead of packing the bounding box of each part, the cut outline of the
+// part is flattened into a polygon (grown by the document padding) and
+// parts are placed against the outlines of the parts already on the sheet
+// using no-fit polygons.  The no-fit polygon of a part around a placed part
+// is the area the part can not be moved to without overlapping, the part
+// touches the placed part anywhere on its edge.  It is built from the
+// convolution of the two outlines: every edge of the placed outline moved
+// along by a vertex of the part (and the other way round), where the edge
+// direction lies between the edges at the vertex.  The convolution is cut
+// where its edges cross and the pieces with an overlap on only one side are
+// the edges of the no-fit polygon.  Where the part overlaps is found from
+// the winding number of the convolution of the outlines, and by checking
+// the shapes when the part could be in a hole.  The no-fit polygons only
+// depend on the two shapes, so they are kept for all the layout passes.
+// The part can go anywhere on the sheet outside of the no-fit polygons, the
+// topmost (then leftmost) such position is a corner of that area: a corner
+// of the sheet, a vertex of a no-fit polygon, or where the edges of two
+// no-fit polygons (or an edge and the side of the sheet) cross.  These
+// positions are tried top to bottom, left to right and the first one
+// outside all the no-fit polygons wins, so a part slides into a notch that
+// only fits it against two edges.  Closed cuts inside the outline are holes,
+// smaller parts can be nested inside them.
+// Each part is tried at every rotation in NestRotations.
+
+// the layout_strategy that selects nesting
+const NestLayout = "nest"
+
+// the default rotations tried when nesting
+var defaultNestRotations = []float64{0, 90, 180, 270}
+
+// a part outline with holes.
+type nestShape struct {
+	// the outline goes clockwise and the holes counterclockwise
+	outer []path.Point
+	holes [][]path.Point
+	// a point inside the shape
+	inside path.Point
+	min    path.Point
+	max    path.Point
+	// the translation from the rotated part coordinates to the
+	// shape coordinates
+	offset path.Point
+	// the shape this was moved from, nil if it was not moved
+	source *nestShape
+}
+
+type nestKey struct {
+	part  *RenderedPart
+	angle float64
+}
+
+type noFitKey struct {
+	placed *nestShape
+	shape  *nestShape
+}
+
+// the shapes for each part and rotation and the no-fit polygons between
+// them, shared between all the documents of a plan set since every layout
+// pass nests the same parts.  Parts with the same outline share a shape,
+// so they share the no-fit polygons too
+type nestShapes struct {
+	shapes   map[nestKey]*nestShape
+	outlines map[string]*nestShape
+	noFit    map[noFitKey]*noFitPolygon
+}
+
+func newNestShapes() *nestShapes {
+	return &nestShapes{
+		shapes:   map[nestKey]*nestShape{},
+		outlines: map[string]*nestShape{},
+		noFit:    map[noFitKey]*noFitPolygon{},
+	}
+}
+
+// parses the comma separated list of rotations (in degrees), the
+// rotations are normalized to [0, 360)
+func ParseNestRotations(str string) ([]float64, error) {
+	rotations := []float64{}
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		r, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse rotation %s", s)
+		}
+		r = math.Mod(r, 360)
+		if r < 0 {
+			r += 360
+		}
+		rotations = append(rotations, r)
+	}
+	if len(rotations) == 0 {
+		return nil, fmt.Errorf("No rotations in %s", str)
+	}
+	return rotations, nil
+}
+
+func MustNestRotations(str string, def []float64) []float64 {
+	r, err := ParseNestRotations(str)
+	if err != nil {
+		return def
+	}
+	return r
+}
+
+func polygonBounds(pts []path.Point) (path.Point, path.Point) {
+	min := path.NewPoint(math.MaxFloat64, math.MaxFloat64)
+	max := path.NewPoint(-math.MaxFloat64, -math.MaxFloat64)
+	for _, p := range pts {
+		if p.X < min.X {
+			min.X = p.X
+		}
+		if p.Y < min.Y {
+			min.Y = p.Y
+		}
+		if p.X > max.X {
+			max.X = p.X
+		}
+		if p.Y > max.Y {
+			max.Y = p.Y
+		}
+	}
+	return min, max
+}
+
+// moves every edge of the polygon outward by distance (inward if distance
+// is negative).  Corners are mitered, sharp outward corners are cut
+// square at the distance so the offset never comes closer than the
+// distance to the polygon.
+func offsetPolygon(pts []path.Point, distance float64) []path.Point {
+	if distance == 0 || len(pts) < 3 {
+		return pts
+	}
+	// the outward normal is on the left for clockwise polygons
+	if path.PolygonArea(pts) < 0 {
+		distance = -distance
+	}
+	d := math.Abs(distance)
+	normal := func(a, b path.Point) path.Point {
+		l := path.Distance(a, b)
+		return path.NewPoint((b.Y-a.Y)/l*distance, (a.X-b.X)/l*distance)
+	}
+	unit := func(a, b path.Point) path.Point {
+		l := path.Distance(a, b)
+		return path.NewPoint((b.X-a.X)/l, (b.Y-a.Y)/l)
+	}
+	n := len(pts)
+	offset := []path.Point{}
+	for i := range pts {
+		prev := pts[(i+n-1)%n]
+		p := pts[i]
+		next := pts[(i+1)%n]
+		n1, n2 := normal(prev, p), normal(p, next)
+		t1, t2 := unit(prev, p), unit(p, next)
+		dot := (n1.X*n2.X + n1.Y*n2.Y) / (d * d)
+		// the next edge turns away from the offset
+		outward := n1.X*t2.X+n1.Y*t2.Y < 0
+		if outward && dot < -0.5 {
+			// the miter would be more than twice the distance, cut it
+			// square where it touches the circle around the corner
+			cut := d * math.Tan(math.Acos(math.Max(dot, -1))/4)
+			offset = append(offset,
+				path.NewPoint(p.X+n1.X+t1.X*cut, p.Y+n1.Y+t1.Y*cut),
+				path.NewPoint(p.X+n2.X-t2.X*cut, p.Y+n2.Y-t2.Y*cut))
+			continue
+		}
+		if 1+dot < 1e-9 {
+			// the edge doubles back into the polygon
+			offset = append(offset,
+				path.NewPoint(p.X+n1.X, p.Y+n1.Y),
+				path.NewPoint(p.X+n2.X, p.Y+n2.Y))
+			continue
+		}
+		// the miter is where the moved edges meet
+		scale := 1 / (1 + dot)
+		offset = append(offset, path.NewPoint(p.X+(n1.X+n2.X)*scale, p.Y+(n1.Y+n2.Y)*scale))
+	}
+	return offset
+}
+
+// true if the segments a and b cross.  Segments that only touch
+// do not cross.
+func segmentsCross(a1, a2, b1, b2 path.Point) bool {
+	const eps = 1e-9
+	cross := func(o, a, b path.Point) float64 {
+		return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+	}
+	d1 := cross(b1, b2, a1)
+	d2 := cross(b1, b2, a2)
+	d3 := cross(a1, a2, b1)
+	d4 := cross(a1, a2, b2)
+	return ((d1 > eps && d2 < -eps) || (d1 < -eps && d2 > eps)) &&
+		((d3 > eps && d4 < -eps) || (d3 < -eps && d4 > eps))
+}
+
+// true if any edges of the polygons cross
+func polygonsCross(a, b []path.Point) bool {
+	amin, amax := polygonBounds(a)
+	bmin, bmax := polygonBounds(b)
+	if amax.X <= bmin.X || bmax.X <= amin.X || amax.Y <= bmin.Y || bmax.Y <= amin.Y {
+		return false
+	}
+	// only the edges in the area where the bounding boxes overlap can cross
+	min := path.NewPoint(math.Max(amin.X, bmin.X), math.Max(amin.Y, bmin.Y))
+	max := path.NewPoint(math.Min(amax.X, bmax.X), math.Min(amax.Y, bmax.Y))
+	outside := func(p1, p2 path.Point) bool {
+		return (p1.X < min.X && p2.X < min.X) || (p1.X > max.X && p2.X > max.X) ||
+			(p1.Y < min.Y && p2.Y < min.Y) || (p1.Y > max.Y && p2.Y > max.Y)
+	}
+	bEdges := []int{}
+	for j := range b {
+		if !outside(b[j], b[(j+1)%len(b)]) {
+			bEdges = append(bEdges, j)
+		}
+	}
+	for i := range a {
+		a1, a2 := a[i], a[(i+1)%len(a)]
+		if outside(a1, a2) {
+			continue
+		}
+		for _, j := range bEdges {
+			if segmentsCross(a1, a2, b[j], b[(j+1)%len(b)]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// true if polygon a is inside polygon b, assuming the edges do not cross.
+func polygonInside(a, b []path.Point) bool {
+	for _, p := range a {
+		in := path.PointInPolygon(p, b)
+		if in != 0 {
+			return in > 0
+		}
+	}
+	// every point is on the edge, try the middle of the edges
+	for i := range a {
+		j := (i + 1) % len(a)
+		in := path.PointInPolygon(path.NewPoint((a[i].X+a[j].X)/2, (a[i].Y+a[j].Y)/2), b)
+		if in != 0 {
+			return in > 0
+		}
+	}
+	// the same polygon
+	return true
+}
+
+func (s *nestShape) translate(t path.Point) *nestShape {
+	move := func(pts []path.Point) []path.Point {
+		moved := make([]path.Point, len(pts))
+		for i, p := range pts {
+			moved[i] = path.NewPoint(p.X+t.X, p.Y+t.Y)
+		}
+		return moved
+	}
+	holes := [][]path.Point{}
+	for _, h := range s.holes {
+		holes = append(holes, move(h))
+	}
+	source := s.source
+	if source == nil {
+		source = s
+	}
+	return &nestShape{
+		outer:  move(s.outer),
+		holes:  holes,
+		inside: path.NewPoint(s.inside.X+t.X, s.inside.Y+t.Y),
+		min:    path.NewPoint(s.min.X+t.X, s.min.Y+t.Y),
+		max:    path.NewPoint(s.max.X+t.X, s.max.Y+t.Y),
+		offset: path.NewPoint(s.offset.X+t.X, s.offset.Y+t.Y),
+		source: source,
+	}
+}
+
+// the outline and the holes
+func (s *nestShape) polygons() [][]path.Point {
+	return append([][]path.Point{s.outer}, s.holes...)
+}
+
+// true if the point is inside the outline and not in (or on the edge of) a hole
+func (s *nestShape) contains(p path.Point) bool {
+	if p.X <= s.min.X || p.X >= s.max.X || p.Y <= s.min.Y || p.Y >= s.max.Y {
+		return false
+	}
+	if path.PointInPolygon(p, s.outer) <= 0 {
+		return false
+	}
+	for _, h := range s.holes {
+		if path.PointInPolygon(p, h) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// true if any of the vertices, or the middle of any edges, are inside the other shape
+func (s *nestShape) touchesInside(other *nestShape) bool {
+	for _, polygon := range s.polygons() {
+		for i, p := range polygon {
+			next := polygon[(i+1)%len(polygon)]
+			if other.contains(p) || other.contains(path.NewPoint((p.X+next.X)/2, (p.Y+next.Y)/2)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// finds a point inside the shape, just inside one of the edges
+func (s *nestShape) insidePoint() path.Point {
+	step := math.Max(s.max.X-s.min.X, s.max.Y-s.min.Y) / 1000
+	for i, p := range s.outer {
+		next := s.outer[(i+1)%len(s.outer)]
+		l := path.Distance(p, next)
+		if l == 0 {
+			continue
+		}
+		mid := path.NewPoint((p.X+next.X)/2, (p.Y+next.Y)/2)
+		normal := path.NewPoint((next.Y-p.Y)/l*step, (p.X-next.X)/l*step)
+		for _, dir := range []float64{-1, 1} {
+			in := path.NewPoint(mid.X+normal.X*dir, mid.Y+normal.Y*dir)
+			if s.contains(in) {
+				return in
+			}
+		}
+	}
+	return path.NewPoint((s.min.X+s.max.X)/2, (s.min.Y+s.max.Y)/2)
+}
+
+// true if the two shapes overlap, shapes can touch.  The shapes overlap if
+// any edges cross or, since edges can lie on top of each other, if any
+// edge of one is inside the other (or the shapes are on top of each other).
+func (s *nestShape) overlaps(other *nestShape) bool {
+	if s.max.X <= other.min.X || other.max.X <= s.min.X ||
+		s.max.Y <= other.min.Y || other.max.Y <= s.min.Y {
+		return false
+	}
+	if other.contains(s.inside) || s.contains(other.inside) {
+		return true
+	}
+	for _, a := range s.polygons() {
+		for _, b := range other.polygons() {
+			if polygonsCross(a, b) {
+				return true
+			}
+		}
+	}
+	return s.touchesInside(other) || other.touchesInside(s)
+}
+
+// the area used by the shape
+func (s *nestShape) area() float64 {
+	area := math.Abs(path.PolygonArea(s.outer))
+	for _, h := range s.holes {
+		area -= math.Abs(path.PolygonArea(h))
+	}
+	return area
+}
+
+// builds the shape for the part at the given rotation.  The outline is the
+// largest closed cut, the other closed cuts that nothing else is drawn in
+// are the holes.  If the part has no closed cuts the bounding box is used.
+func newNestShape(rp *RenderedPart, angle, padding, tolerance float64) *nestShape {
+	cut := [][]path.Point{}
+	other := [][]path.Point{}
+	// the flattened curves can be up to the tolerance inside the
+	// curves, so that is added to the padding when there are curves
+	flattened := 0.0
+	for _, op := range rp.OutlinePaths() {
+		for _, seg := range op.Path.Segments() {
+			switch seg.(type) {
+			case path.MoveSegment, path.LineSegment:
+			default:
+				flattened = tolerance
+			}
+		}
+		for _, polyline := range path.FlattenPath(op.Path, tolerance) {
+			if op.Operation == Cut {
+				cut = append(cut, polyline)
+			} else {
+				other = append(other, polyline)
+			}
+		}
+	}
+	closed := [][]path.Point{}
+	for _, polyline := range cut {
+		first, last := polyline[0], polyline[len(polyline)-1]
+		if len(polyline) > 3 && path.Distance(first, last) <= tolerance {
+			closed = append(closed, polyline[:len(polyline)-1])
+		} else {
+			other = append(other, polyline)
+		}
+	}
+	sort.SliceStable(closed, func(i, j int) bool {
+		return math.Abs(path.PolygonArea(closed[i])) > math.Abs(path.PolygonArea(closed[j]))
+	})
+
+	bbox := []path.Point{
+		path.NewPoint(0, 0),
+		path.NewPoint(rp.Width, 0),
+		path.NewPoint(rp.Width, rp.Height),
+		path.NewPoint(0, rp.Height),
+	}
+	outer := bbox
+	holes := [][]path.Point{}
+	if len(closed) > 0 {
+		min, max := polygonBounds(closed[0])
+		if min.X <= tolerance && min.Y <= tolerance &&
+			max.X >= rp.Width-tolerance && max.Y >= rp.Height-tolerance {
+			outer = closed[0]
+			candidates := closed[1:]
+			for i, h := range candidates {
+				if !polygonInside(h, outer) {
+					continue
+				}
+				empty := true
+				for j, c := range candidates {
+					if i != j && !polygonsCross(c, h) && polygonInside(c, h) {
+						empty = false
+					}
+				}
+				for _, o := range other {
+					if path.PointInPolygon(o[0], h) >= 0 {
+						empty = false
+					}
+				}
+				if empty {
+					holes = append(holes, h)
+				}
+			}
+		}
+	}
+
+	rotate := func(pts []path.Point) []path.Point {
+		rotated := make([]path.Point, len(pts))
+		for i, p := range pts {
+			rotated[i] = path.Rotate(angle, p)
+		}
+		return rotated
+	}
+	shape := &nestShape{
+		outer: orientPolygon(offsetPolygon(rotate(outer), padding+flattened), true),
+	}
+	for _, h := range holes {
+		area := path.PolygonArea(h)
+		shrunk := offsetPolygon(rotate(h), -padding-flattened)
+		// holes smaller than the padding disappear
+		if path.PolygonArea(shrunk)*area <= 0 || math.Abs(path.PolygonArea(shrunk)) >= math.Abs(area) {
+			continue
+		}
+		shape.holes = append(shape.holes, orientPolygon(shrunk, false))
+	}
+	shape.min, shape.max = polygonBounds(shape.outer)
+	shape.inside = shape.insidePoint()
+	shape.offset = path.NewPoint(0, 0)
+	return shape.translate(path.NewPoint(-shape.min.X, -shape.min.Y))
+}
+
+// the shape for the part at the given rotation, from the cache
+func (d *SVGDocument) nestShape(rp *RenderedPart, angle float64) *nestShape {
+	if d.nestShapes == nil {
+		d.nestShapes = newNestShapes()
+	}
+	key := nestKey{part: rp, angle: angle}
+	s, ok := d.nestShapes.shapes[key]
+	if !ok {
+		outline := fmt.Sprintf("%f %f %f", angle, rp.Width, rp.Height)
+		for _, op := range rp.OutlinePaths() {
+			outline += " " + string(op.Operation) + " " + path.SvgString(op.Path, 6)
+		}
+		s, ok = d.nestShapes.outlines[outline]
+		if !ok {
+			s = newNestShape(rp, angle, d.Padding, math.Max(d.CurveTolerance, d.Padding/4))
+			d.nestShapes.outlines[outline] = s
+		}
+		d.nestShapes.shapes[key] = s
+	}
+	return s
+}
+
+// the polygon without repeated points, going clockwise (positive area)
+// or counterclockwise
+func orientPolygon(pts []path.Point, clockwise bool) []path.Point {
+	oriented := []path.Point{}
+	for i, p := range pts {
+		if path.Distance(p, pts[(i+1)%len(pts)]) > 1e-9 {
+			oriented = append(oriented, p)
+		}
+	}
+	if (path.PolygonArea(oriented) > 0) != clockwise {
+		for i, j := 0, len(oriented)-1; i < j; i, j = i+1, j-1 {
+			oriented[i], oriented[j] = oriented[j], oriented[i]
+		}
+	}
+	return oriented
+}
+
+// an edge of a no-fit polygon
+type nestEdge struct {
+	a, b     path.Point
+	min, max path.Point
+}
+
+func newNestEdge(a, b path.Point) nestEdge {
+	min, max := polygonBounds([]path.Point{a, b})
+	return nestEdge{a: a, b: b, min: min, max: max}
+}
+
+func (e nestEdge) translate(t path.Point) nestEdge {
+	return nestEdge{
+		a:   path.NewPoint(e.a.X+t.X, e.a.Y+t.Y),
+		b:   path.NewPoint(e.b.X+t.X, e.b.Y+t.Y),
+		min: path.NewPoint(e.min.X+t.X, e.min.Y+t.Y),
+		max: path.NewPoint(e.max.X+t.X, e.max.Y+t.Y),
+	}
+}
+
+// the point at param t along the edge
+func (e nestEdge) point(t float64) path.Point {
+	return path.NewPoint(e.a.X+(e.b.X-e.a.X)*t, e.a.Y+(e.b.Y-e.a.Y)*t)
+}
+
+// true if the bounding boxes of the edges overlap
+func (e nestEdge) near(o nestEdge) bool {
+	return o.min.X <= e.max.X+1e-9 && e.min.X <= o.max.X+1e-9 &&
+		o.min.Y <= e.max.Y+1e-9 && e.min.Y <= o.max.Y+1e-9
+}
+
+// the params along the edge where the other edge crosses it, or where the
+// other edge starts or ends on it when they lie on top of each other.  The
+// ends of the edge are left out
+func (e nestEdge) crossings(o nestEdge) []float64 {
+	d1 := path.NewPoint(e.b.X-e.a.X, e.b.Y-e.a.Y)
+	d2 := path.NewPoint(o.b.X-o.a.X, o.b.Y-o.a.Y)
+	l1 := d1.X*d1.X + d1.Y*d1.Y
+	if l1 == 0 {
+		return nil
+	}
+	w := path.NewPoint(o.a.X-e.a.X, o.a.Y-e.a.Y)
+	denom := d1.X*d2.Y - d1.Y*d2.X
+	inside := func(t float64) bool {
+		return t > 1e-9 && t < 1-1e-9
+	}
+	if math.Abs(denom) < 1e-12*math.Sqrt(l1*(d2.X*d2.X+d2.Y*d2.Y)) {
+		// parallel, only edges on the same line meet
+		if math.Abs(w.X*d1.Y-w.Y*d1.X) > 1e-9*math.Sqrt(l1) {
+			return nil
+		}
+		params := []float64{}
+		for _, p := range []path.Point{o.a, o.b} {
+			t := ((p.X-e.a.X)*d1.X + (p.Y-e.a.Y)*d1.Y) / l1
+			if inside(t) {
+				params = append(params, t)
+			}
+		}
+		return params
+	}
+	t := (w.X*d2.Y - w.Y*d2.X) / denom
+	u := (w.X*d1.Y - w.Y*d1.X) / denom
+	if !inside(t) || u < -1e-9 || u > 1+1e-9 {
+		return nil
+	}
+	return []float64{t}
+}
+
+// where the edges cross, false if they are parallel or do not meet
+func (e nestEdge) intersection(o nestEdge) (path.Point, bool) {
+	d1 := path.NewPoint(e.b.X-e.a.X, e.b.Y-e.a.Y)
+	d2 := path.NewPoint(o.b.X-o.a.X, o.b.Y-o.a.Y)
+	denom := d1.X*d2.Y - d1.Y*d2.X
+	if math.Abs(denom) < 1e-12 {
+		return path.Point{}, false
+	}
+	w := path.NewPoint(o.a.X-e.a.X, o.a.Y-e.a.Y)
+	t := (w.X*d2.Y - w.Y*d2.X) / denom
+	u := (w.X*d1.Y - w.Y*d1.X) / denom
+	if t < -1e-9 || t > 1+1e-9 || u < -1e-9 || u > 1+1e-9 {
+		return path.Point{}, false
+	}
+	return e.point(t), true
+}
+
+// true if the direction d lies in the turn from direction in to out.  The
+// turns at the vertices of a polygon each include one end, so an edge
+// of the other polygon that is parallel to an edge at the vertex is only
+// used once: withIn includes the in direction, otherwise the out
+// direction is included.
+func turnContains(in, d, out path.Point, withIn bool) bool {
+	cross := func(u, v path.Point) float64 {
+		return u.X*v.Y - u.Y*v.X
+	}
+	turn := cross(in, out)
+	if turn == 0 && in.X*out.X+in.Y*out.Y < 0 {
+		// the polygon doubles back, the turn is half a circle to the left
+		turn = 1
+	}
+	c1 := cross(in, d)
+	c2 := cross(d, out)
+	if turn < 0 {
+		c1, c2 = -c1, -c2
+	}
+	if turn == 0 {
+		return false
+	}
+	if c1 == 0 && c2 == 0 {
+		// d is parallel to in and out, in the direction of in if they
+		// double back
+		if in.X*d.X+in.Y*d.Y > 0 {
+			return withIn
+		}
+		return !withIn && out.X*d.X+out.Y*d.Y > 0
+	}
+	if withIn {
+		return c1 >= 0 && c2 > 0
+	}
+	return c1 > 0 && c2 >= 0
+}
+
+// the convolution of the polygons a and b, each edge of a moved by the
+// vertices of b whose turn contains the edge direction, and the other
+// way round.  The edges of the minkowski sum of a and b are all on the
+// convolution.  The polygons have to go clockwise, holes
+// counterclockwise.  If reduced, only the convex vertices are used: a
+// reflex vertex can not touch an edge without overlapping it, so those
+// edges are inside the sum, but they are needed to close the cycles.
+func convolve(a, b []path.Point, reduced bool, edges []nestEdge) []nestEdge {
+	dir := func(pts []path.Point, i int) path.Point {
+		p, next := pts[i], pts[(i+1)%len(pts)]
+		return path.NewPoint(next.X-p.X, next.Y-p.Y)
+	}
+	convex := func(in, out path.Point) bool {
+		return !reduced || in.X*out.Y-in.Y*out.X >= 0
+	}
+	add := func(p1, p2, offset path.Point) {
+		edges = append(edges, newNestEdge(
+			path.NewPoint(p1.X+offset.X, p1.Y+offset.Y),
+			path.NewPoint(p2.X+offset.X, p2.Y+offset.Y)))
+	}
+	// where an edge of a and an edge of b are parallel the edge of a
+	// goes first, so the vertex of b includes the out direction and the
+	// vertex of a the in direction
+	for j := range b {
+		in, out := dir(b, (j+len(b)-1)%len(b)), dir(b, j)
+		if !convex(in, out) {
+			continue
+		}
+		for i := range a {
+			if turnContains(in, dir(a, i), out, false) {
+				add(a[i], a[(i+1)%len(a)], b[j])
+			}
+		}
+	}
+	for i := range a {
+		in, out := dir(a, (i+len(a)-1)%len(a)), dir(a, i)
+		if !convex(in, out) {
+			continue
+		}
+		for j := range b {
+			if turnContains(in, dir(b, j), out, true) {
+				add(b[j], b[(j+1)%len(b)], a[i])
+			}
+		}
+	}
+	return edges
+}
+
+// the edges of the no-fit polygon of the shape s (at the origin) around
+// the placed shape, moving s by a point on an edge makes the shapes touch.
+// This is the reduced convolution of the placed shape and s turned around
+// the origin, for each outline and hole.
+func noFitEdges(placed, s *nestShape, edges []nestEdge) []nestEdge {
+	for _, a := range placed.polygons() {
+		for _, polygon := range s.polygons() {
+			b := make([]path.Point, len(polygon))
+			for i, p := range polygon {
+				b[i] = path.NewPoint(-p.X, -p.Y)
+			}
+			edges = convolve(a, b, true, edges)
+		}
+	}
+	return edges
+}
+
+// drops the edges that are on top of an earlier edge, in either direction
+func uniqueNestEdges(edges []nestEdge) []nestEdge {
+	round := func(v float64) int64 { return int64(math.Round(v * 1e6)) }
+	seen := map[[4]int64]bool{}
+	unique := edges[:0]
+	for _, e := range edges {
+		key := [4]int64{round(e.a.X), round(e.a.Y), round(e.b.X), round(e.b.Y)}
+		if key[0] > key[2] || (key[0] == key[2] && key[1] > key[3]) {
+			key = [4]int64{key[2], key[3], key[0], key[1]}
+		}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, e)
+		}
+	}
+	return unique
+}
+
+// cuts the edges where they cross each other
+func splitNestEdges(edges []nestEdge) []nestEdge {
+	order := make([]int, len(edges))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return edges[order[i]].min.X < edges[order[j]].min.X
+	})
+	params := make([][]float64, len(edges))
+	for k, i := range order {
+		for _, j := range order[k+1:] {
+			if edges[j].min.X > edges[i].max.X+1e-9 {
+				break
+			}
+			if !edges[i].near(edges[j]) {
+				continue
+			}
+			params[i] = append(params[i], edges[i].crossings(edges[j])...)
+			params[j] = append(params[j], edges[j].crossings(edges[i])...)
+		}
+	}
+	pieces := []nestEdge{}
+	for i, e := range edges {
+		ts := append(params[i], 0, 1)
+		sort.Float64s(ts)
+		for k := 0; k < len(ts)-1; k++ {
+			if ts[k+1]-ts[k] > 1e-9 {
+				pieces = append(pieces, newNestEdge(e.point(ts[k]), e.point(ts[k+1])))
+			}
+		}
+	}
+	return pieces
+}
+
+// the edges in horizontal bands, to find the edges at a height quickly
+type edgeBands struct {
+	edges []nestEdge
+	min   float64
+	step  float64
+	bands [][]int32
+}
+
+func newEdgeBands(edges []nestEdge) *edgeBands {
+	b := &edgeBands{edges: edges, min: math.MaxFloat64, step: 1}
+	max := -math.MaxFloat64
+	for _, e := range edges {
+		b.min = math.Min(b.min, e.min.Y)
+		max = math.Max(max, e.max.Y)
+	}
+	count := len(edges)/4 + 1
+	if max > b.min {
+		b.step = (max - b.min) / float64(count)
+	}
+	b.bands = make([][]int32, count)
+	for i, e := range edges {
+		for j := b.band(e.min.Y); j <= b.band(e.max.Y); j++ {
+			b.bands[j] = append(b.bands[j], int32(i))
+		}
+	}
+	return b
+}
+
+func (b *edgeBands) band(y float64) int {
+	i := int((y - b.min) / b.step)
+	if i < 0 {
+		return 0
+	}
+	if i >= len(b.bands) {
+		return len(b.bands) - 1
+	}
+	return i
+}
+
+// the number of times the edges wind around the point
+func (b *edgeBands) windingNumber(p path.Point) int {
+	w := 0
+	for _, i := range b.bands[b.band(p.Y)] {
+		w += b.edges[i].winding(p)
+	}
+	return w
+}
+
+// the winding numbers of two points, in one pass when they are in the
+// same band
+func (b *edgeBands) windingNumbers(p, q path.Point) (int, int) {
+	if b.band(p.Y) != b.band(q.Y) {
+		return b.windingNumber(p), b.windingNumber(q)
+	}
+	wp, wq := 0, 0
+	for _, i := range b.bands[b.band(p.Y)] {
+		e := &b.edges[i]
+		wp += e.winding(p)
+		wq += e.winding(q)
+	}
+	return wp, wq
+}
+
+// how the edge winds around the point, 1 if it goes up on the right of
+// the point, -1 down and 0 if it does not cross to the right of it
+func (e *nestEdge) winding(p path.Point) int {
+	if e.min.Y > p.Y || e.max.Y <= p.Y || e.max.X < p.X {
+		return 0
+	}
+	side := (e.b.X-e.a.X)*(p.Y-e.a.Y) - (p.X-e.a.X)*(e.b.Y-e.a.Y)
+	if e.a.Y <= p.Y {
+		if side > 0 {
+			return 1
+		}
+	} else if side < 0 {
+		return -1
+	}
+	return 0
+}
+
+// the no-fit polygon of a shape around a placed shape, both in their
+// own coordinates
+type noFitPolygon struct {
+	placed *nestShape
+	shape  *nestShape
+	// the convolution of the outlines, the shape overlaps the placed
+	// outline where the winding number is not 0
+	outlines *edgeBands
+	// the edges of the no-fit polygon
+	edges    []nestEdge
+	min, max path.Point
+}
+
+func newNoFitPolygon(placed, s *nestShape) *noFitPolygon {
+	turned := make([]path.Point, len(s.outer))
+	for i, p := range s.outer {
+		turned[i] = path.NewPoint(-p.X, -p.Y)
+	}
+	outlines := convolve(placed.outer, turned, false, nil)
+	n := &noFitPolygon{
+		placed:   placed,
+		shape:    s,
+		outlines: newEdgeBands(outlines),
+	}
+	ends := []path.Point{}
+	for _, e := range outlines {
+		ends = append(ends, e.a, e.b)
+	}
+	n.min, n.max = polygonBounds(ends)
+
+	// the pieces of the convolution between an overlap and no overlap,
+	// checked just to each side of the middle.  Edges of the
+	// convolution often lie on top of each other, those are only
+	// checked once
+	const side = 1e-6
+	for _, e := range uniqueNestEdges(splitNestEdges(uniqueNestEdges(noFitEdges(placed, s, nil)))) {
+		l := path.Distance(e.a, e.b)
+		mid := e.point(.5)
+		normal := path.NewPoint((e.b.Y-e.a.Y)/l*side, (e.a.X-e.b.X)/l*side)
+		right := path.NewPoint(mid.X+normal.X, mid.Y+normal.Y)
+		left := path.NewPoint(mid.X-normal.X, mid.Y-normal.Y)
+		wr, wl := n.outlines.windingNumbers(right, left)
+		if n.overlapsWinding(right, wr) != n.overlapsWinding(left, wl) {
+			n.edges = append(n.edges, e)
+		}
+	}
+	return n
+}
+
+// true if the shape moved by t overlaps the placed shape.  Only
+// dependable when t is not on an edge of the convolution
+func (n *noFitPolygon) overlaps(t path.Point) bool {
+	if t.X <= n.min.X || t.X >= n.max.X || t.Y <= n.min.Y || t.Y >= n.max.Y {
+		return false
+	}
+	return n.overlapsWinding(t, n.outlines.windingNumber(t))
+}
+
+// overlaps, given the winding number of the convolution around t
+func (n *noFitPolygon) overlapsWinding(t path.Point, winding int) bool {
+	if winding == 0 {
+		return false
+	}
+	// the outlines overlap, but one shape can still be in a hole of
+	// the other
+	for _, h := range n.placed.holes {
+		min, max := polygonBounds(h)
+		if n.shape.min.X+t.X >= min.X && n.shape.min.Y+t.Y >= min.Y &&
+			n.shape.max.X+t.X <= max.X && n.shape.max.Y+t.Y <= max.Y {
+			return n.shape.translate(t).overlaps(n.placed)
+		}
+	}
+	for _, h := range n.shape.holes {
+		min, max := polygonBounds(h)
+		if n.placed.min.X >= min.X+t.X && n.placed.min.Y >= min.Y+t.Y &&
+			n.placed.max.X <= max.X+t.X && n.placed.max.Y <= max.Y+t.Y {
+			return n.shape.translate(t).overlaps(n.placed)
+		}
+	}
+	return true
+}
+
+// true if the shape moved by t is inside the no-fit polygon, touching
+// the placed shape is fine
+func (n *noFitPolygon) blocks(t path.Point) bool {
+	if t.X <= n.min.X || t.X >= n.max.X || t.Y <= n.min.Y || t.Y >= n.max.Y {
+		return false
+	}
+	for _, e := range n.edges {
+		if t.X >= e.min.X-1e-7 && t.X <= e.max.X+1e-7 && t.Y >= e.min.Y-1e-7 && t.Y <= e.max.Y+1e-7 &&
+			segmentDistance(t, e.a, e.b) < 1e-7 {
+			return false
+		}
+	}
+	return n.overlaps(t)
+}
+
+// a no-fit polygon moved to where its placed shape is on the sheet
+type placedNoFit struct {
+	*noFitPolygon
+	offset path.Point
+}
+
+func (n placedNoFit) blocks(c path.Point) bool {
+	return n.noFitPolygon.blocks(path.NewPoint(c.X-n.offset.X, c.Y-n.offset.Y))
+}
+
+// the no-fit polygons of the shape around each of the nested shapes
+func (d *SVGDocument) noFitPolygons(s *nestShape) []placedNoFit {
+	if d.nestShapes == nil {
+		d.nestShapes = newNestShapes()
+	}
+	noFits := []placedNoFit{}
+	for _, placed := range d.nested {
+		source := placed.source
+		if source == nil {
+			source = placed
+		}
+		key := noFitKey{placed: source, shape: s}
+		n, ok := d.nestShapes.noFit[key]
+		if !ok {
+			n = newNoFitPolygon(source, s)
+			d.nestShapes.noFit[key] = n
+		}
+		noFits = append(noFits, placedNoFit{
+			noFitPolygon: n,
+			offset:       path.NewPoint(placed.offset.X-source.offset.X, placed.offset.Y-source.offset.Y),
+		})
+	}
+	return noFits
+}
+
+// the positions to try for the shape, sorted top to bottom, left to
+// right.  These are the corners of the area outside of the no-fit polygons
+// and inside the positions that keep the shape on the sheet.
+func (d *SVGDocument) nestCandidates(s *nestShape, noFits []placedNoFit) []path.Point {
+	maxX := d.Width - s.max.X
+	maxY := d.Height - s.max.Y
+	seen := map[[2]int64]bool{}
+	candidates := []path.Point{}
+	add := func(p path.Point) {
+		if p.X < -1e-9 || p.Y < -1e-9 || p.X > maxX+1e-9 || p.Y > maxY+1e-9 {
+			return
+		}
+		p = path.NewPoint(math.Max(0, math.Min(p.X, maxX)), math.Max(0, math.Min(p.Y, maxY)))
+		key := [2]int64{int64(math.Round(p.X * 1e6)), int64(math.Round(p.Y * 1e6))}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		candidates = append(candidates, p)
+	}
+	sides := []nestEdge{
+		newNestEdge(path.NewPoint(0, 0), path.NewPoint(maxX, 0)),
+		newNestEdge(path.NewPoint(maxX, 0), path.NewPoint(maxX, maxY)),
+		newNestEdge(path.NewPoint(0, maxY), path.NewPoint(maxX, maxY)),
+		newNestEdge(path.NewPoint(0, 0), path.NewPoint(0, maxY)),
+	}
+	for _, side := range sides {
+		add(side.a)
+		add(side.b)
+	}
+
+	// the edges on the sheet, and the no-fit polygon each is from
+	type sheetEdge struct {
+		nestEdge
+		polygon int
+	}
+	edges := []sheetEdge{}
+	for i, n := range noFits {
+		for _, e := range n.edges {
+			e = e.translate(n.offset)
+			if e.max.X < -1e-9 || e.max.Y < -1e-9 || e.min.X > maxX+1e-9 || e.min.Y > maxY+1e-9 {
+				continue
+			}
+			edges = append(edges, sheetEdge{nestEdge: e, polygon: i})
+			add(e.a)
+			add(e.b)
+			for _, side := range sides {
+				if p, ok := e.intersection(side); ok {
+					add(p)
+				}
+			}
+		}
+	}
+	// where the edges of different no-fit polygons cross, the edges of
+	// one polygon only meet at its vertices.  Sweeping left to right so
+	// only the edges whose x ranges overlap are compared
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].min.X < edges[j].min.X
+	})
+	for i, e := range edges {
+		for _, o := range edges[i+1:] {
+			if o.min.X > e.max.X+1e-9 {
+				break
+			}
+			if o.polygon == e.polygon || !e.near(o.nestEdge) {
+				continue
+			}
+			if p, ok := e.intersection(o.nestEdge); ok {
+				add(p)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Y != candidates[j].Y {
+			return candidates[i].Y < candidates[j].Y
+		}
+		return candidates[i].X < candidates[j].X
+	})
+	return candidates
+}
+
+// nests the part into the document. Returns false if the part does not fit.
+func (d *SVGDocument) nest(p *RenderedPart) (*docRenderable, bool) {
+	var best *nestShape
+	bestAngle := 0.0
+	for _, angle := range d.NestRotations {
+		if !nestRotationAllowed(p.Rotation, p.GrainRotation, angle) {
+			continue
+		}
+		s := d.nestShape(p, angle)
+		if s.max.X > d.Width || s.max.Y > d.Height {
+			continue
+		}
+		noFits := d.noFitPolygons(s)
+		// the no-fit polygon that blocked the last candidate, the next
+		// candidate is likely to be blocked by it too
+		blocker := 0
+		for _, c := range d.nestCandidates(s, noFits) {
+			if best != nil && (c.Y+s.max.Y > best.max.Y ||
+				(c.Y+s.max.Y == best.max.Y && c.X >= best.min.X)) {
+				// can not beat the best placement
+				break
+			}
+			blocked := false
+			for i := range noFits {
+				k := (blocker + i) % len(noFits)
+				if noFits[k].blocks(c) {
+					blocker = k
+					blocked = true
+					break
+				}
+			}
+			if blocked {
+				continue
+			}
+			// the no-fit polygons are checked just to the sides of their
+			// edges, make sure the outlines do not overlap
+			placed := s.translate(c)
+			if d.nestOverlaps(placed) {
+				continue
+			}
+			best = placed
+			bestAngle = angle
+			break
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	d.nested = append(d.nested, best)
+	return &docRenderable{
+		renderedPart:     p,
+		position:         best.offset,
+		angle:            bestAngle,
+		segmentOperators: d.SegmentOperators,
+	}, true
+}
+
+// true if the shape overlaps any of the nested shapes
+func (d *SVGDocument) nestOverlaps(s *nestShape) bool {
+	for _, n := range d.nested {
+		if s.overlaps(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// true if the nest angle keeps to the part's layout rotation.  Turning the
+// part by 180 degrees keeps the grain direction, so that is allowed when
+// the rotation comes from the grain.  The angle is in [0, 360)
+func nestRotationAllowed(rotation binpacking.Rotation, grain bool, angle float64) bool {
+	if grain {
+		angle = math.Mod(angle, 180)
+	}
+	switch rotation {
+	case binpacking.RotateNone:
+		return angle == 0
+	case binpacking.Rotate90:
+		return angle == 90
+	}
+	return true
+}
+
+// the area of the document not used by any nested parts (including padding)
+func (d *SVGDocument) nestEmptyArea() float64 {
+	area := d.Width * d.Height
+	for _, s := range d.nested {
+		area -= s.area()
+	}
+	return area
+}
@@ -0,0 +1,182 @@
+package dom
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/binpacking"
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+func testNestDocument(w, h float64) *SVGDocument {
+	doc := NewSVGDocument(w, h, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	doc.Nest = true
+	return doc
+}
+
+// the bounding box of the part in document coordinates
+func testDocumentBounds(t *testing.T, r *docRenderable) (path.Point, path.Point) {
+	pth, err := r.documentPath()
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	tl, br, err := path.BoundingBoxTrimWhitespace(pth, AppContext().SegmentOperators())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	return tl, br
+}
+
+func TestParseNestRotations(t *testing.T) {
+	r, err := ParseNestRotations("0, 90,450, -90")
+	if err != nil || len(r) != 4 || r[1] != 90 || r[2] != 90 || r[3] != 270 {
+		t.Errorf("Expected [0 90 90 270], got %v (%v)", r, err)
+	}
+	_, err = ParseNestRotations("0,ninety")
+	if err == nil {
+		t.Errorf("Expected error for bad rotation")
+	}
+}
+
+func TestNestRotationAllowed(t *testing.T) {
+	tests := []struct {
+		rotation binpacking.Rotation
+		grain    bool
+		angle    float64
+		allowed  bool
+	}{
+		{binpacking.RotateNone, false, 0, true},
+		{binpacking.RotateNone, false, 180, false},
+		{binpacking.RotateNone, true, 180, true},
+		{binpacking.Rotate90, false, 270, false},
+		{binpacking.Rotate90, true, 270, true},
+		{binpacking.RotateFree, false, 45, true},
+	}
+	for _, test := range tests {
+		if nestRotationAllowed(test.rotation, test.grain, test.angle) != test.allowed {
+			t.Errorf("Expected rotation %s grain %v at %.0f allowed: %v", test.rotation, test.grain, test.angle, test.allowed)
+		}
+	}
+}
+
+func TestNestInHole(t *testing.T) {
+	doc := testNestDocument(6.4, 6.4)
+	frame := testRenderedPart("frame", "M 0 0 L 6 0 L 6 6 L 0 6 L 0 0 M 1 1 L 5 1 L 5 5 L 1 5 L 1 1")
+	small := testRenderedPart("small", "M 0 0 L 2 0 L 2 2 L 0 2 L 0 0")
+	for _, p := range []*RenderedPart{frame, small} {
+		added, err := doc.Add(p, RenderContext{})
+		if err != nil || !added {
+			t.Fatalf("Expected %s to be added (%v)", p.Part.Id(), err)
+		}
+	}
+	tl, br := testDocumentBounds(t, doc.renderables[1])
+	if tl.X < 1.2-1e-6 || tl.Y < 1.2-1e-6 || br.X > 4.8+1e-6 || br.Y > 4.8+1e-6 {
+		t.Errorf("Expected the small part inside the hole, got %v %v", tl, br)
+	}
+
+	// does not fit anywhere else
+	added, _ := doc.Add(testRenderedPart("big", "M 0 0 L 3.8 0 L 3.8 3.8 L 0 3.8 L 0 0"), RenderContext{})
+	if added {
+		t.Errorf("Expected the big part not to fit")
+	}
+}
+
+func TestNestRotations(t *testing.T) {
+	doc := testNestDocument(10, 3)
+	doc.NestRotations = []float64{0, 315}
+	// a long thin diagonal part only fits when rotated
+	diagonal := testRenderedPart("diagonal", "M 0 1 L 1 0 L 6 5 L 5 6 L 0 1")
+	added, err := doc.Add(diagonal, RenderContext{})
+	if err != nil || !added {
+		t.Fatalf("Expected diagonal to be added (%v)", err)
+	}
+	r := doc.renderables[0]
+	if r.angle != 315 {
+		t.Errorf("Expected a 315 degree rotation, got %f", r.angle)
+	}
+	tl, br := testDocumentBounds(t, r)
+	if tl.X < 0 || tl.Y < 0 || br.X > doc.Width || br.Y > doc.Height {
+		t.Errorf("Expected the part inside the document, got %v %v", tl, br)
+	}
+	buf := &bytes.Buffer{}
+	doc.WriteSVG(RenderContext{}, buf)
+	if !strings.Contains(buf.String(), "rotate(315.000)") {
+		t.Errorf("Expected the svg part to be rotated\n%s", buf.String())
+	}
+}
+
+func TestNestTriangles(t *testing.T) {
+	// two triangles fit together in a sheet that only fits
+	// one of their bounding boxes
+	doc := testNestDocument(4.8, 3.8)
+	doc.NestRotations = []float64{0, 180}
+	for i := 0; i < 2; i++ {
+		triangle := testRenderedPart("triangle", "M 0 0 L 4 0 L 0 3 L 0 0")
+		added, err := doc.Add(triangle, RenderContext{})
+		if err != nil || !added {
+			t.Fatalf("Expected part %d to be added (%v)", i, err)
+		}
+	}
+	if doc.renderables[1].angle != 180 {
+		t.Errorf("Expected the second part to be rotated 180, got %f", doc.renderables[1].angle)
+	}
+	if doc.nested[0].overlaps(doc.nested[1]) {
+		t.Errorf("Expected the parts not to overlap")
+	}
+}
+
+func TestNestNotch(t *testing.T) {
+	// the square only fits wedged into the notch, touching both sides
+	// of it but none of the corners
+	doc := testNestDocument(6.2, 6.2)
+	notched := testRenderedPart("notched", "M 0 0 L 1.5 0 L 3 3 L 4.5 0 L 6 0 L 6 6 L 0 6 L 0 0")
+	square := testRenderedPart("square", "M 0 0 L 1 0 L 1 1 L 0 1 L 0 0")
+	for _, p := range []*RenderedPart{notched, square} {
+		added, err := doc.Add(p, RenderContext{})
+		if err != nil || !added {
+			t.Fatalf("Expected %s to be added (%v)", p.Part.Id(), err)
+		}
+	}
+	if doc.nested[0].overlaps(doc.nested[1]) {
+		t.Errorf("Expected the parts not to overlap")
+	}
+	tl, br := testDocumentBounds(t, doc.renderables[1])
+	if tl.X < .1-1e-6 || tl.Y < .1-1e-6 || br.X > 6.1+1e-6 || br.Y > 6.1+1e-6 {
+		t.Errorf("Expected the square inside the document, got %v %v", tl, br)
+	}
+}
+
+func TestOffsetPolygonClearance(t *testing.T) {
+	// sharp corners both ways
+	polygon := []path.Point{
+		path.NewPoint(0, 0),
+		path.NewPoint(10, 0),
+		path.NewPoint(1, 1),
+		path.NewPoint(10, 2),
+		path.NewPoint(0, 2),
+	}
+	for _, distance := range []float64{.25, -.25} {
+		offset := offsetPolygon(polygon, distance)
+		for i, a := range offset {
+			b := offset[(i+1)%len(offset)]
+			for k := 0; k <= 20; k++ {
+				p := path.NewPoint(a.X+(b.X-a.X)*float64(k)/20, a.Y+(b.Y-a.Y)*float64(k)/20)
+				min := math.MaxFloat64
+				for j, c := range polygon {
+					min = math.Min(min, segmentDistance(p, c, polygon[(j+1)%len(polygon)]))
+				}
+				if min < .25-1e-9 {
+					t.Errorf("Expected the offset %f at least .25 from the polygon, %v is %f", distance, p, min)
+				}
+			}
+		}
+	}
+	// a bevel, not a spike
+	_, max := polygonBounds(offsetPolygon(polygon, .25))
+	if max.X > 10.5 {
+		t.Errorf("Expected the sharp corners cut, got %f", max.X)
+	}
+}
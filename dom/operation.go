@@ -0,0 +1,128 @@
+package dom
+
+import (
+	"fmt"
+
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/transforms"
+)
+
+// what the machine should do with a path
+type Operation string
+
+const (
+	Cut     Operation = "cut"
+	Score   Operation = "score"
+	Engrave Operation = "engrave"
+)
+
+// all the operations, in the order they should be run.
+// engrave and score happen before the part is cut free
+var Operations = []Operation{Engrave, Score, Cut}
+
+func ParseOperation(str string) (Operation, error) {
+	for _, op := range Operations {
+		if string(op) == str {
+			return op, nil
+		}
+	}
+	return Cut, fmt.Errorf("Error, unknown operation %s", str)
+}
+
+func containsOperation(ops []Operation, op Operation) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// a path and the operation to perform on it
+type OperationPath struct {
+	Operation Operation
+	Path      path.Path
+}
+
+// the operation attribute of the element, or def if it is not set
+func elementOperation(e Element, def Operation) (Operation, error) {
+	str, ok := NewAttrElement(e).String("operation")
+	if !ok {
+		return def, nil
+	}
+	return ParseOperation(str)
+}
+
+// adds the path to the operation paths, joining it with any
+// existing path for the same operation
+func addOperationPath(ops []OperationPath, op Operation, pth path.Path) []OperationPath {
+	for i, o := range ops {
+		if o.Operation == op {
+			ops[i].Path = transforms.SimpleJoin{}.JoinPaths(o.Path, pth)
+			return ops
+		}
+	}
+	return append(ops, OperationPath{Operation: op, Path: pth})
+}
+
+// joins all the operation paths into a single path
+func joinOperationPaths(ops []OperationPath) path.Path {
+	if len(ops) == 0 {
+		return path.NewPath()
+	}
+	pth := ops[0].Path
+	for _, op := range ops[1:] {
+		pth = transforms.SimpleJoin{}.JoinPaths(pth, op.Path)
+	}
+	return pth
+}
+
+// applies the transform to all the operation paths.
+// Point transforms (move, rotate, trim whitespace...) are applied to each
+// path, measured on all the paths together so they stay lined up. Any other
+// transform rebuilds the path (join, offset...), those are only applied to
+// the cut path.
+func transformOperationPaths(t path.PathTransform, ops []OperationPath) ([]OperationPath, error) {
+	if len(ops) == 1 {
+		p, err := t.PathTransform(ops[0].Path)
+		return []OperationPath{{Operation: ops[0].Operation, Path: p}}, err
+	}
+
+	ret := []OperationPath{}
+	if pt, ok := t.(transforms.PointPathTransform); ok {
+		combined := joinOperationPaths(ops)
+		for _, op := range ops {
+			p, err := pt.PathTransformWithReference(op.Path, combined)
+			if err != nil {
+				return ops, err
+			}
+			ret = append(ret, OperationPath{Operation: op.Operation, Path: p})
+		}
+		return ret, nil
+	}
+
+	for _, op := range ops {
+		if op.Operation != Cut {
+			ret = append(ret, op)
+			continue
+		}
+		p, err := t.PathTransform(op.Path)
+		if err != nil {
+			return ops, err
+		}
+		ret = append(ret, OperationPath{Operation: op.Operation, Path: p})
+	}
+	return ret, nil
+}
+
+// collapses multiple consecutive Moves into the last one
+func collapseMoves(pth path.Path) path.Path {
+	sgs := []path.Segment{}
+	for _, s := range pth.Segments() {
+		if path.IsMove(s) {
+			sgs = path.TrimTailMove(sgs)
+		}
+		sgs = append(sgs, s)
+	}
+	return path.NewPathFromSegments(sgs)
+}
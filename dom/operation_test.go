@@ -0,0 +1,125 @@
+package dom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/transforms"
+)
+
+func TestParseOperation(t *testing.T) {
+	op, err := ParseOperation("score")
+	if err != nil || op != Score {
+		t.Errorf("Expected score, got %s (%v)", op, err)
+	}
+	_, err = ParseOperation("etch")
+	if err == nil {
+		t.Errorf("Expected error for unknown operation")
+	}
+}
+
+func TestTransformOperationPaths(t *testing.T) {
+	cut, _ := path.ParsePathFromSvg("M 1 1 L 3 1 L 3 3")
+	score, _ := path.ParsePathFromSvg("M 2 2 L 2 3")
+	ops, err := transformOperationPaths(transforms.TrimWhitespaceTransform{
+		SegmentOperators: AppContext().SegmentOperators(),
+	}, []OperationPath{
+		{Operation: Cut, Path: cut},
+		{Operation: Score, Path: score},
+	})
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 operation paths, got %d", len(ops))
+	}
+	expected := []string{
+		"M 0.000 0.000 L 2.000 0.000 L 2.000 2.000",
+		"M 1.000 1.000 L 1.000 2.000",
+	}
+	for i, op := range ops {
+		actual := path.SvgString(op.Path, 3)
+		if actual != expected[i] {
+			t.Errorf("Expected: %s\nActual: %s", expected[i], actual)
+		}
+	}
+}
+
+func TestTransformOperationPathsSeparately(t *testing.T) {
+	so := AppContext().SegmentOperators()
+	cut, _ := path.ParsePathFromSvg("M 0 0 L 2 0 L 2 2 L 0 2 L 0 0")
+	score, _ := path.ParsePathFromSvg("M 0.5 0.5 L 0.5 1.5")
+	ops := []OperationPath{
+		{Operation: Cut, Path: cut},
+		{Operation: Score, Path: score},
+	}
+	tests := []struct {
+		transform path.PathTransform
+		expected  []string
+	}{
+		// point transforms are measured on all the paths
+		{transforms.MirrorTransform{Axis: transforms.Vertical, SegmentOperators: so}, []string{
+			"M 2.000 0.000 L 0.000 0.000 L 0.000 2.000 L 2.000 2.000 L 2.000 0.000",
+			"M 1.500 0.500 L 1.500 1.500",
+		}},
+		{transforms.ScaleTransform{Width: 4, SegmentOperators: so}, []string{
+			"M 0.000 0.000 L 4.000 0.000 L 4.000 4.000 L 0.000 4.000 L 0.000 0.000",
+			"M 1.000 1.000 L 1.000 3.000",
+		}},
+		// other transforms only change the cut
+		{transforms.PathReverse{}, []string{
+			"M 0.000 0.000 L 0.000 2.000 L 2.000 2.000 L 2.000 0.000 L 0.000 0.000 M 0.000 0.000",
+			"M 0.500 0.500 L 0.500 1.500",
+		}},
+	}
+	for _, test := range tests {
+		transformed, err := transformOperationPaths(test.transform, ops)
+		if err != nil {
+			t.Fatalf("Error %s", err)
+		}
+		for i, op := range transformed {
+			actual := path.SvgString(op.Path, 3)
+			if actual != test.expected[i] {
+				t.Errorf("%T %s expected: %s\nActual: %s", test.transform, op.Operation, test.expected[i], actual)
+			}
+		}
+	}
+}
+
+func TestWriteSVGOperations(t *testing.T) {
+	rp := testRenderedPart("box", "M 0 0 L 2 0 L 2 2 L 0 2 L 0 0 M 1 0.5 L 1 1.5")
+	cut, _ := path.ParsePathFromSvg("M 0 0 L 2 0 L 2 2 L 0 2 L 0 0")
+	score, _ := path.ParsePathFromSvg("M 1 0.5 L 1 1.5")
+	rp.Operations = []OperationPath{
+		{Operation: Cut, Path: cut},
+		{Operation: Score, Path: score},
+	}
+
+	doc := NewSVGDocument(10, 10, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	_, err := doc.Add(rp, RenderContext{})
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	ops := doc.Operations()
+	if len(ops) != 2 || ops[0] != Score || ops[1] != Cut {
+		t.Errorf("Expected score then cut, got %v", ops)
+	}
+
+	buf := &bytes.Buffer{}
+	doc.WriteSVG(RenderContext{}, buf)
+	svg := buf.String()
+	scoreGroup := strings.Index(svg, "<g id=\"score\">")
+	cutGroup := strings.Index(svg, "<g id=\"cut\">")
+	if scoreGroup < 0 || cutGroup < scoreGroup {
+		t.Errorf("Expected score group before cut group\n%s", svg)
+	}
+	if !strings.Contains(svg, "id=\"box_score\"") {
+		t.Errorf("Expected path with id box_score\n%s", svg)
+	}
+	if !strings.Contains(svg, doc.ScoreStyle) {
+		t.Errorf("Expected score style %s\n%s", doc.ScoreStyle, svg)
+	}
+}
@@ -263,6 +263,24 @@ func (b *Attr) String(param string) (string, bool) {
 	return v, ok
 }
 
+func (b *Attr) DynMap(param string) (*dynmap.DynMap, bool) {
+	v, ok := b.lookup(param, 0)
+	if !ok {
+		return nil, false
+	}
+	return dynmap.ToDynMap(v)
+}
+
+func (b *Attr) DynMapSlice(param string) ([]*dynmap.DynMap, bool) {
+	v, ok := b.lookup(param, 0)
+	if !ok {
+		return nil, false
+	}
+	mp := dynmap.New()
+	mp.Put(param, v)
+	return mp.GetDynMapSlice(param)
+}
+
 func (b *Attr) Bool(param string) (bool, bool) {
 	bl, ok := b.String(param)
 	if !ok {
@@ -358,11 +376,15 @@ func (p *BasicParamLookerUpper) localLookup(param string, recursion int) (interf
 	if !found {
 		// check if this is a Document. if so look in the document context
 		switch c := p.element.(type) {
+		case *Part:
+			if c.materialParams != nil {
+				v, found = c.materialParams.Get(param)
+			}
 		case *Document:
-			v, found = c.Context.Params.Get(param)
+			v, found = c.Context.lookup(param)
 		case *BasicElement:
 			if c.doc != nil {
-				v, found = c.doc.Context.Params.Get(param)
+				v, found = c.doc.Context.lookup(param)
 			}
 		}
 	}
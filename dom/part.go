@@ -3,6 +3,7 @@ package dom
 import (
 	"fmt"
 
+	"github.com/dustismo/heavyfishdesign/binpacking"
 	"github.com/dustismo/heavyfishdesign/dynmap"
 	"github.com/dustismo/heavyfishdesign/path"
 	"github.com/dustismo/heavyfishdesign/transforms"
@@ -13,6 +14,9 @@ import (
 type Part struct {
 	*BasicComponent
 	PartTransformers []PartTransformer
+	// the params of the part material, nil if the part uses the
+	// document material
+	materialParams *dynmap.DynMap
 }
 
 type Label struct {
@@ -21,13 +25,67 @@ type Label struct {
 }
 
 type RenderedPart struct {
-	Part   *Part
-	Path   path.Path
-	Width  float64
-	Height float64
-	MinX   float64 // bbox min X (path can extend outside 0..Width in design)
-	MinY   float64 // bbox min Y
-	Label  Label
+	Part *Part
+	// all the paths for the part, this is used for layout
+	Path path.Path
+	// the path for each operation, in the same coordinates as Path.
+	// see OperationPaths
+	Operations []OperationPath
+	Width      float64
+	Height     float64
+	MinX       float64 // bbox min X (path can extend outside 0..Width in design)
+	MinY       float64 // bbox min Y
+	Label      Label
+	// the operation paths before holding tabs were added, nil if the
+	// part has no tabs.  see OutlinePaths
+	Outline []OperationPath
+	// the repeat index of the part
+	Index int
+	// the part transformers that created this part, with the index
+	// of the result (splitter:1)
+	Lineage []string
+	// how the part may be rotated during layout, see layoutRotation
+	Rotation binpacking.Rotation
+	// true if Rotation comes from the grain of the part, then it can
+	// also be turned by 180 degrees
+	GrainRotation bool
+	// the fixed position of the part, nil if it is laid out normally
+	Pin *Pin
+}
+
+// the path for each operation, in the order they should be run.
+// Parts without Operations (for instance from a part transformer) are
+// a single path with the part operation
+func (rp *RenderedPart) OperationPaths() []OperationPath {
+	if len(rp.Operations) == 0 {
+		op := Cut
+		if rp.Part.Document() != nil {
+			o, err := elementOperation(rp.Part, Cut)
+			if err == nil {
+				op = o
+			}
+		}
+		return []OperationPath{{Operation: op, Path: rp.Path}}
+	}
+	ops := []OperationPath{}
+	for _, op := range Operations {
+		for _, o := range rp.Operations {
+			if o.Operation == op {
+				ops = append(ops, o)
+			}
+		}
+	}
+	return ops
+}
+
+// the operation paths with closed outlines, before any holding tabs
+// opened them up.  This is the shape of the part for nesting and for its
+// area, the cuts come from OperationPaths
+func (rp *RenderedPart) OutlinePaths() []OperationPath {
+	if rp.Outline != nil {
+		return rp.Outline
+	}
+	return rp.OperationPaths()
 }
 
 type PartTransformer interface {
@@ -56,6 +114,16 @@ func (pf PartFactory) CreateComponent(componentType string, mp *dynmap.DynMap, d
 	p := &Part{
 		BasicComponent: bc,
 	}
+	material, ok := paramValue(bc.Params(), "material")
+	if !ok {
+		material, ok = dm.Get("material")
+	}
+	if ok && dc != nil && dc.docParams != nil {
+		p.materialParams, err = materialGroupParams(dc.docParams, dynmap.ToString(material))
+		if err != nil {
+			return nil, fmt.Errorf("Error in material for part %s: %s", p.Id(), err.Error())
+		}
+	}
 	for _, c := range components {
 		c.SetParent(p)
 	}
@@ -67,6 +135,22 @@ func (pf PartFactory) ComponentTypes() []string {
 	return []string{"part"}
 }
 
+// looks up params in the part, then the part material, then the document
+func (p *Part) ParamLookerUpper() ParamLookerUpper {
+	return &BasicParamLookerUpper{element: p}
+}
+
+func (p *Part) Attr() *Attr {
+	return p.DmAttr(p.ToDynMap())
+}
+
+func (p *Part) DmAttr(mp *dynmap.DynMap) *Attr {
+	return &Attr{
+		element: p,
+		mp:      mp,
+	}
+}
+
 // gets the owning document
 func (p *Part) Document() *Document {
 	d := p.Parent()
@@ -86,7 +170,7 @@ func (p *Part) RenderPart(ctx RenderContext) ([]*RenderedPart, error) {
 	for i := 0; i < repeat; i++ {
 		context := ctx.Clone()
 		p.SetLocalVariable("part_index", i)
-		pth, _, err := p.Render(context)
+		pth, ops, _, err := p.renderOperations(context)
 		if err != nil {
 			return nil, err
 		}
@@ -96,12 +180,13 @@ func (p *Part) RenderPart(ctx RenderContext) ([]*RenderedPart, error) {
 			return nil, err
 		}
 		// trim any whitespace (shifts path so bbox min becomes 0,0)
-		pth, err = transforms.TrimWhitespaceTransform{
+		ops, err = transformOperationPaths(transforms.TrimWhitespaceTransform{
 			SegmentOperators: AppContext().SegmentOperators(),
-		}.PathTransform(pth)
+		}, ops)
 		if err != nil {
 			return nil, err
 		}
+		pth = joinOperationPaths(ops)
 
 		tl, br, err := path.BoundingBoxTrimWhitespace(pth, AppContext().SegmentOperators())
 		if err != nil {
@@ -119,28 +204,40 @@ func (p *Part) RenderPart(ctx RenderContext) ([]*RenderedPart, error) {
 			label.Text = fmt.Sprintf("%s:%d", label.Text, i)
 		}
 		renderedParts = append(renderedParts, &RenderedPart{
-			Part:   p,
-			Path:   pth,
-			Width:  width,
-			Height: height,
-			MinX:   tlPre.X,
-			MinY:   tlPre.Y,
-			Label:  label,
+			Part:       p,
+			Path:       pth,
+			Operations: ops,
+			Width:      width,
+			Height:     height,
+			MinX:       tlPre.X,
+			MinY:       tlPre.Y,
+			Label:      label,
+			Index:      i,
 		})
 	}
 
 	// create the part transformers
-	transforms, err := AppContext().MakePartTransformers(p.originalMap.MustDynMapSlice("part_transformers", []*dynmap.DynMap{}), p)
+	transformDms := p.originalMap.MustDynMapSlice("part_transformers", []*dynmap.DynMap{})
+	transforms, err := AppContext().MakePartTransformers(transformDms, p)
 	if err != nil {
 		return nil, err
 	}
-	for _, pt := range transforms {
+	p.PartTransformers = transforms
+	for i, pt := range transforms {
+		transformType := transformDms[i].MustString("type", "unknown")
 		renderedPartsTmp := []*RenderedPart{}
 		for _, rp := range renderedParts {
 			rps, err := pt.TransformPart(rp, ctx)
 			if err != nil {
 				return nil, err
 			}
+			for j, r := range rps {
+				if r == rp {
+					continue
+				}
+				r.Index = rp.Index
+				r.Lineage = append(append([]string{}, rp.Lineage...), fmt.Sprintf("%s:%d", transformType, j))
+			}
 			renderedPartsTmp = append(renderedPartsTmp, rps...)
 		}
 		renderedParts = renderedPartsTmp
@@ -152,55 +249,80 @@ func (p *Part) RenderPart(ctx RenderContext) ([]*RenderedPart, error) {
 // typically RenderPart should be used instead as that will honor the
 // repeats or splits
 func (p *Part) Render(ctx RenderContext) (path.Path, RenderContext, error) {
-	var pth path.Path
+	pth, _, context, err := p.renderOperations(ctx)
+	return pth, context, err
+}
+
+// renders the part, keeping the paths for each operation separate.
+// The operation for each child component defaults to the operation of the part.
+// Returns the combined path, and the path for each operation.
+func (p *Part) renderOperations(ctx RenderContext) (path.Path, []OperationPath, RenderContext, error) {
 	context := ctx.Clone()
+	partOperation, err := elementOperation(p, Cut)
+	if err != nil {
+		return nil, nil, context, err
+	}
 	paths := []path.Path{}
+	ops := []OperationPath{}
 	for _, e := range p.Children() {
 		component, ok := e.(Component)
 		if !ok {
-			return nil, ctx, fmt.Errorf("Error, part children must be components")
+			return nil, nil, ctx, fmt.Errorf("Error, part children must be components")
+		}
+		if component.Hidden() {
+			continue
 		}
 
 		p1, cTmp, err := component.Render(context)
 		if err != nil {
-			return p1, context, err
+			return p1, nil, context, err
 		}
 		context = cTmp
 		// find the new cursor location
 		context.Cursor = path.PathCursor(p1)
 		paths = append(paths, p1)
+
+		op, err := elementOperation(component, partOperation)
+		if err != nil {
+			return p1, nil, context, err
+		}
+		ops = addOperationPath(ops, op, p1)
 	}
 	if len(paths) == 0 {
-		return nil, context, fmt.Errorf("Error no path found to render in \n%s", p.ToDynMap().ToJSON())
+		return nil, nil, context, fmt.Errorf("Error no path found to render in \n%s", p.ToDynMap().ToJSON())
 	}
-	pth = transforms.SimpleJoin{}.JoinPaths(paths...)
+	if len(ops) == 1 {
+		// keep the original component order
+		ops[0].Path = transforms.SimpleJoin{}.JoinPaths(paths...)
+	}
+	pth := joinOperationPaths(ops)
 	context.Cursor = path.PathCursor(pth)
 
-	p1, c1, err := p.HandleTransforms(p, pth, context)
-
+	// handle the transforms, on each operation path
+	pth, context, err = p.applyTransforms(p, pth, context, func(t path.PathTransform, _ path.Path) (path.Path, error) {
+		var err error
+		ops, err = transformOperationPaths(t, ops)
+		return joinOperationPaths(ops), err
+	})
 	if err != nil {
-		return p1, c1, err
+		return pth, ops, context, err
 	}
 
 	// now trim any whitespace and measure
 	// calculate the width and height
-	p2, err := transforms.TrimWhitespaceTransform{
+	ops, err = transformOperationPaths(transforms.TrimWhitespaceTransform{
 		SegmentOperators: AppContext().SegmentOperators(),
-	}.PathTransform(p1)
+	}, ops)
 	if err != nil {
-		return p2, c1, err
+		pth = joinOperationPaths(ops)
+		return pth, ops, context, err
 	}
 
 	// collapse multiple Moves
 	// TODO: this should be a transform, or something..
-	sgs := []path.Segment{}
-	for _, s := range p2.Segments() {
-		if path.IsMove(s) {
-			sgs = path.TrimTailMove(sgs)
-		}
-		sgs = append(sgs, s)
+	for i, op := range ops {
+		ops[i].Path = collapseMoves(op.Path)
 	}
-	p2 = path.NewPathFromSegments(sgs)
-
-	return p2, c1, err
+	pth = joinOperationPaths(ops)
+	return pth, ops, context, nil
 }
@@ -25,3 +25,14 @@ func (pf PartLatheTransformerFactory) CreateTransformer(transformType string, dm
 func (cf PartLatheTransformerFactory) TransformerTypes() []string {
 	return []string{"lathe"}
 }
+
+type TabsPartTransformerFactory struct{}
+
+func (pf TabsPartTransformerFactory) CreateTransformer(transformType string, dm *dynmap.DynMap, part *Part) (PartTransformer, error) {
+	return &TabsPartTransform{mp: dm}, nil
+}
+
+// The list of component types this Factory should be used for
+func (cf TabsPartTransformerFactory) TransformerTypes() []string {
+	return []string{"tabs"}
+}
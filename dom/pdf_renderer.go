@@ -0,0 +1,198 @@
+package dom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// PDF output.
+// Each document is written as a single page at true physical size, so
+// plans can be printed at 1:1 scale.  Paths are written with the pdf bezier
+// operators, labels use the base 14 Times-Roman font so nothing needs to be
+// embedded.
+
+// pdf user space is 1/72 inch
+func pdfPointsPerUnit(u Units) float64 {
+	return 72 / u.FromInch(1)
+}
+
+// the characters of Windows-1252 from 0x80 to 0x9f, the rest of the
+// printable characters are the same as unicode
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// a pdf string literal in the WinAnsiEncoding of the label font.
+// Characters the encoding does not have are written as ?, the special
+// characters are escaped and the rest of the non ascii characters are
+// written as octal escapes
+func pdfString(s string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('(')
+	for _, r := range s {
+		c, ok := winAnsiSpecials[r]
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n' || r == '\r':
+			buf.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f:
+			buf.WriteRune(r)
+		case ok:
+			fmt.Fprintf(buf, "\\%03o", c)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(buf, "\\%03o", r)
+		default:
+			buf.WriteByte('?')
+		}
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+// the rgb stroke color for each operation, matches the svg defaults
+func pdfOperationColor(op Operation) string {
+	switch op {
+	case Score:
+		return "0.5 0.5 0.5"
+	case Engrave:
+		return "0 0 1"
+	}
+	return "0 0 0"
+}
+
+// writes the page content stream for the document
+func (d *SVGDocument) pdfContent(ctx RenderContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	f := func(v float64) string {
+		return fmt.Sprintf("%.*f", d.Precision, v)
+	}
+	scale := pdfPointsPerUnit(d.Units)
+	// draw in document units, with the y axis pointing down like svg
+	fmt.Fprintf(buf, "%.4f 0 0 %.4f 0 %.4f cm\n", scale, -scale, d.Height*scale)
+	fmt.Fprintf(buf, "%s w\n", f(d.Units.FromMM(.3)))
+	fmt.Fprintf(buf, "1 J 1 j\n")
+	color := pdfOperationColor(Cut)
+	fmt.Fprintf(buf, "%s RG\n", color)
+
+	for _, r := range d.renderables {
+		for _, op := range r.renderedPart.OperationPaths() {
+			pth, err := r.documentOperationPath(op.Path)
+			if err != nil {
+				return nil, err
+			}
+			if c := pdfOperationColor(op.Operation); c != color {
+				color = c
+				fmt.Fprintf(buf, "%s RG\n", color)
+			}
+			var cursor *path.Point
+			for _, seg := range path.ArcsToCurves(pth.Segments()) {
+				if path.IsMove(seg) {
+					continue
+				}
+				start := seg.Start()
+				if cursor == nil || !cursor.EqualsPrecision(start, d.Precision) {
+					fmt.Fprintf(buf, "%s %s m\n", f(start.X), f(start.Y))
+				}
+				switch s := seg.(type) {
+				case path.CurveSegment:
+					fmt.Fprintf(buf, "%s %s %s %s %s %s c\n",
+						f(s.ControlPointStart.X), f(s.ControlPointStart.Y),
+						f(s.ControlPointEnd.X), f(s.ControlPointEnd.Y),
+						f(s.EndPoint.X), f(s.EndPoint.Y))
+				default:
+					fmt.Fprintf(buf, "%s %s l\n", f(seg.End().X), f(seg.End().Y))
+				}
+				end := seg.End()
+				cursor = &end
+			}
+			if cursor != nil {
+				fmt.Fprintf(buf, "S\n")
+			}
+		}
+
+		if len(r.renderedPart.Label.Text) > 0 {
+			textPos, err := r.documentLabelPosition()
+			if err != nil {
+				return nil, err
+			}
+			// the text matrix flips the text back upright, and
+			// rotates it with the part
+			matrix := "1 0 0 -1"
+			if r.rotate {
+				matrix = "0 1 1 0"
+			} else if r.angle != 0 {
+				rad := r.angle * math.Pi / 180
+				cos, sin := math.Cos(rad), math.Sin(rad)
+				matrix = fmt.Sprintf("%.4f %.4f %.4f %.4f", cos, sin, sin, -cos)
+			}
+			fmt.Fprintf(buf, "BT /F1 %s Tf 0 0 1 rg %s %s %s Tm %s Tj ET\n",
+				f(d.Units.FromMM(3)),
+				matrix, f(textPos.X), f(textPos.Y),
+				pdfString(r.renderedPart.Label.Text))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writes the documents into a single pdf, one page per document
+func WritePDF(docs []*SVGDocument, ctx RenderContext, writer io.Writer) error {
+	buf := &bytes.Buffer{}
+	offsets := []int{}
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// page objects start after the catalog, pages and font
+	kids := []string{}
+	for i := range docs {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+2*i))
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(docs)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Times-Roman /Encoding /WinAnsiEncoding >>")
+
+	for i, d := range docs {
+		scale := pdfPointsPerUnit(d.Units)
+		content, err := d.pdfContent(ctx)
+		if err != nil {
+			return err
+		}
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.3f %.3f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			d.Width*scale, d.Height*scale, 5+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n", len(offsets)+1)
+	buf.WriteString("0000000000 65535 f \n")
+	for _, o := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := writer.Write(buf.Bytes())
+	return err
+}
+
+// writes the document as a single page pdf
+func (d *SVGDocument) WritePDF(ctx RenderContext, writer io.Writer) error {
+	return WritePDF([]*SVGDocument{d}, ctx, writer)
+}
+
+// writes all the documents into a single multi page pdf
+func (p *PlanSet) WritePDF(ctx RenderContext, writer io.Writer) error {
+	return WritePDF(p.svgDocs, ctx, writer)
+}
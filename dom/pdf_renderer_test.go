@@ -0,0 +1,74 @@
+package dom
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+func TestWritePDF(t *testing.T) {
+	docs := []*SVGDocument{}
+	for i := 0; i < 2; i++ {
+		doc := NewSVGDocument(10, 5, Inches)
+		doc.SegmentOperators = AppContext().SegmentOperators()
+		part := testRenderedPart(fmt.Sprintf("part_%d", i), "M 0 0 L 2 0 C 3 0 3 1 2 1 L 0 1")
+		part.Label = Label{Text: "part (a)", Position: path.BottomMiddle}
+		_, err := doc.Add(part, RenderContext{})
+		if err != nil {
+			t.Errorf("Error %s", err)
+		}
+		docs = append(docs, doc)
+	}
+	buf := &bytes.Buffer{}
+	err := WritePDF(docs, RenderContext{}, buf)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Errorf("Expected pdf header and trailer")
+	}
+	if !strings.Contains(pdf, "/Count 2") {
+		t.Errorf("Expected 2 pages\n%s", pdf)
+	}
+	if !strings.Contains(pdf, "/MediaBox [0 0 720.000 360.000]") {
+		t.Errorf("Expected page size to be 10 x 5 inches\n%s", pdf)
+	}
+	if strings.Count(pdf, " c\n") != 2 {
+		t.Errorf("Expected a curve on each page\n%s", pdf)
+	}
+	if !strings.Contains(pdf, `(part \(a\)) Tj`) {
+		t.Errorf("Expected escaped label\n%s", pdf)
+	}
+
+	// the xref offsets should point at the objects
+	var xref int
+	fmt.Sscanf(pdf[strings.LastIndex(pdf, "startxref")+len("startxref\n"):], "%d", &xref)
+	if !strings.HasPrefix(pdf[xref:], "xref") {
+		t.Errorf("Expected startxref to point to the xref table")
+	}
+	for i, line := range strings.Split(pdf[xref:], "\n")[3:9] {
+		var offset int
+		fmt.Sscanf(line, "%d", &offset)
+		if !strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj", i+1)) {
+			t.Errorf("Expected object %d at offset %d", i+1, offset)
+		}
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := map[string]string{
+		`a (b) \c`:    `(a \(b\) \\c)`,
+		"line\nbreak": `(line break)`,
+		"café €5":     `(caf\351 \2005)`,
+		"日本 ✓":        `(?? ?)`,
+	}
+	for s, expected := range tests {
+		if actual := pdfString(s); actual != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, s, actual)
+		}
+	}
+}
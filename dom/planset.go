@@ -3,6 +3,9 @@ package dom
 import (
 	"archive/zip"
 	"fmt"
+
+	"github.com/dustismo/heavyfishdesign/binpacking"
+	"github.com/dustismo/heavyfishdesign/dynmap"
 )
 
 // a collection of documents
@@ -14,6 +17,24 @@ type PlanSet struct {
 	// to render
 	doc     *Document
 	svgDocs []*SVGDocument
+	// the nested part outlines, shared by all the layout passes
+	nestShapes *nestShapes
+	// the available stock sheets, nil to use as many
+	// material_width x material_height sheets as needed
+	stock []*StockSheet
+	// the areas to keep out of, and the parts at fixed positions
+	keepOuts []*KeepOut
+	pinned   []*RenderedPart
+	// the material of the parts, empty if the parts do not name one
+	material string
+	// the params of the material group, nil to use the document params
+	materialParams *dynmap.DynMap
+	// the layout of each material, when the parts use more than one
+	groups []*PlanSet
+	// the stock used by the material groups laid out before this one
+	stockUsed map[*StockSheet]int
+	// the stock sheets filled while picking the stock, for this layout
+	stockFills map[stockFillKey]stockFill
 }
 
 func NewPlanSet(doc *Document) *PlanSet {
@@ -27,19 +48,85 @@ func (p *PlanSet) Document() *Document {
 	return p.doc
 }
 
-func (p *PlanSet) createSvgDoc(ctx RenderContext) *SVGDocument {
-	attr := p.doc.Attr()
+// the params for the layout, the material group params and then the
+// document params
+func (p *PlanSet) attr() *Attr {
+	if p.materialParams == nil {
+		return p.doc.Attr()
+	}
+	return NewAttr(p.doc, p.materialParams)
+}
+
+// a plan set for the parts of the material
+func (p *PlanSet) materialGroup(material string) (*PlanSet, error) {
+	params, err := materialGroupParams(p.doc.Params(), material)
+	if err != nil {
+		return nil, fmt.Errorf("Error in material %s: %s", material, err.Error())
+	}
+	return &PlanSet{
+		doc:            p.doc,
+		material:       material,
+		materialParams: params,
+	}, nil
+}
+
+// the plan set that laid out the document, the material group or p
+func (p *PlanSet) documentGroup(d *SVGDocument) *PlanSet {
+	for _, g := range p.groups {
+		if g.material == d.Material {
+			return g
+		}
+	}
+	return p
+}
+
+// creates a new document, the size is from the stock sheet or the
+// material_width and material_height if stock is nil.  The keep out areas
+// and pinned parts for the sheet index are added
+func (p *PlanSet) createSvgDoc(ctx RenderContext, stock *StockSheet, index int) *SVGDocument {
+	attr := p.attr()
+	width := attr.MustFloat64("material_width", 20)
+	height := attr.MustFloat64("material_height", 12)
+	if stock != nil {
+		width = stock.Width
+		height = stock.Height
+	}
 	svgDoc := NewSVGDocument(
-		attr.MustFloat64("material_width", 20),
-		attr.MustFloat64("material_height", 12),
+		width,
+		height,
 		MustUnits(attr.MustString("measurement_units", "in"), Inches),
 	)
+	svgDoc.Stock = stock
+	svgDoc.Material = p.material
 
 	svgDoc.SegmentOperators = AppContext().SegmentOperators()
 	svgDoc.Padding = attr.MustFloat64(
 		"doc_padding",
 		.1,
 	)
+	svgDoc.CurveTolerance = attr.MustFloat64(
+		"curve_tolerance",
+		svgDoc.CurveTolerance,
+	)
+	svgDoc.GCode = gcodeSettingsFromAttr(attr, svgDoc.Units)
+	svgDoc.DPI = attr.MustFloat64("png_dpi", svgDoc.DPI)
+	svgDoc.Laser = laserSettingsFromAttr(attr)
+	svgDoc.Layers = MustLayerStrategy(attr.MustString("svg_layers", "none"), NoLayers)
+	layoutStrategy := attr.MustString("layout_strategy", "guillotine")
+	svgDoc.SetLayoutStrategy(binpacking.MustStrategy(layoutStrategy, binpacking.Guillotine))
+	svgDoc.Nest = layoutStrategy == NestLayout
+	svgDoc.NestRotations = MustNestRotations(attr.MustString("nest_rotations", ""), svgDoc.NestRotations)
+	if p.nestShapes == nil {
+		p.nestShapes = newNestShapes()
+	}
+	svgDoc.nestShapes = p.nestShapes
+	for _, op := range Operations {
+		color, ok := attr.String(string(op) + "_color")
+		if ok {
+			svgDoc.SetOperationColor(op, color)
+		}
+	}
+	p.prepareSheet(svgDoc, index)
 	return svgDoc
 }
 
@@ -47,7 +134,17 @@ func (p *PlanSet) SVGDocuments() []*SVGDocument {
 	return p.svgDocs
 }
 
-func (p *PlanSet) addPart(part *RenderedPart, ctx RenderContext) (bool, error) {
+// true if the parts are laid out in more than one material group, then
+// the documents of each material are named after it
+func (p *PlanSet) HasMaterialGroups() bool {
+	return len(p.groups) > 1
+}
+
+// adds the part to the first document it fits in, or a new document.
+// remaining is the part and the rest of the parts to be added, used to
+// pick the stock sheet for a new document
+func (p *PlanSet) addPart(remaining []*RenderedPart, available map[*StockSheet]int, ctx RenderContext) (bool, error) {
+	part := remaining[0]
 	// first try to add to all the existing open docs
 	for _, svgDoc := range p.svgDocs {
 		added, err := svgDoc.Add(part, ctx)
@@ -59,46 +156,202 @@ func (p *PlanSet) addPart(part *RenderedPart, ctx RenderContext) (bool, error) {
 		}
 	}
 
-	// add a new svgDoc and try to add it
-	svgDoc := p.createSvgDoc(ctx)
-	added, err := svgDoc.Add(part, ctx)
-	if err != nil {
-		return false, err
-	}
-	if added {
+	// add a new svgDoc and try to add it.  If it does not fit around the
+	// pinned parts on the new sheet try the next one
+	for {
+		svgDoc := p.createSvgDoc(ctx, nil, len(p.svgDocs))
+		if p.UsesStock() {
+			var err error
+			svgDoc, err = p.nextStockDoc(ctx, remaining, available)
+			if err != nil {
+				return false, err
+			}
+		}
+		added, err := svgDoc.Add(part, ctx)
+		if err != nil {
+			return false, err
+		}
+		if added {
+			p.svgDocs = append(p.svgDocs, svgDoc)
+			return true, nil
+		}
+		if len(svgDoc.renderables) == 0 {
+			return false, nil
+		}
 		p.svgDocs = append(p.svgDocs, svgDoc)
-		return true, nil
 	}
-	return false, nil
 }
 
+// renders the parts and lays them out.  Parts of each material are laid
+// out on their own sheets, materials in the order they are first used
 func (p *PlanSet) InitWithPartsFilter(ctx RenderContext, filter func(p *RenderedPart) bool) error {
-	// create the first svgdoc
-	p.svgDocs = []*SVGDocument{
-		p.createSvgDoc(ctx),
-	}
-
 	// render all the parts..
 	// this is necessary in order to get the measurements
+	log := ctx.Logger()
+	groups := map[string]*PlanSet{}
+	materials := []string{}
+	parts := map[string][]*RenderedPart{}
 	for _, part := range p.doc.Parts {
+		material := part.Attr().MustString("material", "")
+		group, ok := groups[material]
+		if !ok {
+			var err error
+			group, err = p.materialGroup(material)
+			if err != nil {
+				return err
+			}
+			groups[material] = group
+			materials = append(materials, material)
+		}
 		renderedParts, err := part.RenderPart(ctx)
 		if err != nil {
 			println(err.Error())
 			return err
 		}
-		for _, renderedPart := range renderedParts {
+		renderedParts, err = group.addTabs(part, renderedParts, ctx)
+		if err != nil {
+			return err
+		}
+		sheetGrain := materialGrain(group.attr(), log)
+		rotation := layoutRotation(part, sheetGrain, log)
+		grainRotation := layoutGrainRotation(part, sheetGrain)
+		pin := layoutPin(part)
+		if pin != nil && len(renderedParts) > 1 {
+			log.Errorf("Part %s is pinned but renders %d parts, only the first is pinned", part.Id(), len(renderedParts))
+		}
+		for i, renderedPart := range renderedParts {
+			renderedPart.Rotation = rotation
+			renderedPart.GrainRotation = grainRotation
+			if i == 0 {
+				renderedPart.Pin = pin
+			}
 			if filter(renderedPart) {
-				added, err := p.addPart(renderedPart, ctx)
-				if err != nil {
-					println(err.Error())
-					return err
-				}
-				if !added {
-					// too big.  try again?
-					return fmt.Errorf("unable to add part, it is probably too big")
-				}
+				parts[material] = append(parts[material], renderedPart)
+			}
+		}
+	}
+
+	// each material group can list its own stock
+	loadedStock := map[string][]*StockSheet{}
+	p.stockUsed = map[*StockSheet]int{}
+	if len(materials) <= 1 {
+		if len(materials) == 1 {
+			p.material = materials[0]
+			p.materialParams = groups[p.material].materialParams
+		}
+		var err error
+		p.stock, err = p.loadStock(loadedStock)
+		if err != nil {
+			return err
+		}
+		return p.layoutParts(ctx, parts[p.material])
+	}
+
+	p.svgDocs = []*SVGDocument{}
+	p.groups = []*PlanSet{}
+	p.stock = nil
+	for _, material := range materials {
+		group := groups[material]
+		var err error
+		group.stock, err = group.loadStock(loadedStock)
+		if err != nil {
+			return fmt.Errorf("Error in stock for material %s: %s", material, err.Error())
+		}
+		// the stock report lists every sheet of all the groups
+		for _, s := range group.stock {
+			if !containsStock(p.stock, s) {
+				p.stock = append(p.stock, s)
+			}
+		}
+		group.stockUsed = p.stockUsed
+		err = group.layoutParts(ctx, parts[material])
+		if err != nil {
+			return fmt.Errorf("Error laying out material %s: %s", material, err.Error())
+		}
+		for _, d := range group.svgDocs {
+			if d.Stock != nil {
+				p.stockUsed[d.Stock]++
 			}
 		}
+		p.svgDocs = append(p.svgDocs, group.svgDocs...)
+		p.groups = append(p.groups, group)
+	}
+	return nil
+}
+
+// lays out the parts of a single material, trying each ordering and
+// keeping the best layout
+func (p *PlanSet) layoutParts(ctx RenderContext, parts []*RenderedPart) error {
+	log := ctx.Logger()
+	var err error
+	p.keepOuts, err = p.loadKeepOuts()
+	if err != nil {
+		return err
+	}
+	// the pinned parts are placed on each sheet as it is created
+	p.pinned = []*RenderedPart{}
+	unpinned := []*RenderedPart{}
+	for _, part := range parts {
+		if part.Pin != nil {
+			p.pinned = append(p.pinned, part)
+		} else {
+			unpinned = append(unpinned, part)
+		}
+	}
+	p.checkPins(log)
+
+	// try each ordering and keep the best layout
+	randomPasses := p.attr().MustInt("layout_random_passes", 5)
+	var best []*SVGDocument
+	for _, ordering := range layoutOrderings(unpinned, randomPasses) {
+		err := p.layout(ctx, ordering)
+		if err != nil {
+			return err
+		}
+		if best == nil || betterLayout(p.svgDocs, best) {
+			best = p.svgDocs
+		}
+	}
+	p.svgDocs = best
+	logRotationViolations(p.svgDocs, log)
+	return nil
+}
+
+// lays out the parts in the given order
+func (p *PlanSet) layout(ctx RenderContext, parts []*RenderedPart) error {
+	// create the first svgdoc, with stock the first document
+	// is picked based on the parts
+	p.svgDocs = []*SVGDocument{}
+	p.stockFills = map[stockFillKey]stockFill{}
+	available := map[*StockSheet]int{}
+	for _, s := range p.stock {
+		available[s] = p.stockAvailable(s)
+	}
+	if !p.UsesStock() {
+		p.svgDocs = append(p.svgDocs, p.createSvgDoc(ctx, nil, 0))
+	}
+	for i := range parts {
+		added, err := p.addPart(parts[i:], available, ctx)
+		if err != nil {
+			println(err.Error())
+			return err
+		}
+		if !added {
+			// too big.  try again?
+			return fmt.Errorf("unable to add part, it is probably too big")
+		}
+	}
+	// add the sheets for any pinned parts past the last sheet
+	for len(p.svgDocs) <= p.lastPinnedSheet() {
+		svgDoc := p.createSvgDoc(ctx, nil, len(p.svgDocs))
+		if p.UsesStock() {
+			var err error
+			svgDoc, err = p.pinnedStockDoc(ctx, available)
+			if err != nil {
+				return err
+			}
+		}
+		p.svgDocs = append(p.svgDocs, svgDoc)
 	}
 	return nil
 }
@@ -123,8 +376,14 @@ func positionForSplit(width, documentWidth, documentHeight float64) float64 {
 
 // renders all the documents into the passed in zip writer
 func (ps *PlanSet) RenderZip(filename string, w *zip.Writer, ctx RenderContext) error {
-	for i, svgDoc := range ps.svgDocs {
-		f, err := w.Create(fmt.Sprintf("%s.%d.svg", filename, i))
+	counts := map[string]int{}
+	for _, svgDoc := range ps.svgDocs {
+		name := filename
+		if ps.HasMaterialGroups() {
+			name = fmt.Sprintf("%s_%s", filename, svgDoc.Material)
+		}
+		f, err := w.Create(fmt.Sprintf("%s.%d.svg", name, counts[svgDoc.Material]))
+		counts[svgDoc.Material]++
 		if err != nil {
 			return err
 		}
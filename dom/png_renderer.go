@@ -0,0 +1,318 @@
+package dom
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// PNG output.
+// A small anti aliased rasterizer, so thumbnails can be rendered without
+// a browser.  Paths are flattened and stroked, labels are drawn with a
+// built in 5x7 bitmap font.
+
+var (
+	rasterBackground   = color.RGBA{255, 255, 255, 255}
+	rasterCutColor     = color.RGBA{0, 0, 0, 255}
+	rasterScoreColor   = color.RGBA{128, 128, 128, 255}
+	rasterEngraveColor = color.RGBA{0, 0, 255, 255}
+	rasterLabelColor   = color.RGBA{0, 0, 255, 255}
+	rasterSheetColor   = color.RGBA{160, 160, 160, 255}
+)
+
+// coverage for a single color, values are 0 - 1
+type rasterMask struct {
+	width  int
+	height int
+	cov    []float64
+}
+
+func newRasterMask(width, height int) *rasterMask {
+	return &rasterMask{
+		width:  width,
+		height: height,
+		cov:    make([]float64, width*height),
+	}
+}
+
+func (m *rasterMask) set(x, y int, c float64) {
+	if x < 0 || y < 0 || x >= m.width || y >= m.height || c <= 0 {
+		return
+	}
+	i := y*m.width + x
+	m.cov[i] = math.Max(m.cov[i], math.Min(c, 1))
+}
+
+// distance from p to the line segment a b
+func segmentDistance(p, a, b path.Point) float64 {
+	dx := b.X - a.X
+	dy := b.Y - a.Y
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return path.Distance(p, a)
+	}
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l2))
+	return path.Distance(p, path.NewPoint(a.X+t*dx, a.Y+t*dy))
+}
+
+// strokes the line from a to b, points and width are in pixels
+func (m *rasterMask) line(a, b path.Point, width float64) {
+	half := width / 2
+	x0 := int(math.Floor(math.Min(a.X, b.X) - half - 1))
+	x1 := int(math.Ceil(math.Max(a.X, b.X) + half + 1))
+	y0 := int(math.Floor(math.Min(a.Y, b.Y) - half - 1))
+	y1 := int(math.Ceil(math.Max(a.Y, b.Y) + half + 1))
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			d := segmentDistance(path.NewPoint(float64(x)+.5, float64(y)+.5), a, b)
+			m.set(x, y, half+.5-d)
+		}
+	}
+}
+
+// fills the rectangle, partially covered pixels get partial coverage
+func (m *rasterMask) rect(tl, br path.Point) {
+	for y := int(math.Floor(tl.Y)); y < int(math.Ceil(br.Y)); y++ {
+		for x := int(math.Floor(tl.X)); x < int(math.Ceil(br.X)); x++ {
+			cx := math.Min(br.X, float64(x+1)) - math.Max(tl.X, float64(x))
+			cy := math.Min(br.Y, float64(y+1)) - math.Max(tl.Y, float64(y))
+			m.set(x, y, cx*cy)
+		}
+	}
+}
+
+// blends the color onto the image based on coverage
+func (m *rasterMask) draw(img *image.RGBA, c color.RGBA) {
+	blend := func(bg, fg uint8, cov float64) uint8 {
+		return uint8(math.Round(float64(bg)*(1-cov) + float64(fg)*cov))
+	}
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			cov := m.cov[y*m.width+x]
+			if cov <= 0 {
+				continue
+			}
+			bg := img.RGBAAt(x, y)
+			img.SetRGBA(x, y, color.RGBA{
+				blend(bg.R, c.R, cov),
+				blend(bg.G, c.G, cov),
+				blend(bg.B, c.B, cov),
+				255,
+			})
+		}
+	}
+}
+
+// draws the text with the bitmap font. pos is the start of the baseline,
+// size is the font size, all in pixels.  The text is rotated clockwise by
+// angle degrees, the same as the part.
+func (m *rasterMask) text(txt string, pos path.Point, size float64, angle float64) {
+	// the glyphs are 7 rows tall, about the cap height of the font
+	s := size / 10
+	rad := angle * math.Pi / 180
+	// round so right angles are exact
+	cos := math.Round(math.Cos(rad)*1e9) / 1e9
+	sin := math.Round(math.Sin(rad)*1e9) / 1e9
+	// along the text, and up from the baseline
+	toPixel := func(along, up float64) path.Point {
+		return path.NewPoint(pos.X+along*cos+up*sin, pos.Y+along*sin-up*cos)
+	}
+	// the index of the glyph, range gives the byte index of each rune
+	i := 0
+	for _, r := range txt {
+		glyph, ok := rasterFont[r]
+		if !ok {
+			glyph = rasterFont['?']
+		}
+		for col, bits := range glyph {
+			for row := 0; row < 7; row++ {
+				if bits&(1<<uint(row)) == 0 {
+					continue
+				}
+				along := float64(i*6+col) * s
+				up := float64(7-row) * s
+				p1 := toPixel(along, up)
+				p2 := toPixel(along+s, up-s)
+				m.rect(
+					path.NewPoint(math.Min(p1.X, p2.X), math.Min(p1.Y, p2.Y)),
+					path.NewPoint(math.Max(p1.X, p2.X), math.Max(p1.Y, p2.Y)))
+			}
+		}
+		i++
+	}
+}
+
+// renders the document to an image at the document DPI
+func (d *SVGDocument) Rasterize(ctx RenderContext) (*image.RGBA, error) {
+	scale := d.DPI / d.Units.FromInch(1)
+	width := int(math.Ceil(d.Width * scale))
+	height := int(math.Ceil(d.Height * scale))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []uint8{rasterBackground.R, rasterBackground.G, rasterBackground.B, 255})
+	}
+	toPixel := func(p path.Point) path.Point {
+		return path.NewPoint(p.X*scale, p.Y*scale)
+	}
+	strokeWidth := math.Max(1, d.Units.FromMM(.3)*scale)
+
+	// sheet border
+	sheet := newRasterMask(width, height)
+	corners := []path.Point{
+		path.NewPoint(.5, .5),
+		path.NewPoint(float64(width)-.5, .5),
+		path.NewPoint(float64(width)-.5, float64(height)-.5),
+		path.NewPoint(.5, float64(height)-.5),
+	}
+	for i := range corners {
+		sheet.line(corners[i], corners[(i+1)%len(corners)], 1)
+	}
+	sheet.draw(img, rasterSheetColor)
+
+	// a mask for each operation
+	masks := map[Operation]*rasterMask{}
+	for _, op := range Operations {
+		masks[op] = newRasterMask(width, height)
+	}
+	labels := newRasterMask(width, height)
+	// flatten to within a quarter pixel
+	tolerance := .25 / scale
+	for _, r := range d.renderables {
+		for _, op := range r.renderedPart.OperationPaths() {
+			pth, err := r.documentOperationPath(op.Path)
+			if err != nil {
+				return nil, err
+			}
+			for _, polyline := range path.FlattenPath(pth, tolerance) {
+				for i := 1; i < len(polyline); i++ {
+					masks[op.Operation].line(toPixel(polyline[i-1]), toPixel(polyline[i]), strokeWidth)
+				}
+			}
+		}
+
+		if len(r.renderedPart.Label.Text) > 0 {
+			textPos, err := r.documentLabelPosition()
+			if err != nil {
+				return nil, err
+			}
+			labels.text(r.renderedPart.Label.Text, toPixel(textPos), d.Units.FromMM(3)*scale, r.labelAngle())
+		}
+	}
+	masks[Engrave].draw(img, rasterEngraveColor)
+	masks[Score].draw(img, rasterScoreColor)
+	masks[Cut].draw(img, rasterCutColor)
+	labels.draw(img, rasterLabelColor)
+	return img, nil
+}
+
+// writes the document as a png
+func (d *SVGDocument) WritePNG(ctx RenderContext, writer io.Writer) error {
+	img, err := d.Rasterize(ctx)
+	if err != nil {
+		return err
+	}
+	return png.Encode(writer, img)
+}
+
+// 5x7 font, each glyph is 5 columns, the low bit is the top row
+var rasterFont = map[rune][5]uint8{
+	' ':  {0x00, 0x00, 0x00, 0x00, 0x00},
+	'!':  {0x00, 0x00, 0x5F, 0x00, 0x00},
+	'"':  {0x00, 0x07, 0x00, 0x07, 0x00},
+	'#':  {0x14, 0x7F, 0x14, 0x7F, 0x14},
+	'$':  {0x24, 0x2A, 0x7F, 0x2A, 0x12},
+	'%':  {0x23, 0x13, 0x08, 0x64, 0x62},
+	'&':  {0x36, 0x49, 0x56, 0x20, 0x50},
+	'\'': {0x00, 0x00, 0x07, 0x00, 0x00},
+	'(':  {0x00, 0x1C, 0x22, 0x41, 0x00},
+	')':  {0x00, 0x41, 0x22, 0x1C, 0x00},
+	'*':  {0x2A, 0x1C, 0x7F, 0x1C, 0x2A},
+	'+':  {0x08, 0x08, 0x3E, 0x08, 0x08},
+	',':  {0x00, 0x50, 0x30, 0x00, 0x00},
+	'-':  {0x08, 0x08, 0x08, 0x08, 0x08},
+	'.':  {0x00, 0x60, 0x60, 0x00, 0x00},
+	'/':  {0x20, 0x10, 0x08, 0x04, 0x02},
+	'0':  {0x3E, 0x51, 0x49, 0x45, 0x3E},
+	'1':  {0x00, 0x42, 0x7F, 0x40, 0x00},
+	'2':  {0x42, 0x61, 0x51, 0x49, 0x46},
+	'3':  {0x21, 0x41, 0x45, 0x4B, 0x31},
+	'4':  {0x18, 0x14, 0x12, 0x7F, 0x10},
+	'5':  {0x27, 0x45, 0x45, 0x45, 0x39},
+	'6':  {0x3C, 0x4A, 0x49, 0x49, 0x30},
+	'7':  {0x01, 0x71, 0x09, 0x05, 0x03},
+	'8':  {0x36, 0x49, 0x49, 0x49, 0x36},
+	'9':  {0x06, 0x49, 0x49, 0x29, 0x1E},
+	':':  {0x00, 0x36, 0x36, 0x00, 0x00},
+	';':  {0x00, 0x56, 0x36, 0x00, 0x00},
+	'<':  {0x08, 0x14, 0x22, 0x41, 0x00},
+	'=':  {0x14, 0x14, 0x14, 0x14, 0x14},
+	'>':  {0x00, 0x41, 0x22, 0x14, 0x08},
+	'?':  {0x02, 0x01, 0x51, 0x09, 0x06},
+	'@':  {0x32, 0x49, 0x79, 0x41, 0x3E},
+	'A':  {0x7E, 0x11, 0x11, 0x11, 0x7E},
+	'B':  {0x7F, 0x49, 0x49, 0x49, 0x36},
+	'C':  {0x3E, 0x41, 0x41, 0x41, 0x22},
+	'D':  {0x7F, 0x41, 0x41, 0x22, 0x1C},
+	'E':  {0x7F, 0x49, 0x49, 0x49, 0x41},
+	'F':  {0x7F, 0x09, 0x09, 0x09, 0x01},
+	'G':  {0x3E, 0x41, 0x49, 0x49, 0x7A},
+	'H':  {0x7F, 0x08, 0x08, 0x08, 0x7F},
+	'I':  {0x00, 0x41, 0x7F, 0x41, 0x00},
+	'J':  {0x20, 0x40, 0x41, 0x3F, 0x01},
+	'K':  {0x7F, 0x08, 0x14, 0x22, 0x41},
+	'L':  {0x7F, 0x40, 0x40, 0x40, 0x40},
+	'M':  {0x7F, 0x02, 0x0C, 0x02, 0x7F},
+	'N':  {0x7F, 0x04, 0x08, 0x10, 0x7F},
+	'O':  {0x3E, 0x41, 0x41, 0x41, 0x3E},
+	'P':  {0x7F, 0x09, 0x09, 0x09, 0x06},
+	'Q':  {0x3E, 0x41, 0x51, 0x21, 0x5E},
+	'R':  {0x7F, 0x09, 0x19, 0x29, 0x46},
+	'S':  {0x46, 0x49, 0x49, 0x49, 0x31},
+	'T':  {0x01, 0x01, 0x7F, 0x01, 0x01},
+	'U':  {0x3F, 0x40, 0x40, 0x40, 0x3F},
+	'V':  {0x1F, 0x20, 0x40, 0x20, 0x1F},
+	'W':  {0x3F, 0x40, 0x38, 0x40, 0x3F},
+	'X':  {0x63, 0x14, 0x08, 0x14, 0x63},
+	'Y':  {0x07, 0x08, 0x70, 0x08, 0x07},
+	'Z':  {0x61, 0x51, 0x49, 0x45, 0x43},
+	'[':  {0x00, 0x7F, 0x41, 0x41, 0x00},
+	'\\': {0x02, 0x04, 0x08, 0x10, 0x20},
+	']':  {0x00, 0x41, 0x41, 0x7F, 0x00},
+	'^':  {0x04, 0x02, 0x01, 0x02, 0x04},
+	'_':  {0x40, 0x40, 0x40, 0x40, 0x40},
+	'`':  {0x00, 0x01, 0x02, 0x04, 0x00},
+	'a':  {0x20, 0x54, 0x54, 0x54, 0x78},
+	'b':  {0x7F, 0x48, 0x44, 0x44, 0x38},
+	'c':  {0x38, 0x44, 0x44, 0x44, 0x20},
+	'd':  {0x38, 0x44, 0x44, 0x48, 0x7F},
+	'e':  {0x38, 0x54, 0x54, 0x54, 0x18},
+	'f':  {0x08, 0x7E, 0x09, 0x01, 0x02},
+	'g':  {0x0C, 0x52, 0x52, 0x52, 0x3E},
+	'h':  {0x7F, 0x08, 0x04, 0x04, 0x78},
+	'i':  {0x00, 0x44, 0x7D, 0x40, 0x00},
+	'j':  {0x20, 0x40, 0x44, 0x3D, 0x00},
+	'k':  {0x7F, 0x10, 0x28, 0x44, 0x00},
+	'l':  {0x00, 0x41, 0x7F, 0x40, 0x00},
+	'm':  {0x7C, 0x04, 0x18, 0x04, 0x78},
+	'n':  {0x7C, 0x08, 0x04, 0x04, 0x78},
+	'o':  {0x38, 0x44, 0x44, 0x44, 0x38},
+	'p':  {0x7C, 0x14, 0x14, 0x14, 0x08},
+	'q':  {0x08, 0x14, 0x14, 0x18, 0x7C},
+	'r':  {0x7C, 0x08, 0x04, 0x04, 0x08},
+	's':  {0x48, 0x54, 0x54, 0x54, 0x20},
+	't':  {0x04, 0x3F, 0x44, 0x40, 0x20},
+	'u':  {0x3C, 0x40, 0x40, 0x20, 0x7C},
+	'v':  {0x1C, 0x20, 0x40, 0x20, 0x1C},
+	'w':  {0x3C, 0x40, 0x30, 0x40, 0x3C},
+	'x':  {0x44, 0x28, 0x10, 0x28, 0x44},
+	'y':  {0x0C, 0x50, 0x50, 0x50, 0x3C},
+	'z':  {0x44, 0x64, 0x54, 0x4C, 0x44},
+	'{':  {0x00, 0x08, 0x36, 0x41, 0x00},
+	'|':  {0x00, 0x00, 0x7F, 0x00, 0x00},
+	'}':  {0x00, 0x41, 0x36, 0x08, 0x00},
+	'~':  {0x08, 0x04, 0x08, 0x10, 0x08},
+}
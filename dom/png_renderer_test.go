@@ -0,0 +1,69 @@
+package dom
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+func TestWritePNG(t *testing.T) {
+	doc := NewSVGDocument(10, 5, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	doc.Padding = 0
+	doc.DPI = 100
+	part := testRenderedPart("part", "M 0 0 L 4 0 L 4 2 L 0 2 L 0 0")
+	part.Label = Label{Text: "A", Position: path.MiddleMiddle}
+	_, err := doc.Add(part, RenderContext{})
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	buf := &bytes.Buffer{}
+	err = doc.WritePNG(RenderContext{}, buf)
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if img.Bounds().Dx() != 1000 || img.Bounds().Dy() != 500 {
+		t.Errorf("Expected 1000x500 image, got %v", img.Bounds())
+	}
+	// on the bottom edge of the part
+	if r, _, _, _ := img.At(200, 199).RGBA(); r > 0x8000 {
+		t.Errorf("Expected the part edge to be drawn")
+	}
+	// inside the part
+	if r, _, _, _ := img.At(100, 100).RGBA(); r != 0xffff {
+		t.Errorf("Expected inside of the part to be empty")
+	}
+	// the label is blue
+	blue := false
+	for x := 190; x < 220; x++ {
+		for y := 80; y < 110; y++ {
+			r, _, b, _ := img.At(x, y).RGBA()
+			if b == 0xffff && r < 0x8000 {
+				blue = true
+			}
+		}
+	}
+	if !blue {
+		t.Errorf("Expected the label to be drawn")
+	}
+}
+
+func TestRasterTextMultiByte(t *testing.T) {
+	// unknown glyphs draw as ?, each glyph takes the same room no matter
+	// how many bytes it is
+	expected := newRasterMask(100, 20)
+	expected.text("??", path.NewPoint(0, 15), 10, 0)
+	m := newRasterMask(100, 20)
+	m.text("é?", path.NewPoint(0, 15), 10, 0)
+	for i := range m.cov {
+		if m.cov[i] != expected.cov[i] {
+			t.Fatalf("Expected the same pixels as ??, differs at %d,%d", i%m.width, i/m.width)
+		}
+	}
+}
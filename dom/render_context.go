@@ -18,3 +18,11 @@ func (c RenderContext) Clone() RenderContext {
 		Log:    c.Log,
 	}
 }
+
+// the logger, or a new one if none was set
+func (c RenderContext) Logger() *util.HfdLog {
+	if c.Log == nil {
+		return util.NewLog()
+	}
+	return c.Log
+}
@@ -0,0 +1,267 @@
+package dom
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// Layout report.
+// Summarizes the material a plan set uses, for each sheet and in total.
+// Utilization is measured two ways, by the bounding boxes of the parts and
+// by the true area of the parts, which is the area inside the closed cuts
+// less any holes.  Parts without a closed cut use their bounding box.
+// Lengths are the distance the machine travels with the laser on for each
+// operation, and pierces are the number of times it turns on, one for each
+// continuous section of a path.  All values are in the document units,
+// sheets of a material group with other units are converted.
+
+// the report for one sheet, or the totals of all the sheets
+type SheetReport struct {
+	// the index of the sheet, -1 for the totals
+	Index int
+	// the stock name, or the size if the sheet is not from stock
+	Stock    string
+	Material string
+	Width    float64
+	Height   float64
+	// the ids of the parts placed on the sheet
+	Parts []string
+	// the total of the part bounding boxes
+	BoundingBoxArea float64
+	// the total true area of the parts
+	PartArea float64
+	// the total sheet area
+	Area float64
+	// the length of the paths for each operation
+	Length map[Operation]float64
+	// the number of pierces for each operation
+	Pierces map[Operation]int
+}
+
+type LayoutReport struct {
+	Units  Units
+	Sheets []*SheetReport
+	Total  *SheetReport
+}
+
+func newSheetReport(index int) *SheetReport {
+	return &SheetReport{
+		Index:   index,
+		Parts:   []string{},
+		Length:  map[Operation]float64{},
+		Pierces: map[Operation]int{},
+	}
+}
+
+// the fraction of the sheet covered by part bounding boxes
+func (s *SheetReport) BoundingBoxUtilization() float64 {
+	if s.Area == 0 {
+		return 0
+	}
+	return s.BoundingBoxArea / s.Area
+}
+
+// the fraction of the sheet covered by the parts
+func (s *SheetReport) AreaUtilization() float64 {
+	if s.Area == 0 {
+		return 0
+	}
+	return s.PartArea / s.Area
+}
+
+// the sheet area not used by parts
+func (s *SheetReport) Waste() float64 {
+	return s.Area - s.PartArea
+}
+
+// the total number of pierces for all operations
+func (s *SheetReport) TotalPierces() int {
+	total := 0
+	for _, p := range s.Pierces {
+		total += p
+	}
+	return total
+}
+
+// adds the other report to this one
+func (s *SheetReport) add(other *SheetReport) {
+	s.Parts = append(s.Parts, other.Parts...)
+	s.BoundingBoxArea += other.BoundingBoxArea
+	s.PartArea += other.PartArea
+	s.Area += other.Area
+	for op, l := range other.Length {
+		s.Length[op] += l
+	}
+	for op, p := range other.Pierces {
+		s.Pierces[op] += p
+	}
+}
+
+// converts the report from the units to the other units
+func (s *SheetReport) convert(from, to Units) {
+	scale := to.FromMM(1) / from.FromMM(1)
+	s.Width *= scale
+	s.Height *= scale
+	s.BoundingBoxArea *= scale * scale
+	s.PartArea *= scale * scale
+	s.Area *= scale * scale
+	for op := range s.Length {
+		s.Length[op] *= scale
+	}
+}
+
+func (s *SheetReport) ToDynMap() *dynmap.DynMap {
+	dm := dynmap.New()
+	if s.Index >= 0 {
+		dm.Put("sheet", s.Index)
+		dm.Put("stock", s.Stock)
+		if len(s.Material) > 0 {
+			dm.Put("material", s.Material)
+		}
+		dm.Put("width", s.Width)
+		dm.Put("height", s.Height)
+	}
+	dm.Put("parts", s.Parts)
+	dm.Put("part_count", len(s.Parts))
+	dm.Put("area", s.Area)
+	dm.Put("bounding_box_area", s.BoundingBoxArea)
+	dm.Put("bounding_box_utilization", s.BoundingBoxUtilization())
+	dm.Put("part_area", s.PartArea)
+	dm.Put("area_utilization", s.AreaUtilization())
+	dm.Put("waste", s.Waste())
+	length := dynmap.New()
+	pierces := dynmap.New()
+	for _, op := range Operations {
+		if l, ok := s.Length[op]; ok {
+			length.Put(string(op), l)
+			pierces.Put(string(op), s.Pierces[op])
+		}
+	}
+	dm.Put("length", length)
+	dm.Put("pierces", pierces)
+	dm.Put("total_pierces", s.TotalPierces())
+	return dm
+}
+
+func (r *LayoutReport) ToDynMap() *dynmap.DynMap {
+	sheets := []*dynmap.DynMap{}
+	for _, s := range r.Sheets {
+		sheets = append(sheets, s.ToDynMap())
+	}
+	total := r.Total.ToDynMap()
+	total.Put("sheets", len(r.Sheets))
+	dm := dynmap.New()
+	dm.Put("units", r.Units.Abv)
+	dm.Put("sheets", sheets)
+	dm.Put("total", total)
+	return dm
+}
+
+// writes the report as plain text
+func (r *LayoutReport) WriteText(w io.Writer) {
+	write := func(title string, s *SheetReport) {
+		fmt.Fprintf(w, "%s\n", title)
+		fmt.Fprintf(w, "  parts:                    %d\n", len(s.Parts))
+		fmt.Fprintf(w, "  area:                     %.3f %s²\n", s.Area, r.Units.Abv)
+		fmt.Fprintf(w, "  bounding box utilization: %.1f%%\n", s.BoundingBoxUtilization()*100)
+		fmt.Fprintf(w, "  area utilization:         %.1f%%\n", s.AreaUtilization()*100)
+		fmt.Fprintf(w, "  waste:                    %.3f %s²\n", s.Waste(), r.Units.Abv)
+		for _, op := range Operations {
+			if l, ok := s.Length[op]; ok {
+				fmt.Fprintf(w, "  %-8s length:          %.3f %s, %d pierces\n", op, l, r.Units.Abv, s.Pierces[op])
+			}
+		}
+	}
+	for _, s := range r.Sheets {
+		title := fmt.Sprintf("sheet %d: %s (%g x %g %s)", s.Index, s.Stock, s.Width, s.Height, r.Units.Abv)
+		if len(s.Material) > 0 {
+			title += " " + s.Material
+		}
+		write(title, s)
+	}
+	write(fmt.Sprintf("total: %d sheets", len(r.Sheets)), r.Total)
+}
+
+// the true area of the part.  Closed cuts inside an odd number of other
+// closed cuts are holes.  Returns the bounding box area if there are no
+// closed cuts.
+func partArea(rp *RenderedPart, tolerance float64) float64 {
+	closed := [][]path.Point{}
+	for _, op := range rp.OutlinePaths() {
+		if op.Operation != Cut {
+			continue
+		}
+		for _, polyline := range path.FlattenPath(op.Path, tolerance) {
+			first, last := polyline[0], polyline[len(polyline)-1]
+			if len(polyline) > 3 && path.Distance(first, last) <= tolerance {
+				closed = append(closed, polyline[:len(polyline)-1])
+			}
+		}
+	}
+	if len(closed) == 0 {
+		return rp.Width * rp.Height
+	}
+	area := 0.0
+	for i, c := range closed {
+		depth := 0
+		for j, other := range closed {
+			if i != j && polygonInside(c, other) {
+				depth++
+			}
+		}
+		if depth%2 == 0 {
+			area += math.Abs(path.PolygonArea(c))
+		} else {
+			area -= math.Abs(path.PolygonArea(c))
+		}
+	}
+	return area
+}
+
+// the report for the document
+func (d *SVGDocument) Report(index int) *SheetReport {
+	s := newSheetReport(index)
+	s.Stock = fmt.Sprintf("%gx%g", d.Width, d.Height)
+	if d.Stock != nil {
+		s.Stock = d.Stock.Name
+	}
+	s.Material = d.Material
+	s.Width = d.Width
+	s.Height = d.Height
+	s.Area = d.Width * d.Height
+	for _, r := range d.renderables {
+		rp := r.renderedPart
+		s.Parts = append(s.Parts, rp.Part.Id())
+		s.BoundingBoxArea += rp.Width * rp.Height
+		s.PartArea += partArea(rp, d.CurveTolerance)
+		for _, op := range rp.OperationPaths() {
+			for _, polyline := range path.FlattenPath(op.Path, d.CurveTolerance) {
+				for i := 1; i < len(polyline); i++ {
+					s.Length[op.Operation] += path.Distance(polyline[i-1], polyline[i])
+				}
+				s.Pierces[op.Operation]++
+			}
+		}
+	}
+	return s
+}
+
+// the report for all the documents in the plan set
+func (p *PlanSet) Report() *LayoutReport {
+	r := &LayoutReport{
+		Units:  MustUnits(p.doc.Attr().MustString("measurement_units", "in"), Inches),
+		Sheets: []*SheetReport{},
+		Total:  newSheetReport(-1),
+	}
+	for i, d := range p.svgDocs {
+		s := d.Report(i)
+		s.convert(d.Units, r.Units)
+		r.Sheets = append(r.Sheets, s)
+		r.Total.add(s)
+	}
+	return r
+}
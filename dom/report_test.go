@@ -0,0 +1,128 @@
+package dom
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+func TestReport(t *testing.T) {
+	doc := NewSVGDocument(10, 10, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	frame := testRenderedPart("frame", "M 0 0 L 6 0 L 6 6 L 0 6 L 0 0 M 1 1 L 5 1 L 5 5 L 1 5 L 1 1")
+	small := testRenderedPart("small", "M 0 0 L 2 0 L 2 2 L 0 2 L 0 0")
+	for _, p := range []*RenderedPart{frame, small} {
+		added, err := doc.Add(p, RenderContext{})
+		if err != nil || !added {
+			t.Fatalf("Expected %s to be added (%v)", p.Part.Id(), err)
+		}
+	}
+
+	d, err := ParseDocumentFromJson(`{"params": {}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	p := NewPlanSet(d)
+	p.svgDocs = []*SVGDocument{doc, doc}
+	report := p.Report()
+	s := report.Sheets[0]
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-6
+	}
+	if len(s.Parts) != 2 || !near(s.BoundingBoxArea, 40) || !near(s.PartArea, 24) || !near(s.Waste(), 76) {
+		t.Errorf("Unexpected sheet report %s", s.ToDynMap().ToJSON())
+	}
+	if !near(s.Length[Cut], 48) || s.Pierces[Cut] != 3 {
+		t.Errorf("Expected 48in of cuts and 3 pierces, got %f %d", s.Length[Cut], s.Pierces[Cut])
+	}
+	if !near(report.Total.PartArea, 48) || !near(report.Total.AreaUtilization(), .24) || report.Total.TotalPierces() != 6 {
+		t.Errorf("Unexpected total report %s", report.Total.ToDynMap().ToJSON())
+	}
+	if report.ToDynMap().MustDynMap("total", nil).MustInt("sheets", 0) != 2 {
+		t.Errorf("Expected 2 sheets %s", report.ToDynMap().ToJSON())
+	}
+	buf := &bytes.Buffer{}
+	report.WriteText(buf)
+	if !strings.Contains(buf.String(), "total: 2 sheets") {
+		t.Errorf("Unexpected text report\n%s", buf.String())
+	}
+}
+
+// tabs open up the cuts, the parts are still nested and measured by
+// their closed outlines
+func TestReportTabs(t *testing.T) {
+	d, err := ParseDocumentFromJson(`{"params": {}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	doc := testNestDocument(6.4, 6.4)
+	tabs, _ := dynmap.ParseJSON(`{"width": 0.2, "count": 2}`)
+	frame := testRenderedPart("frame", "M 0 0 L 6 0 L 6 6 L 0 6 L 0 0 M 1 1 L 5 1 L 5 5 L 1 5 L 1 1")
+	small := testRenderedPart("small", "M 0 0 L 2 0 L 2 2 L 0 2 L 0 0")
+	for _, p := range []*RenderedPart{frame, small} {
+		p.Part.SetParent(d)
+		_, err := (&TabsPartTransform{mp: tabs}).TransformPart(p, RenderContext{})
+		if err != nil {
+			t.Fatalf("Error %s", err)
+		}
+		added, err := doc.Add(p, RenderContext{})
+		if err != nil || !added {
+			t.Fatalf("Expected %s to be added (%v)", p.Part.Id(), err)
+		}
+	}
+	if len(doc.nested[0].holes) != 1 {
+		t.Errorf("Expected the tabbed frame to keep its hole")
+	}
+	tl, br := testDocumentBounds(t, doc.renderables[1])
+	if tl.X < 1.2-1e-6 || tl.Y < 1.2-1e-6 || br.X > 4.8+1e-6 || br.Y > 4.8+1e-6 {
+		t.Errorf("Expected the small part inside the hole, got %v %v", tl, br)
+	}
+
+	p := NewPlanSet(d)
+	p.svgDocs = []*SVGDocument{doc}
+	s := p.Report().Sheets[0]
+	if math.Abs(s.PartArea-24) > 1e-6 {
+		t.Errorf("Expected the part area of the outlines, got %f", s.PartArea)
+	}
+	// the cuts stop at the two tabs on each outside
+	if math.Abs(s.Length[Cut]-(48-4*.2)) > 1e-6 || s.Pierces[Cut] != 5 {
+		t.Errorf("Expected the tabs left out of the cuts, got %f %d", s.Length[Cut], s.Pierces[Cut])
+	}
+}
+
+// sheets in other units are converted to the document units
+func TestReportUnits(t *testing.T) {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-6
+	}
+	inDoc := NewSVGDocument(10, 10, Inches)
+	mmDoc := NewSVGDocument(254, 254, MilliMeters)
+	for _, doc := range []*SVGDocument{inDoc, mmDoc} {
+		doc.SegmentOperators = AppContext().SegmentOperators()
+		s := doc.Units.FromInch(1)
+		pth := fmt.Sprintf("M 0 0 L %f 0 L %f %f L 0 %f L 0 0", s, s, s, s)
+		added, err := doc.Add(testRenderedPart("square", pth), RenderContext{})
+		if err != nil || !added {
+			t.Fatalf("Expected square to be added (%v)", err)
+		}
+	}
+	d, err := ParseDocumentFromJson(`{"params": {}}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	p := NewPlanSet(d)
+	p.svgDocs = []*SVGDocument{inDoc, mmDoc}
+	report := p.Report()
+	mm := report.Sheets[1]
+	if !near(mm.Width, 10) || !near(mm.Area, 100) || !near(mm.PartArea, 1) || !near(mm.Length[Cut], 4) {
+		t.Errorf("Expected the mm sheet in inches %s", mm.ToDynMap().ToJSON())
+	}
+	if !near(report.Total.Area, 200) || !near(report.Total.PartArea, 2) || !near(report.Total.Length[Cut], 8) {
+		t.Errorf("Unexpected total report %s", report.Total.ToDynMap().ToJSON())
+	}
+}
@@ -0,0 +1,414 @@
+package dom
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/binpacking"
+	"github.com/dustismo/heavyfishdesign/dynmap"
+)
+
+// Stock sheets.
+// By default parts are laid out on as many material_width x material_height
+// sheets as needed.  The stock document param (or the stock list in the
+// file named by stock_file) lists the sheets that are actually available:
+//
+//	"stock": [
+//		{"width": 24, "height": 12, "quantity": 2},
+//		{"name": "offcut", "width": 7, "height": 9}
+//	]
+//
+// Each time a new sheet is needed every available size is tried, looking
+// ahead at the stock needed for the parts that do not fit, and the size that
+// needs the least total area is used.  Layouts are compared by the total area
+// of the sheets, so the smallest combination of sheets wins.
+
+// a size of material that is available for layout
+type StockSheet struct {
+	Name     string
+	Width    float64
+	Height   float64
+	Quantity int
+	// the cost of one sheet, used for job estimates
+	Price float64
+	// the material of the sheet, only parts of that material are laid
+	// out on it.  Empty if the sheet can be used for any material
+	Material string
+}
+
+func NewStockSheet(dm *dynmap.DynMap) (*StockSheet, error) {
+	width, ok := dm.GetFloat64("width")
+	if !ok || width <= 0 {
+		return nil, fmt.Errorf("Stock width is required and must be greater than 0: %s", dm.ToJSON())
+	}
+	height, ok := dm.GetFloat64("height")
+	if !ok || height <= 0 {
+		return nil, fmt.Errorf("Stock height is required and must be greater than 0: %s", dm.ToJSON())
+	}
+	quantity := dm.MustInt("quantity", 1)
+	if quantity < 0 {
+		return nil, fmt.Errorf("Stock quantity must not be negative: %s", dm.ToJSON())
+	}
+	return &StockSheet{
+		Name:     dm.MustString("name", fmt.Sprintf("%gx%g", width, height)),
+		Width:    width,
+		Height:   height,
+		Quantity: quantity,
+		Price:    dm.MustFloat64("price", 0),
+		Material: dm.MustString("material", ""),
+	}, nil
+}
+
+func (s *StockSheet) ToDynMap() *dynmap.DynMap {
+	dm := dynmap.New()
+	dm.Put("name", s.Name)
+	dm.Put("width", s.Width)
+	dm.Put("height", s.Height)
+	dm.Put("quantity", s.Quantity)
+	if s.Price > 0 {
+		dm.Put("price", s.Price)
+	}
+	if len(s.Material) > 0 {
+		dm.Put("material", s.Material)
+	}
+	return dm
+}
+
+func (s *StockSheet) Area() float64 {
+	return s.Width * s.Height
+}
+
+// parses the list of stock sheets
+func ParseStock(dms []*dynmap.DynMap) ([]*StockSheet, error) {
+	stock := []*StockSheet{}
+	for _, dm := range dms {
+		s, err := NewStockSheet(dm)
+		if err != nil {
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+	return stock, nil
+}
+
+// loads the stock from the stock_file or the stock param, of the material
+// group or the document.  Plan sets that list the same stock share the
+// loaded sheets, so they share the quantities.
+// returns nil if the document does not list any stock
+func (p *PlanSet) loadStock(loaded map[string][]*StockSheet) ([]*StockSheet, error) {
+	attr := p.attr()
+	dms, _ := attr.DynMapSlice("stock")
+	filename := attr.MustString("stock_file", "")
+	// the stock list, or the file it is loaded from
+	key := dynmap.New()
+	key.Put("stock", dms)
+	if len(filename) > 0 {
+		key.Put("stock", filename)
+	}
+	if stock, ok := loaded[key.ToJSON()]; ok {
+		return stock, nil
+	}
+	if len(filename) > 0 {
+		b, err := AppContext().FileLoader().LoadBytes(filename)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load stock file %s: %s", filename, err.Error())
+		}
+		dm, err := dynmap.ParseJSON(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse stock file %s: %s", filename, err.Error())
+		}
+		dms = dm.MustDynMapSlice("stock", []*dynmap.DynMap{})
+	}
+	var stock []*StockSheet
+	if len(dms) > 0 {
+		var err error
+		stock, err = ParseStock(dms)
+		if err != nil {
+			return nil, err
+		}
+	}
+	loaded[key.ToJSON()] = stock
+	return stock, nil
+}
+
+func containsStock(stock []*StockSheet, s *StockSheet) bool {
+	for _, st := range stock {
+		if st == s {
+			return true
+		}
+	}
+	return false
+}
+
+// a stock size filled with parts, see fillStock
+type stockFillKey struct {
+	stock *StockSheet
+	index int
+	// the parts, in order
+	parts string
+}
+
+type stockFill struct {
+	rest []*RenderedPart
+	ok   bool
+}
+
+// fills a new sheet of the stock size with the parts, as the sheet with the
+// index.  Returns the parts that did not fit, and false if the first part
+// does not fit on the sheet.  Picking the stock tries the same fills many
+// times, so the fills are kept until the next layout.
+func (p *PlanSet) fillStock(ctx RenderContext, s *StockSheet, parts []*RenderedPart, index int) ([]*RenderedPart, bool, error) {
+	ids := &strings.Builder{}
+	for _, part := range parts {
+		fmt.Fprintf(ids, "%p ", part)
+	}
+	key := stockFillKey{stock: s, index: index, parts: ids.String()}
+	if f, ok := p.stockFills[key]; ok {
+		return f.rest, f.ok, nil
+	}
+	if p.stockFills == nil {
+		p.stockFills = map[stockFillKey]stockFill{}
+	}
+
+	doc := p.createSvgDoc(ctx, s, index)
+	rest := []*RenderedPart{}
+	for i, part := range parts {
+		added, err := doc.Add(part, ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		if i == 0 && (!added || doc.oversized) {
+			p.stockFills[key] = stockFill{ok: false}
+			return nil, false, nil
+		}
+		if !added {
+			rest = append(rest, part)
+		}
+	}
+	p.stockFills[key] = stockFill{rest: rest, ok: true}
+	return rest, true, nil
+}
+
+// the total area of the stock needed for the parts.  Each sheet is the
+// smallest that fits all the remaining parts, or if none do the one that
+// fits the most part area per sheet area.  Returns +Inf if there is
+// not enough stock.
+func (p *PlanSet) greedyStockArea(ctx RenderContext, parts []*RenderedPart, available map[*StockSheet]int, index int) (float64, error) {
+	partArea := func(ps []*RenderedPart) float64 {
+		area := 0.0
+		for _, part := range ps {
+			area += part.Width * part.Height
+		}
+		return area
+	}
+	total := 0.0
+	for len(parts) > 0 {
+		var best *StockSheet
+		var bestRest []*RenderedPart
+		bestFill := 0.0
+		for _, s := range p.stock {
+			if available[s] <= 0 {
+				continue
+			}
+			rest, ok, err := p.fillStock(ctx, s, parts, index)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				continue
+			}
+			fill := (partArea(parts) - partArea(rest)) / s.Area()
+			better := best == nil
+			if !better && len(rest) == 0 {
+				better = len(bestRest) > 0 || s.Area() < best.Area()
+			} else if !better && len(bestRest) > 0 {
+				better = fill > bestFill
+			}
+			if better {
+				best, bestRest, bestFill = s, rest, fill
+			}
+		}
+		if best == nil {
+			return math.Inf(1), nil
+		}
+		available[best]--
+		total += best.Area()
+		parts = bestRest
+		index++
+	}
+	return total, nil
+}
+
+// creates the document for the next stock sheet.  Each available size is
+// tried for the next sheet, with the parts that do not fit laid out greedily
+// on the rest of the stock, and the size that needs the least total area
+// is used.  Ties go to the smaller sheet.
+func (p *PlanSet) nextStockDoc(ctx RenderContext, remaining []*RenderedPart, available map[*StockSheet]int) (*SVGDocument, error) {
+	var best *StockSheet
+	bestArea := 0.0
+	for _, s := range p.stock {
+		if available[s] <= 0 {
+			continue
+		}
+		rest, ok, err := p.fillStock(ctx, s, remaining, len(p.svgDocs))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		left := map[*StockSheet]int{}
+		for k, v := range available {
+			left[k] = v
+		}
+		left[s]--
+		area, err := p.greedyStockArea(ctx, rest, left, len(p.svgDocs)+1)
+		if err != nil {
+			return nil, err
+		}
+		area += s.Area()
+		if best == nil || area < bestArea || (area == bestArea && s.Area() < best.Area()) {
+			best = s
+			bestArea = area
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("Not enough stock, part %s does not fit on any of the remaining sheets", remaining[0].Part.Id())
+	}
+	available[best]--
+	return p.createSvgDoc(ctx, best, len(p.svgDocs)), nil
+}
+
+// creates the document for a sheet that only has pinned parts, on the
+// smallest available stock that they all fit on
+func (p *PlanSet) pinnedStockDoc(ctx RenderContext, available map[*StockSheet]int) (*SVGDocument, error) {
+	index := len(p.svgDocs)
+	var best *StockSheet
+	for _, s := range p.stock {
+		if available[s] <= 0 || (best != nil && s.Area() >= best.Area()) {
+			continue
+		}
+		fits := true
+		for _, part := range p.pinned {
+			_, max := pinnedBounds(part, 0)
+			if part.Pin.Sheet == index && (max.X > s.Width || max.Y > s.Height) {
+				fits = false
+			}
+		}
+		if fits {
+			best = s
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("Not enough stock, the parts pinned to sheet %d do not fit on any of the remaining sheets", index)
+	}
+	available[best]--
+	return p.createSvgDoc(ctx, best, index), nil
+}
+
+// the number of sheets available for the plan set material, sheets
+// of other materials are not available
+func (p *PlanSet) stockAvailable(s *StockSheet) int {
+	if len(s.Material) > 0 && s.Material != p.material {
+		return 0
+	}
+	return s.Quantity - p.stockUsed[s]
+}
+
+// true if the document lists the available stock
+func (p *PlanSet) UsesStock() bool {
+	return len(p.stock) > 0
+}
+
+// the stock used by the layout, and the offcuts left over.
+// sheets lists the stock and the offcuts of each document, used and unused
+// are the quantity of each stock size used and left over.  offcuts are the
+// offcuts in the same format as the stock list, so they can be added back
+// to inventory.  Offcuts smaller than the min_offcut param (default 1 inch)
+// in either direction are left out.
+func (p *PlanSet) StockReport() *dynmap.DynMap {
+	attr := p.doc.Attr()
+	units := MustUnits(attr.MustString("measurement_units", "in"), Inches)
+	minOffcut := attr.MustFloat64("min_offcut", units.FromInch(1))
+
+	usedCount := map[*StockSheet]int{}
+	sheets := []*dynmap.DynMap{}
+	offcuts := []*dynmap.DynMap{}
+	for i, d := range p.svgDocs {
+		sheet := dynmap.New()
+		sheet.Put("document", i)
+		sheet.Put("width", d.Width)
+		sheet.Put("height", d.Height)
+		name := fmt.Sprintf("%gx%g", d.Width, d.Height)
+		if d.Stock != nil {
+			name = d.Stock.Name
+			usedCount[d.Stock]++
+		}
+		sheet.Put("stock", name)
+		if len(d.Material) > 0 {
+			sheet.Put("material", d.Material)
+		}
+		bins := []*dynmap.DynMap{}
+		for _, b := range d.Offcuts(minOffcut) {
+			bin := dynmap.New()
+			bin.Put("x", b.X)
+			bin.Put("y", b.Y)
+			bin.Put("width", b.Width)
+			bin.Put("height", b.Height)
+			bins = append(bins, bin)
+
+			offcuts = append(offcuts, (&StockSheet{
+				Name:     fmt.Sprintf("offcut of %s", name),
+				Width:    b.Width,
+				Height:   b.Height,
+				Quantity: 1,
+				Material: d.Material,
+			}).ToDynMap())
+		}
+		sheet.Put("offcuts", bins)
+		sheets = append(sheets, sheet)
+	}
+
+	used := []*dynmap.DynMap{}
+	unused := []*dynmap.DynMap{}
+	for _, s := range p.stock {
+		if usedCount[s] > 0 {
+			u := *s
+			u.Quantity = usedCount[s]
+			used = append(used, u.ToDynMap())
+		}
+		if s.Quantity > usedCount[s] {
+			u := *s
+			u.Quantity = s.Quantity - usedCount[s]
+			unused = append(unused, u.ToDynMap())
+		}
+	}
+
+	report := dynmap.New()
+	report.Put("sheets", sheets)
+	report.Put("used", used)
+	report.Put("unused", unused)
+	report.Put("offcuts", offcuts)
+	return report
+}
+
+// the unused rectangles of the document, largest first, that are at least
+// minSize in both directions.  When nesting this is the area below the
+// nested parts
+func (d *SVGDocument) Offcuts(minSize float64) []*binpacking.Bin {
+	if d.oversized {
+		return []*binpacking.Bin{}
+	}
+	if !d.Nest {
+		return d.layoutContainer.GetOffcuts(minSize)
+	}
+	bottom := 0.0
+	for _, s := range d.nested {
+		bottom = math.Max(bottom, s.max.Y)
+	}
+	bins := []*binpacking.Bin{
+		binpacking.NewBin(0, bottom, d.Width, d.Height-bottom, false),
+	}
+	return binpacking.DisjointBins(bins, minSize)
+}
@@ -0,0 +1,87 @@
+package dom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+func testStockPlanSet(t *testing.T) *PlanSet {
+	doc, err := ParseDocumentFromJson(`{
+		"params": {
+			"doc_padding": 0,
+			"stock": [
+				{"name": "sheet", "width": 10, "height": 10},
+				{"width": 5, "height": 5, "quantity": 2}
+			]
+		}
+	}`, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	p := NewPlanSet(doc)
+	p.stock, err = p.loadStock(map[string][]*StockSheet{})
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	return p
+}
+
+func testSquares(n int) []*RenderedPart {
+	parts := []*RenderedPart{}
+	for i := 0; i < n; i++ {
+		parts = append(parts, testRenderedPart(fmt.Sprintf("square%d", i), "M 0 0 L 4 0 L 4 4 L 0 4 L 0 0"))
+	}
+	return parts
+}
+
+func TestParseStock(t *testing.T) {
+	dm, _ := dynmap.ParseJSON(`{"stock": [{"width": 24, "height": 12, "quantity": 2}, {"width": 7}]}`)
+	_, err := ParseStock(dm.MustDynMapSlice("stock", nil))
+	if err == nil {
+		t.Errorf("Expected error for stock without a height")
+	}
+	stock, err := ParseStock(dm.MustDynMapSlice("stock", nil)[:1])
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if stock[0].Name != "24x12" || stock[0].Quantity != 2 {
+		t.Errorf("Unexpected stock %s", stock[0].ToDynMap().ToJSON())
+	}
+}
+
+func TestStockLayout(t *testing.T) {
+	p := testStockPlanSet(t)
+	// the big sheet alone is smaller than both small sheets and the big sheet
+	err := p.layout(RenderContext{}, testSquares(3))
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if len(p.svgDocs) != 1 || p.svgDocs[0].Stock.Name != "sheet" {
+		t.Errorf("Expected a single 10x10 sheet, got %d sheets", len(p.svgDocs))
+	}
+
+	err = p.layout(RenderContext{}, testSquares(5))
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	if len(p.svgDocs) != 2 || layoutArea(p.svgDocs) != 125 {
+		t.Fatalf("Expected a 10x10 and a 5x5 sheet, got %d sheets", len(p.svgDocs))
+	}
+	report := p.StockReport()
+	used := report.MustDynMapSlice("used", nil)
+	unused := report.MustDynMapSlice("unused", nil)
+	if len(used) != 2 || len(unused) != 1 || unused[0].MustInt("quantity", 0) != 1 {
+		t.Errorf("Unexpected stock report %s", report.ToJSON())
+	}
+	if len(report.MustDynMapSlice("offcuts", nil)) == 0 {
+		t.Errorf("Expected offcuts %s", report.ToJSON())
+	}
+
+	err = p.layout(RenderContext{}, testSquares(7))
+	if err == nil {
+		t.Errorf("Expected error when there is not enough stock")
+	}
+}
@@ -33,11 +33,46 @@ const (
 	ResizeDocument RenderStrategy = 1
 )
 
+// How the svg output is divided into Inkscape layers
+type LayerStrategy int32
+
+const (
+	// no layers, paths are only grouped by operation
+	NoLayers LayerStrategy = 0
+	// a layer for each operation, and one for the labels
+	OperationLayers LayerStrategy = 1
+	// a layer for each part
+	PartLayers LayerStrategy = 2
+	// a layer for each part label
+	LabelLayers LayerStrategy = 3
+)
+
+var layerStrategyNames = map[string]LayerStrategy{
+	"none":      NoLayers,
+	"operation": OperationLayers,
+	"part":      PartLayers,
+	"label":     LabelLayers,
+}
+
+func NewLayerStrategy(in string) (LayerStrategy, bool) {
+	l, ok := layerStrategyNames[in]
+	return l, ok
+}
+
+func MustLayerStrategy(in string, defaultStrategy LayerStrategy) LayerStrategy {
+	l, ok := NewLayerStrategy(in)
+	if !ok {
+		return defaultStrategy
+	}
+	return l
+}
+
 type docRenderable struct {
 	// position to render at
 	position         path.Point
 	renderedPart     *RenderedPart
-	rotate           bool // should we rotate by 90deg (used for layout)
+	rotate           bool    // should we rotate by 90deg (used for layout)
+	angle            float64 // rotate clockwise by degrees around the origin, then move to position (used for nesting)
 	segmentOperators path.SegmentOperators
 }
 
@@ -64,14 +99,70 @@ type SVGDocument struct {
 	// how many decimal places to render
 	Precision int
 
+	// max distance a flattened curve can stray from the real curve
+	// used by output formats that do not support bezier curves
+	CurveTolerance float64
+
+	// settings for gcode output
+	GCode GCodeSettings
+
+	// resolution of raster (png) output, in pixels per inch
+	DPI float64
+
+	// laser speed and power for each operation
+	Laser map[Operation]LaserSettings
+
+	// the packing algorithm used to lay out the page
+	LayoutStrategy binpacking.Strategy
+
+	// nest the part outlines rather than packing the bounding boxes
+	Nest bool
+
+	// the stock sheet this document is laid out on, nil if the
+	// document does not use stock
+	Stock *StockSheet
+
+	// the material of the parts on the document, empty if the
+	// parts do not name one
+	Material string
+
+	// the rotations (in degrees) to try when nesting
+	NestRotations []float64
+
 	// this is for laying out the page
 	layoutContainer *binpacking.Container
 
+	// the nested part outlines, and the cache of outlines for each part
+	nested     []*nestShape
+	nestShapes *nestShapes
+
+	// true if a part that did not fit was added anyway
+	oversized bool
+
+	// the areas parts are kept out of
+	KeepOuts []*KeepOut
+
+	// draw the keep out areas, for previews
+	ShowKeepOuts bool
+
 	renderables      []*docRenderable
 	SegmentOperators path.SegmentOperators
 
-	CutStyle   string
-	LabelStyle string
+	CutStyle     string
+	ScoreStyle   string
+	EngraveStyle string
+	LabelStyle   string
+
+	// how the svg output is divided into layers
+	Layers LayerStrategy
+}
+
+// a group of parts in the svg output
+type svgLayer struct {
+	name        string
+	ops         []Operation
+	label       bool
+	renderables []*docRenderable
 }
 
 func (dr *docRenderable) GetWidth() float64 {
@@ -82,7 +173,66 @@ func (dr *docRenderable) GetHeight() float64 {
 	return dr.renderedPart.Height
 }
 
-func (dr *docRenderable) render(d *SVGDocument, ctx RenderContext, writer io.Writer) error {
+func (dr *docRenderable) AllowedRotation() binpacking.Rotation {
+	return dr.renderedPart.Rotation
+}
+
+// transforms a point from the rendered part coordinates into the document
+// coordinates, based on the layout position and rotation.
+// This is equivalent to the svg transform used in render
+func (dr *docRenderable) toDocument(p path.Point) path.Point {
+	if dr.angle != 0 {
+		r := path.Rotate(dr.angle, p)
+		return path.NewPoint(dr.position.X+r.X, dr.position.Y+r.Y)
+	}
+	if dr.rotate {
+		// rotate 90 around the position then shift to the right by the height
+		return path.NewPoint(dr.position.X+dr.GetHeight()-p.Y, dr.position.Y+p.X)
+	}
+	return path.NewPoint(dr.position.X+p.X, dr.position.Y+p.Y)
+}
+
+// the rotation of the part in degrees (clockwise), labels are
+// rotated with the part
+func (dr *docRenderable) labelAngle() float64 {
+	if dr.rotate {
+		return 90
+	}
+	return dr.angle
+}
+
+// returns the part path in document coordinates
+func (dr *docRenderable) documentPath() (path.Path, error) {
+	return dr.documentOperationPath(dr.renderedPart.Path)
+}
+
+// returns one of the part operation paths in document coordinates
+func (dr *docRenderable) documentOperationPath(pth path.Path) (path.Path, error) {
+	segments := []path.Segment{}
+	for _, seg := range pth.Segments() {
+		s, err := dr.segmentOperators.TransformSegment(seg, dr.toDocument)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, s...)
+	}
+	return path.NewPathFromSegmentsWithoutMove(segments), nil
+}
+
+// returns the position of the label in document coordinates
+func (dr *docRenderable) documentLabelPosition() (path.Point, error) {
+	textPos, err := path.PointPathAttribute(
+		dr.renderedPart.Label.Position,
+		dr.renderedPart.Path,
+		dr.segmentOperators)
+	if err != nil {
+		return textPos, err
+	}
+	return dr.toDocument(textPos), nil
+}
+
+// renders the part paths for the requested operations, and the label if requested
+func (dr *docRenderable) render(d *SVGDocument, ctx RenderContext, writer io.Writer, ops []Operation, label bool) error {
 	transforms := []string{}
 
 	translateX := dr.position.X
@@ -95,21 +245,33 @@ func (dr *docRenderable) render(d *SVGDocument, ctx RenderContext, writer io.Wri
 	}
 	// move to the correct location
 	transforms = append(transforms, fmt.Sprintf("translate(%.3f %.3f)", translateX, translateY))
+	if dr.angle != 0 {
+		transforms = append(transforms, fmt.Sprintf("rotate(%.3f)", dr.angle))
+	}
 
-	d.writeSVG(writer, fmt.Sprintf("<g transform=\"%s\">", strings.Join(transforms, " ")))
+	d.writeSVG(writer, fmt.Sprintf("<g transform=\"%s\"%s>", strings.Join(transforms, " "), dr.dataAttributes(d)))
 
 	// svgItem is guarenteed to be available
 
 	pth := dr.renderedPart.Path
 
-	svg := fmt.Sprintf("<path id=\"%s\" d=\"%s\" style=\"%s\" />",
-		dr.renderedPart.Part.Id(),
-		path.SvgString(pth, d.Precision),
-		d.CutStyle)
-	d.writeSVG(writer, svg)
+	for _, op := range dr.renderedPart.OperationPaths() {
+		if !containsOperation(ops, op.Operation) {
+			continue
+		}
+		id := dr.renderedPart.Part.Id()
+		if op.Operation != Cut {
+			id = fmt.Sprintf("%s_%s", id, op.Operation)
+		}
+		svg := fmt.Sprintf("<path id=\"%s\" d=\"%s\" style=\"%s\" />",
+			id,
+			path.SvgString(op.Path, d.Precision),
+			d.OperationStyle(op.Operation))
+		d.writeSVG(writer, svg)
+	}
 
 	// Now render the label
-	if len(dr.renderedPart.Label.Text) > 0 {
+	if label && len(dr.renderedPart.Label.Text) > 0 {
 		// position and render
 		textPos, err := path.PointPathAttribute(
 			dr.renderedPart.Label.Position,
@@ -130,20 +292,27 @@ func (dr *docRenderable) render(d *SVGDocument, ctx RenderContext, writer io.Wri
 	return nil
 }
 
+// the data- attributes used to find the part in the svg.  These are only
+// added with layers, so the default output does not change
+func (dr *docRenderable) dataAttributes(d *SVGDocument) string {
+	if d.Layers == NoLayers {
+		return ""
+	}
+	attrs := fmt.Sprintf(" data-part-id=\"%s\" data-part-index=\"%d\"",
+		dr.renderedPart.Part.Id(),
+		dr.renderedPart.Index)
+	if len(dr.renderedPart.Lineage) > 0 {
+		attrs = fmt.Sprintf("%s data-part-lineage=\"%s\"", attrs, strings.Join(dr.renderedPart.Lineage, " "))
+	}
+	return attrs
+}
+
 // Creates a new document
 // defaults to settings for .2" Lowes style plywood
 func NewSVGDocument(w float64, h float64, unit Units) *SVGDocument {
 	// we want accuracy to 3 decimals
 	// for reference the glowforge is supposed to be .025mm accurate
 
-	// styles := svg.Attributes{
-	// 	CutStyle:        fmt.Sprintf("fill:none;stroke:black;stroke-width:%.3f", unit.FromMM(.3)),
-	// 	EngraveStyle:    fmt.Sprintf("fill:none;stroke:blue;stroke-width:%.3f", unit.FromMM(.3)),
-	// 	ScoreStyle:      fmt.Sprintf("fill:none;stroke:grey;stroke-width:%.3f", unit.FromMM(.3)),
-	// 	LabelTextStyle:  fmt.Sprintf("font: italic %.3fpt serif; fill: blue", unit.FromMM(5)),
-	// 	LabelShapeStyle: fmt.Sprintf("fill:none;stroke:blue;stroke-width:%.3f", unit.FromMM(.3)),
-	// }
-
 	name := "laser_design.svg"
 	d := &SVGDocument{
 		Name:            name,
@@ -154,7 +323,14 @@ func NewSVGDocument(w float64, h float64, unit Units) *SVGDocument {
 		RenderSize:      true,
 		layoutContainer: binpacking.NewContainer(0, 0, w, h),
 		Precision:       3,
-		CutStyle:        fmt.Sprintf("fill:none;stroke:black;stroke-width:%.3f", unit.FromMM(.3)),
+		CurveTolerance:  unit.FromMM(.025),
+		GCode:           NewGCodeSettings(unit),
+		DPI:             96,
+		NestRotations:   defaultNestRotations,
+		Laser:           NewLaserSettings(),
+		CutStyle:        operationStyle("black", unit),
+		ScoreStyle:      operationStyle("grey", unit),
+		EngraveStyle:    operationStyle("blue", unit),
 		LabelStyle:      fmt.Sprintf("font: %.3fpt serif; fill: blue", unit.FromMM(3)),
 	}
 	return d
@@ -169,13 +345,72 @@ func (d *SVGDocument) Clone() *SVGDocument {
 		Units:            d.Units,
 		Padding:          d.Padding,
 		RenderSize:       d.RenderSize,
-		layoutContainer:  binpacking.NewContainer(0, 0, d.Width, d.Height),
+		LayoutStrategy:   d.LayoutStrategy,
+		layoutContainer:  binpacking.NewStrategyContainer(d.LayoutStrategy, 0, 0, d.Width, d.Height),
+		Nest:             d.Nest,
+		Stock:            d.Stock,
+		Material:         d.Material,
+		NestRotations:    d.NestRotations,
+		nestShapes:       d.nestShapes,
 		SegmentOperators: d.SegmentOperators,
 		Precision:        d.Precision,
+		CurveTolerance:   d.CurveTolerance,
+		GCode:            d.GCode,
+		DPI:              d.DPI,
+		Laser:            d.Laser,
 		CutStyle:         d.CutStyle,
+		ScoreStyle:       d.ScoreStyle,
+		EngraveStyle:     d.EngraveStyle,
+		Layers:           d.Layers,
 	}
 }
 
+func operationStyle(color string, unit Units) string {
+	return fmt.Sprintf("fill:none;stroke:%s;stroke-width:%.3f", color, unit.FromMM(.3))
+}
+
+// the svg style for paths with the given operation
+func (d *SVGDocument) OperationStyle(op Operation) string {
+	switch op {
+	case Score:
+		return d.ScoreStyle
+	case Engrave:
+		return d.EngraveStyle
+	}
+	return d.CutStyle
+}
+
+// sets the stroke color for paths with the given operation
+func (d *SVGDocument) SetOperationColor(op Operation, color string) {
+	style := operationStyle(color, d.Units)
+	switch op {
+	case Score:
+		d.ScoreStyle = style
+	case Engrave:
+		d.EngraveStyle = style
+	default:
+		d.CutStyle = style
+	}
+}
+
+// the operations used by any of the parts in this document,
+// in the order they should be run
+func (d *SVGDocument) Operations() []Operation {
+	used := map[Operation]bool{}
+	for _, r := range d.renderables {
+		for _, op := range r.renderedPart.OperationPaths() {
+			used[op.Operation] = true
+		}
+	}
+	ops := []Operation{}
+	for _, op := range Operations {
+		if used[op] {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
 func (d *SVGDocument) start(writer io.Writer) {
 
 	size := ""
@@ -183,13 +418,19 @@ func (d *SVGDocument) start(writer io.Writer) {
 		size = fmt.Sprintf(`width="%.3f%s" height="%.3f%s"`, d.Width, d.Units.Abv, d.Height, d.Units.Abv)
 	}
 
+	namespaces := ""
+	if d.Layers != NoLayers {
+		namespaces = `
+		xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape"`
+	}
+
 	svg := `<?xml version="1.0"?>
 	<!-- Generated by github.com/dustismo/heavyfishdesign -->
 	<svg %s viewBox="0.000 0.000 %.3f %.3f"
     	xmlns="http://www.w3.org/2000/svg"
-		xmlns:xlink="http://www.w3.org/1999/xlink">
+		xmlns:xlink="http://www.w3.org/1999/xlink"%s>
 	`
-	fmt.Fprintf(writer, svg, size, d.Width, d.Height)
+	fmt.Fprintf(writer, svg, size, d.Width, d.Height, namespaces)
 }
 
 func (d *SVGDocument) end(writer io.Writer) {
@@ -197,15 +438,89 @@ func (d *SVGDocument) end(writer io.Writer) {
 	fmt.Fprintf(writer, svg)
 }
 
-// writes the whole svg document
+// splits the parts into layers based on the Layers strategy.
+// a layer with no name is written without a group
+func (d *SVGDocument) svgLayers() []*svgLayer {
+	ops := d.Operations()
+	layers := []*svgLayer{}
+	if d.Layers == PartLayers || d.Layers == LabelLayers {
+		byName := map[string]*svgLayer{}
+		for _, r := range d.renderables {
+			name := r.renderedPart.Part.Id()
+			if d.Layers == LabelLayers && len(r.renderedPart.Label.Text) > 0 {
+				name = r.renderedPart.Label.Text
+			}
+			layer, ok := byName[name]
+			if !ok {
+				layer = &svgLayer{name: name, ops: ops, label: true}
+				byName[name] = layer
+				layers = append(layers, layer)
+			}
+			layer.renderables = append(layer.renderables, r)
+		}
+		return layers
+	}
+
+	if len(ops) <= 1 && d.Layers == NoLayers {
+		return []*svgLayer{{ops: ops, label: true, renderables: d.renderables}}
+	}
+	for _, op := range ops {
+		layers = append(layers, &svgLayer{
+			name:        string(op),
+			ops:         []Operation{op},
+			renderables: d.renderables,
+		})
+	}
+	labels := &svgLayer{name: "labels", ops: []Operation{}, label: true}
+	for _, r := range d.renderables {
+		if len(r.renderedPart.Label.Text) > 0 {
+			labels.renderables = append(labels.renderables, r)
+		}
+	}
+	return append(layers, labels)
+}
+
+// writes the whole svg document.
+// If there is more than one operation, the paths are grouped by
+// operation, with the labels in their own group.  If Layers is set
+// the groups are written as Inkscape layers
 func (d *SVGDocument) WriteSVG(ctx RenderContext, writer io.Writer) {
 	d.start(writer)
-	for _, r := range d.renderables {
-		r.render(d, ctx, writer)
+	for _, layer := range d.svgLayers() {
+		if len(layer.name) > 0 {
+			if d.Layers == NoLayers {
+				d.writeSVG(writer, fmt.Sprintf("<g id=\"%s\">", layer.name))
+			} else {
+				d.writeSVG(writer, fmt.Sprintf("<g id=\"layer_%s\" inkscape:groupmode=\"layer\" inkscape:label=\"%s\">",
+					dxfLayerName(layer.name), layer.name))
+			}
+		}
+		for _, r := range layer.renderables {
+			r.render(d, ctx, writer, layer.ops, layer.label)
+		}
+		if len(layer.name) > 0 {
+			d.writeSVG(writer, "</g>")
+		}
 	}
+	d.writeKeepOuts(writer)
 	d.end(writer)
 }
 
+// sets the packing algorithm, this must be called before
+// any parts are added
+func (d *SVGDocument) SetLayoutStrategy(strategy binpacking.Strategy) {
+	d.LayoutStrategy = strategy
+	d.layoutContainer = binpacking.NewStrategyContainer(strategy, 0, 0, d.Width, d.Height)
+}
+
+// the area of the document not used by any parts (including padding)
+func (d *SVGDocument) EmptyArea() float64 {
+	if d.Nest {
+		return d.nestEmptyArea()
+	}
+	return d.layoutContainer.GetEmptyArea()
+}
+
 // adds a renderable creator into this document.  Returns
 // true if it was able to fit, false otherwise.
 func (d *SVGDocument) Add(p *RenderedPart, ctx RenderContext) (bool, error) {
@@ -220,13 +535,30 @@ func (d *SVGDocument) Add(p *RenderedPart, ctx RenderContext) (bool, error) {
 		return false, fmt.Errorf("Error, cannot add part because width or height is NaN")
 	}
 
+	if d.Nest {
+		r, ok := d.nest(p)
+		if !ok && len(d.renderables) == 0 {
+			fmt.Printf("Warning: part %s did not fit, but adding to a new document anyway\n", p.Part.Id())
+			r, ok = &docRenderable{
+				renderedPart:     p,
+				rotate:           p.Rotation == binpacking.Rotate90,
+				segmentOperators: d.SegmentOperators,
+			}, true
+			d.oversized = true
+		}
+		if ok {
+			d.renderables = append(d.renderables, r)
+		}
+		return ok, nil
+	}
+
 	r := &docRenderable{
 		renderedPart:     p,
 		rotate:           false,
 		segmentOperators: d.SegmentOperators,
 	}
 	inserted, bin := d.layoutContainer.InsertWithPadding(r, r, d.Padding)
-	if !inserted && d.layoutContainer.IsEmpty() {
+	if !inserted && len(d.renderables) == 0 {
 		// THis is kinda hacky, but here if the item doesn't fit we add
 		// a new container with the oversized part in on its own.
 		// eventually we should do this better, and probably flag this as being
@@ -236,7 +568,9 @@ func (d *SVGDocument) Add(p *RenderedPart, ctx RenderContext) (bool, error) {
 			r,
 			d.layoutContainer.X, d.layoutContainer.Y,
 			d.layoutContainer.Width, d.layoutContainer.Height)
-		bin = *d.layoutContainer.Root
+		bin = *binpacking.NewBin(d.layoutContainer.X, d.layoutContainer.Y,
+			d.layoutContainer.Width, d.layoutContainer.Height, p.Rotation == binpacking.Rotate90)
+		d.oversized = true
 	} else if !inserted {
 		return false, nil
 	}
@@ -0,0 +1,56 @@
+package dom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVGLayers(t *testing.T) {
+	doc := NewSVGDocument(10, 10, Inches)
+	doc.SegmentOperators = AppContext().SegmentOperators()
+	doc.Layers = PartLayers
+	top := testRenderedPart("shelf", "M 0 0 L 2 0 L 2 1 L 0 1 L 0 0")
+	bottom := testRenderedPart("shelf", "M 0 0 L 2 0 L 2 1 L 0 1 L 0 0")
+	bottom.Index = 1
+	bottom.Lineage = []string{"splitter:1"}
+	for _, rp := range []*RenderedPart{top, bottom, testRenderedPart("side", "M 0 0 L 1 0 L 1 1")} {
+		_, err := doc.Add(rp, RenderContext{})
+		if err != nil {
+			t.Errorf("Error %s", err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	doc.WriteSVG(RenderContext{}, buf)
+	svg := buf.String()
+	if !strings.Contains(svg, "xmlns:inkscape=") {
+		t.Errorf("Expected inkscape namespace\n%s", svg)
+	}
+	if strings.Count(svg, "inkscape:groupmode=\"layer\"") != 2 {
+		t.Errorf("Expected 2 layers\n%s", svg)
+	}
+	if !strings.Contains(svg, "inkscape:label=\"shelf\"") {
+		t.Errorf("Expected layer labeled shelf\n%s", svg)
+	}
+	if !strings.Contains(svg, "data-part-id=\"shelf\" data-part-index=\"1\" data-part-lineage=\"splitter:1\"") {
+		t.Errorf("Expected data attributes for the split part\n%s", svg)
+	}
+
+	// without layers the output is unchanged
+	doc.Layers = NoLayers
+	buf.Reset()
+	doc.WriteSVG(RenderContext{}, buf)
+	if svg := buf.String(); strings.Contains(svg, "data-part-id") {
+		t.Errorf("Expected no data attributes without layers\n%s", svg)
+	}
+}
+
+func TestLayerStrategy(t *testing.T) {
+	if MustLayerStrategy("operation", NoLayers) != OperationLayers {
+		t.Errorf("Expected operation layers")
+	}
+	if MustLayerStrategy("unknown", PartLayers) != PartLayers {
+		t.Errorf("Expected default for unknown layer strategy")
+	}
+}
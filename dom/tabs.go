@@ -0,0 +1,101 @@
+package dom
+
+import (
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// Holding tabs.
+// Leaves gaps in the cut of the outer contours so small parts stay in the
+// sheet, see path.Tabs.  Tabs are added by a tabs part transformer, or to
+// every part with the tabs document param, which takes the same fields:
+//
+//	"tabs": {
+//	    "width": 0.1,
+//	    "count": 2,
+//	    "spacing": 6,
+//	    "corner_clearance": 0.25,
+//	    "score_marks": true
+//	}
+//
+// count is the number of tabs on each contour, without it the count comes
+// from the perimeter and spacing.  With score_marks the tabs are also
+// scored, to show where to cut the parts free.
+// A part with its own tabs part transformer, or with "tabs": false, is
+// left out of the document tabs.
+// The part keeps its closed outline, so it is still nested by its shape
+// and reported by its area, see RenderedPart.OutlinePaths.
+
+type TabsPartTransform struct {
+	mp *dynmap.DynMap
+}
+
+func (t *TabsPartTransform) TransformPart(part *RenderedPart, ctx RenderContext) ([]*RenderedPart, error) {
+	attr := part.Part.DmAttr(t.mp)
+	width, ok := attr.Float64("width")
+	if !ok {
+		return nil, createMissingAttributeError("width", "tabs", t.mp)
+	}
+	options := path.TabOptions{
+		Width:           width,
+		Count:           attr.MustInt("count", 0),
+		Spacing:         attr.MustFloat64("spacing", 0),
+		CornerClearance: attr.MustFloat64("corner_clearance", width),
+	}
+	precision := attr.MustInt("precision", 3)
+	ops := []OperationPath{}
+	marks := []path.Path{}
+	for _, op := range part.OperationPaths() {
+		if op.Operation != Cut {
+			ops = addOperationPath(ops, op.Operation, op.Path)
+			continue
+		}
+		cut, m, untabbed, err := path.Tabs(op.Path, options, precision)
+		if err != nil {
+			return nil, err
+		}
+		if untabbed > 0 {
+			ctx.Logger().Errorf("Part %s has %d outlines with no room for a %.3f tab, they are cut without tabs",
+				part.Part.Id(), untabbed, width)
+		}
+		ops = addOperationPath(ops, Cut, cut)
+		marks = append(marks, m)
+	}
+	if attr.MustBool("score_marks", false) {
+		for _, m := range marks {
+			if !path.IsEmptyPath(m) {
+				ops = addOperationPath(ops, Score, m)
+			}
+		}
+	}
+	if part.Outline == nil {
+		part.Outline = part.OperationPaths()
+	}
+	part.Operations = ops
+	part.Path = joinOperationPaths(ops)
+	return []*RenderedPart{part}, nil
+}
+
+// adds the tabs param of the plan set (the material group or document)
+// to the rendered parts of the part
+func (p *PlanSet) addTabs(part *Part, renderedParts []*RenderedPart, ctx RenderContext) ([]*RenderedPart, error) {
+	mp, ok := p.attr().DynMap("tabs")
+	if !ok || !part.ToDynMap().MustBool("tabs", true) {
+		return renderedParts, nil
+	}
+	for _, pt := range part.PartTransformers {
+		if _, ok := pt.(*TabsPartTransform); ok {
+			return renderedParts, nil
+		}
+	}
+	tabs := &TabsPartTransform{mp: mp}
+	ret := []*RenderedPart{}
+	for _, rp := range renderedParts {
+		rps, err := tabs.TransformPart(rp, ctx)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, rps...)
+	}
+	return ret, nil
+}
@@ -336,3 +336,140 @@ func (tf MatrixTransformFactory) CreateTransform(transformType string, dm *dynma
 func (tf MatrixTransformFactory) TransformTypes() []string {
 	return []string{"matrix"}
 }
+
+type BooleanTransformFactory struct {
+}
+
+func (tf BooleanTransformFactory) CreateTransform(transformType string, dm *dynmap.DynMap, element Element) (path.PathTransform, error) {
+	op, err := path.ParseBooleanOperation(transformType)
+	if err != nil {
+		return nil, err
+	}
+	ids := dm.MustStringSlice("operands", []string{})
+	if id, ok := dm.GetString("operand"); ok {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, createMissingAttributeError("operand", transformType, dm)
+	}
+	operands := []path.Path{}
+	for _, id := range ids {
+		p, err := renderOperand(id, element)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, p)
+	}
+	attr := NewAttr(element, dm)
+	return transforms.BooleanTransform{
+		Operation:        op,
+		Operands:         operands,
+		Precision:        attr.MustInt("precision", 3),
+		SegmentOperators: AppContext().SegmentOperators(),
+	}, nil
+}
+
+// renders the component with the given id, from the same place the
+// element is being rendered
+func renderOperand(id string, element Element) (path.Path, error) {
+	e, err := FindElementByID(id, element)
+	if err != nil {
+		return nil, err
+	}
+	component, ok := e.(Component)
+	if !ok {
+		return nil, fmt.Errorf("Error, boolean operand %s must be a component", id)
+	}
+	if _, rendering := component.RenderContext(); rendering {
+		return nil, fmt.Errorf("Error, boolean operand %s cannot be used while it is rendering", id)
+	}
+	ctx := RenderContext{}
+	if c, ok := element.(Component); ok {
+		if cur, rendering := c.RenderContext(); rendering {
+			ctx = cur.Clone()
+		}
+	}
+	p, _, err := component.Render(ctx)
+	return p, err
+}
+
+// The list of component types this Factory should be used for
+func (tf BooleanTransformFactory) TransformTypes() []string {
+	return []string{"union", "subtract", "intersect", "xor"}
+}
+
+type FilletTransformFactory struct {
+}
+
+func (tf FilletTransformFactory) CreateTransform(transformType string, dm *dynmap.DynMap, element Element) (path.PathTransform, error) {
+	attr := NewAttr(element, dm)
+	chamfer := transformType == "chamfer"
+	sizeAttr := "radius"
+	if chamfer {
+		sizeAttr = "distance"
+	}
+	size, ok := attr.Float64(sizeAttr)
+	if !ok {
+		return nil, createMissingAttributeError(sizeAttr, transformType, dm)
+	}
+
+	var corners path.CornerSelection = path.AllCorners
+	cornersName := attr.MustString("corners", "all")
+	switch cornersName {
+	case "all":
+	case "convex":
+		corners = path.ConvexCorners
+	case "concave":
+		corners = path.ConcaveCorners
+	default:
+		return nil, fmt.Errorf("Error, unknown %s corners %s, expected all, convex or concave", transformType, cornersName)
+	}
+
+	return transforms.FilletTransform{
+		Radius:    size,
+		Chamfer:   chamfer,
+		Corners:   corners,
+		MinAngle:  attr.MustFloat64("min_angle", 0),
+		MaxAngle:  attr.MustFloat64("max_angle", 0),
+		Precision: attr.MustInt("precision", 3),
+	}, nil
+}
+
+// The list of component types this Factory should be used for
+func (tf FilletTransformFactory) TransformTypes() []string {
+	return []string{"fillet", "chamfer"}
+}
+
+type DogboneTransformFactory struct {
+}
+
+func (tf DogboneTransformFactory) CreateTransform(transformType string, dm *dynmap.DynMap, element Element) (path.PathTransform, error) {
+	attr := NewAttr(element, dm)
+	diameter, ok := attr.Float64("diameter")
+	if !ok {
+		return nil, createMissingAttributeError("diameter", transformType, dm)
+	}
+
+	var style path.DogboneStyle = path.DogboneBisector
+	styleName := attr.MustString("style", "bisector")
+	switch styleName {
+	case "bisector":
+	case "tbone_horizontal":
+		style = path.DogboneHorizontal
+	case "tbone_vertical":
+		style = path.DogboneVertical
+	default:
+		return nil, fmt.Errorf("Error, unknown dogbone style %s, expected bisector, tbone_horizontal or tbone_vertical", styleName)
+	}
+
+	return transforms.DogboneTransform{
+		Diameter:  diameter,
+		Style:     style,
+		Precision: attr.MustInt("precision", 3),
+	}, nil
+}
+
+// The list of component types this Factory should be used for
+func (tf DogboneTransformFactory) TransformTypes() []string {
+	return []string{"dogbone"}
+}
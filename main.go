@@ -3,12 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/dustismo/heavyfishdesign/dom"
 	"github.com/dustismo/heavyfishdesign/dynmap"
@@ -26,13 +28,19 @@ func main() {
 	// various flags
 	renderFilename := flag.String("path", "", "Path to the file to render")
 	outputFile := flag.String("output_file", "", "File name to save")
+	format := flag.String("format", "svg", "The output format [svg|dxf|gcode|pdf|png|lbrn2]")
+	dpi := flag.Float64("dpi", 0, "The resolution of png output, overrides the png_dpi document param")
+	watch := flag.Bool("watch", false, "Keep running and re-render when the design or any of its imports change")
+	stock := flag.String("stock", "", "A stock file listing the available sheets, overrides the stock_file document param")
+	material := flag.String("material", "", "The material to render with, overrides the material document param")
+	machine := flag.String("machine", "", "The machine profile used for estimates, overrides the machine document param")
 
 	renderDirectory := flag.String("render_dir", "designs/", "The Directory to render (recursively)")
 	outputDirectory := flag.String("output_dir", "", "The Directory to render into")
 	compareDirectory := flag.String("compare_dir", "designs_rendered", "The Directory to compare the current render to")
 
 	if len(os.Args) < 2 {
-		fmt.Printf("Usage: \n \t$ run main.go [serve|render|render_all|diff_test|designs_updated|svg_to_path]\n")
+		fmt.Printf("Usage: \n \t$ run main.go [serve|render|render_all|report|estimate|diff_test|designs_updated|svg_to_path]\n")
 		return
 	}
 	command := os.Args[1]
@@ -47,6 +55,11 @@ func main() {
 
 	if command == "serve" {
 		http.Handle("/json", http.HandlerFunc(processRequest))
+		http.Handle("/png", http.HandlerFunc(processPNGRequest))
+		http.Handle("/report", http.HandlerFunc(processReportRequest))
+		http.Handle("/estimate", http.HandlerFunc(processEstimateRequest))
+		preview := &previewServer{renderDir: *renderDirectory}
+		preview.register(http.DefaultServeMux)
 		err := http.ListenAndServe(":2003", nil)
 		if err != nil {
 			log.Fatal("ListenAndServe:", err)
@@ -63,31 +76,91 @@ func main() {
 			return
 		}
 
-		planset, err := renderPlanSet(rfn, dynmap.New(), logger)
+		if *watch {
+			render := func(filename string, logger *util.HfdLog) {
+				renderFile(filename, createFilename(*outputFile, filename), *format, renderParams(*dpi, *stock, *material), logger)
+			}
+			render(rfn, logger)
+			designs := func() ([]string, error) {
+				return []string{rfn}, nil
+			}
+			WatchDesigns(designs, renderParams(*dpi, *stock, *material), render, logger)
+			return
+		}
+
+		planset, err := renderPlanSet(rfn, renderParams(*dpi, *stock, *material), logger)
 		if err != nil {
 			log.Fatalf("Error during planset render: %s\n", err.Error())
 			return
 		}
 
 		outFile := createFilename(*outputFile, rfn)
-		err = save(planset, outFile, logger)
+		err = save(planset, outFile, *format, logger)
 		if err != nil {
 			fmt.Printf("Error during save: %s\n", err.Error())
 			return
 		}
+	} else if command == "report" {
+		logger := util.NewLog()
+		rfn := *renderFilename
+		if len(rfn) == 0 && len(os.Args) > 2 {
+			rfn = os.Args[2]
+		}
+		if len(rfn) == 0 {
+			log.Fatalf("Report filename is required")
+			return
+		}
+		planset, err := renderPlanSet(rfn, renderParams(*dpi, *stock, *material), logger)
+		if err != nil {
+			log.Fatalf("Error during planset render: %s\n", err.Error())
+			return
+		}
+		writeReport(planset, *format, os.Stdout)
+	} else if command == "estimate" {
+		logger := util.NewLog()
+		rfn := *renderFilename
+		if len(rfn) == 0 && len(os.Args) > 2 {
+			rfn = os.Args[2]
+		}
+		if len(rfn) == 0 {
+			log.Fatalf("Estimate filename is required")
+			return
+		}
+		params := renderParams(*dpi, *stock, *material)
+		if len(*machine) > 0 {
+			params.Put("machine", *machine)
+		}
+		planset, err := renderPlanSet(rfn, params, logger)
+		if err != nil {
+			log.Fatalf("Error during planset render: %s\n", err.Error())
+			return
+		}
+		err = writeEstimate(planset, *format, os.Stdout)
+		if err != nil {
+			log.Fatalf("Error during estimate: %s\n", err.Error())
+			return
+		}
 	} else if command == "render_all" {
 		logger := util.NewLog()
 
-		err := RenderAll(*renderDirectory, *outputDirectory, logger)
+		err := RenderAll(*renderDirectory, *outputDirectory, *format, renderParams(*dpi, *stock, *material), logger)
 		if err != nil {
 			logger.Errorf("error %s", err.Error())
 			return
 		}
+		if *watch {
+			designs := func() ([]string, error) {
+				return util.FileList(*renderDirectory, FileExtension)
+			}
+			WatchDesigns(designs, renderParams(*dpi, *stock, *material), func(filename string, logger *util.HfdLog) {
+				renderFile(filename, createFilename(*outputDirectory, filename), *format, renderParams(*dpi, *stock, *material), logger)
+			}, logger)
+		}
 	} else if command == "designs_updated" {
 		logger := util.NewLog()
 		// clear out the designs rendered directory
 		util.ClearDir("designs_rendered", "svg")
-		err := RenderAll("designs", "designs_rendered", logger)
+		err := RenderAll("designs", "designs_rendered", "svg", dynmap.New(), logger)
 		if err != nil {
 			logger.Errorf("error %s", err.Error())
 			return
@@ -104,7 +177,7 @@ func main() {
 			}
 			outputDirectory = &od
 		}
-		err := RenderAll(*renderDirectory, *outputDirectory, logger)
+		err := RenderAll(*renderDirectory, *outputDirectory, "svg", dynmap.New(), logger)
 		if err != nil {
 			fmt.Printf("error %s", err.Error())
 			return
@@ -154,7 +227,7 @@ func main() {
 		fmt.Print(path.SvgString(p, dom.AppContext().Precision()))
 		return
 	} else {
-		fmt.Printf("Usage: \n \t$ run main.go [serve|render|render_all|diff_test|designs_updated|svg_to_path]\n")
+		fmt.Printf("Usage: \n \t$ run main.go [serve|render|render_all|report|estimate|diff_test|designs_updated|svg_to_path]\n")
 	}
 }
 
@@ -231,7 +304,7 @@ func CreateDir(dir string) error {
 	return os.MkdirAll(dir, os.ModePerm)
 }
 
-func RenderAll(renderDir, outputDir string, logger *util.HfdLog) error {
+func RenderAll(renderDir, outputDir, format string, params *dynmap.DynMap, logger *util.HfdLog) error {
 	filenames, err := util.FileList(renderDir, FileExtension)
 	if err != nil {
 		logger.Errorf("Error during render_all: %s\n", err.Error())
@@ -246,18 +319,96 @@ func RenderAll(renderDir, outputDir string, logger *util.HfdLog) error {
 	for _, rf := range filenames {
 		planLogger := logger.NewChild()
 		planLogger.StaticFields.Put("filename", rf)
-		planset, err := renderPlanSet(rf, dynmap.New(), planLogger)
+		renderFile(rf, createFilename(outputDir, rf), format, params.Clone(), planLogger)
+	}
+	return nil
+}
+
+// renders a single design and saves it, any errors are logged
+func renderFile(filename, outFile, format string, params *dynmap.DynMap, logger *util.HfdLog) {
+	planset, err := renderPlanSet(filename, params, logger)
+	if err != nil {
+		logger.Errorf("Error during planset %s : %s\n", filename, err.Error())
+		return
+	}
+	err = save(planset, outFile, format, logger)
+	if err != nil {
+		logger.Errorf("Error during save %s : %s\n", filename, err.Error())
+	}
+}
+
+// how often to check for changed files
+var watchInterval = 500 * time.Millisecond
+
+// watches the designs and every file in their import graph, with the
+// params that override the document params.  When a file changes only the
+// designs that depend on it are rendered again.
+// Errors are logged and watching continues, this never returns.
+func WatchDesigns(designs func() ([]string, error), params *dynmap.DynMap, render func(filename string, logger *util.HfdLog), logger *util.HfdLog) {
+	watcher := util.NewFileWatcher()
+	graphs := map[string][]string{}
+	updateGraph := func(design string) {
+		files, err := dom.ImportGraph(design, params)
 		if err != nil {
-			planLogger.Errorf("Error during planset %s : %s\n", rf, err.Error())
-		} else {
-			outFile := createFilename(outputDir, rf)
-			err = save(planset, outFile, planLogger)
-			if err != nil {
-				planLogger.Errorf("Error during save %s : %s\n", rf, err.Error())
+			logger.Errorf("Error finding imports for %s : %s\n", design, err.Error())
+			// keep the previous imports so fixing them triggers a render
+			files = graphs[design]
+			if len(files) == 0 {
+				files = []string{design}
 			}
 		}
+		graphs[design] = files
+		watcher.Add(files...)
+	}
+
+	filenames, err := designs()
+	if err != nil {
+		logger.Errorf("Error listing designs: %s\n", err.Error())
+	}
+	for _, fn := range filenames {
+		updateGraph(fn)
+	}
+	fmt.Printf("Watching %d designs for changes\n", len(graphs))
+
+	for {
+		time.Sleep(watchInterval)
+
+		// pick up any new designs
+		filenames, err := designs()
+		if err != nil {
+			logger.Errorf("Error listing designs: %s\n", err.Error())
+			continue
+		}
+		rerender := map[string]bool{}
+		for _, fn := range filenames {
+			if _, ok := graphs[fn]; !ok {
+				updateGraph(fn)
+				rerender[fn] = true
+			}
+		}
+
+		for _, changed := range watcher.Changed() {
+			for design, files := range graphs {
+				for _, f := range files {
+					if f == changed {
+						rerender[design] = true
+					}
+				}
+			}
+		}
+
+		for _, fn := range filenames {
+			if !rerender[fn] {
+				continue
+			}
+			fmt.Printf("Changed: %s\n", fn)
+			planLogger := logger.NewChild()
+			planLogger.StaticFields.Put("filename", fn)
+			render(fn, planLogger)
+			// imports may have changed
+			updateGraph(fn)
+		}
 	}
-	return nil
 }
 
 // getDocument parses an HFD file with params and returns the document (no PlanSet).
@@ -285,11 +436,38 @@ func renderPlanSet(filename string, params *dynmap.DynMap, logger *util.HfdLog)
 	context := dom.RenderContext{
 		Origin: path.NewPoint(0, 0),
 		Cursor: path.NewPoint(0, 0),
+		Log:    logger,
 	}
 	err = planset.Init(context)
 	return planset, err
 }
 
+// writes the layout report for the planset, as json if the format is
+// json, otherwise as plain text
+func writeReport(planset *dom.PlanSet, format string, w io.Writer) {
+	report := planset.Report()
+	if format == "json" {
+		fmt.Fprintf(w, "%s\n", report.ToDynMap().ToJSON())
+		return
+	}
+	report.WriteText(w)
+}
+
+// writes the job estimate for the planset, as json if the format is
+// json, otherwise as plain text
+func writeEstimate(planset *dom.PlanSet, format string, w io.Writer) error {
+	estimate, err := planset.Estimate()
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		fmt.Fprintf(w, "%s\n", estimate.ToDynMap().ToJSON())
+		return nil
+	}
+	estimate.WriteText(w)
+	return nil
+}
+
 // construct a suitable saveFile name from the give path + document name
 // for instance:
 // filepath = "/home/my_document/"
@@ -335,7 +513,37 @@ func IsDir(filePath string) bool {
 	return false
 }
 
-func save(planset *dom.PlanSet, saveFile string, logger *util.HfdLog) error {
+// the content type for each of the output formats
+var contentTypes = map[string]string{
+	"svg":   "image/svg+xml",
+	"dxf":   "application/dxf",
+	"gcode": "text/x-gcode",
+	"pdf":   "application/pdf",
+	"png":   "image/png",
+	"lbrn2": "application/xml",
+}
+
+// writes a single document in the requested format
+func writeDocument(svgDoc *dom.SVGDocument, format string, ctx dom.RenderContext, w io.Writer) error {
+	switch format {
+	case "svg":
+		svgDoc.WriteSVG(ctx, w)
+		return nil
+	case "dxf":
+		return svgDoc.WriteDXF(ctx, w)
+	case "gcode":
+		return svgDoc.WriteGCode(ctx, w)
+	case "pdf":
+		return svgDoc.WritePDF(ctx, w)
+	case "png":
+		return svgDoc.WritePNG(ctx, w)
+	case "lbrn2":
+		return svgDoc.WriteLightBurn(ctx, w)
+	}
+	return fmt.Errorf("Unknown output format %s", format)
+}
+
+func save(planset *dom.PlanSet, saveFile string, format string, logger *util.HfdLog) error {
 	svgDocs := planset.SVGDocuments()
 	context := dom.RenderContext{
 		Origin: path.NewPoint(0, 0),
@@ -343,19 +551,126 @@ func save(planset *dom.PlanSet, saveFile string, logger *util.HfdLog) error {
 		Log:    logger,
 	}
 
-	for i, svgDoc := range svgDocs {
-		fn := fmt.Sprintf("%s_%03d.svg", saveFile, i)
+	if planset.UsesStock() {
+		err := saveStockReport(planset, saveFile, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format == "pdf" {
+		// pdf is a single multi page file for the whole planset
+		fn := fmt.Sprintf("%s.pdf", saveFile)
 		f, err := os.Create(fn)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
 		logger.Infof("SAVING: %s\n", fn)
-		svgDoc.WriteSVG(context, f)
+		return planset.WritePDF(context, f)
+	}
+
+	// with more than one material the documents of each material are
+	// numbered separately, and the material is part of the filename
+	counts := map[string]int{}
+	for _, svgDoc := range svgDocs {
+		name := saveFile
+		if planset.HasMaterialGroups() {
+			name = fmt.Sprintf("%s_%s", saveFile, svgDoc.Material)
+		}
+		fn := fmt.Sprintf("%s_%03d.%s", name, counts[svgDoc.Material], format)
+		counts[svgDoc.Material]++
+		f, err := os.Create(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		logger.Infof("SAVING: %s\n", fn)
+		err = writeDocument(svgDoc, format, context, f)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// writes the stock used and the offcuts left over as json
+func saveStockReport(planset *dom.PlanSet, saveFile string, logger *util.HfdLog) error {
+	fn := fmt.Sprintf("%s.stock.json", saveFile)
+	logger.Infof("SAVING: %s\n", fn)
+	return ioutil.WriteFile(fn, []byte(planset.StockReport().ToJSON()), 0644)
+}
+
+// the document params to override from the command line flags
+func renderParams(dpi float64, stock string, material string) *dynmap.DynMap {
+	params := dynmap.New()
+	if dpi > 0 {
+		params.Put("png_dpi", dpi)
+	}
+	if len(stock) > 0 {
+		params.Put("stock_file", stock)
+	}
+	if len(material) > 0 {
+		params.Put("material", material)
+	}
+	return params
+}
+
+// same as processRequest, but always renders a png.
+// the dpi param is a shortcut for png_dpi
+func processPNGRequest(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	q.Set("format", "png")
+	if dpi := q.Get("dpi"); len(dpi) > 0 {
+		q.Set("png_dpi", dpi)
+	}
+	req.URL.RawQuery = q.Encode()
+	processRequest(w, req)
+}
+
+// renders the file and returns the layout report as json
+func processReportRequest(w http.ResponseWriter, req *http.Request) {
+	params := dynmap.New()
+	err := req.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params.UnmarshalUrlValues(req.Form)
+	filename := params.MustString("file", "dom/testdata/box_test.hfd")
+	params.Remove("file")
+	planset, err := renderPlanSet(filename, params, util.NewLog())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, planset.Report().ToDynMap())
+}
+
+// renders the file and returns the job estimate as json
+func processEstimateRequest(w http.ResponseWriter, req *http.Request) {
+	params := dynmap.New()
+	err := req.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params.UnmarshalUrlValues(req.Form)
+	filename := params.MustString("file", "dom/testdata/box_test.hfd")
+	params.Remove("file")
+	planset, err := renderPlanSet(filename, params, util.NewLog())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	estimate, err := planset.Estimate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, estimate.ToDynMap())
+}
+
 func processRequest(w http.ResponseWriter, req *http.Request) {
 	params := dynmap.New()
 	err := req.ParseForm()
@@ -376,13 +691,23 @@ func processRequest(w http.ResponseWriter, req *http.Request) {
 		Origin: path.NewPoint(0, 0),
 		Cursor: path.NewPoint(0, 0),
 	}
-	svgDocs[params.MustInt("index", 0)].WriteSVG(context, w)
-	w.Header().Set("Content-Type", "image/svg+xml")
+	format := params.MustString("format", "svg")
+	contentType, ok := contentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown output format %s", format), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	err = writeDocument(svgDocs[params.MustInt("index", 0)], format, context, w)
+	if err != nil {
+		logger.Errorf("Error during render: %s\n", err.Error())
+		return
+	}
 
 	saveFile, ok := params.GetString("save_file")
 	if ok {
 		sf := createFilename(filename, saveFile)
-		err = save(planset, sf, logger)
+		err = save(planset, sf, format, logger)
 		if err != nil {
 			logger.Errorf("Error during save: %s\n", err.Error())
 			return
@@ -0,0 +1,501 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dustismo/heavyfishdesign/dom"
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+// Parses the entities of a DXF file into a path.
+// Supported entities are LINE, LWPOLYLINE, POLYLINE, ARC, CIRCLE, ELLIPSE and SPLINE.
+// DXF has the y axis pointing up, so the result is flipped to match the svg
+// coordinate system.
+type DXFParser struct {
+}
+
+// a single group code / value pair
+type dxfGroup struct {
+	Code  int
+	Value string
+}
+
+type dxfEntity struct {
+	Type   string
+	Groups []dxfGroup
+	// only used for POLYLINE entities
+	Vertices []*dxfEntity
+}
+
+// number of line segments used to approximate splines we cannot
+// convert directly to bezier curves, per knot span
+const dxfSplineSamples = 16
+
+func (e *dxfEntity) Layer() string {
+	return e.String(8, "0")
+}
+
+func (e *dxfEntity) String(code int, def string) string {
+	for _, g := range e.Groups {
+		if g.Code == code {
+			return g.Value
+		}
+	}
+	return def
+}
+
+func (e *dxfEntity) Float64(code int, def float64) float64 {
+	for _, g := range e.Groups {
+		if g.Code == code {
+			f, err := strconv.ParseFloat(g.Value, 64)
+			if err != nil {
+				return def
+			}
+			return f
+		}
+	}
+	return def
+}
+
+func (e *dxfEntity) Int(code int, def int) int {
+	return int(e.Float64(code, float64(def)))
+}
+
+// all the values for the given code in order
+func (e *dxfEntity) Floats(code int) []float64 {
+	floats := []float64{}
+	for _, g := range e.Groups {
+		if g.Code == code {
+			f, err := strconv.ParseFloat(g.Value, 64)
+			if err == nil {
+				floats = append(floats, f)
+			}
+		}
+	}
+	return floats
+}
+
+// the x,y points, where xCode is the group code of the x value
+// (y is always xCode + 10)
+func (e *dxfEntity) Points(xCode int) []path.Point {
+	points := []path.Point{}
+	for _, g := range e.Groups {
+		f, err := strconv.ParseFloat(g.Value, 64)
+		if err != nil {
+			continue
+		}
+		switch g.Code {
+		case xCode:
+			points = append(points, path.NewPoint(f, 0))
+		case xCode + 10:
+			if len(points) > 0 {
+				points[len(points)-1].Y = f
+			}
+		}
+	}
+	return points
+}
+
+func (e *dxfEntity) Point(xCode int) path.Point {
+	return path.NewPoint(e.Float64(xCode, 0), e.Float64(xCode+10, 0))
+}
+
+// reads the group code pairs from the dxf
+func readDXFGroups(dxf string) ([]dxfGroup, error) {
+	groups := []dxfGroup{}
+	scanner := bufio.NewScanner(strings.NewReader(dxf))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		codeStr := strings.TrimSpace(scanner.Text())
+		if len(codeStr) == 0 {
+			continue
+		}
+		code, err := strconv.Atoi(codeStr)
+		if err != nil {
+			return groups, fmt.Errorf("Error parsing dxf, invalid group code %s", codeStr)
+		}
+		if !scanner.Scan() {
+			return groups, fmt.Errorf("Error parsing dxf, missing value for group code %d", code)
+		}
+		groups = append(groups, dxfGroup{
+			Code:  code,
+			Value: strings.TrimSpace(scanner.Text()),
+		})
+	}
+	return groups, scanner.Err()
+}
+
+// finds all the entities in the ENTITIES section
+func readDXFEntities(groups []dxfGroup) []*dxfEntity {
+	entities := []*dxfEntity{}
+	inEntities := false
+	var current *dxfEntity
+	var polyline *dxfEntity
+	for i, g := range groups {
+		if g.Code != 0 {
+			if current != nil {
+				current.Groups = append(current.Groups, g)
+			}
+			continue
+		}
+		current = nil
+		switch g.Value {
+		case "SECTION":
+			if i+1 < len(groups) && groups[i+1].Code == 2 {
+				inEntities = groups[i+1].Value == "ENTITIES"
+			}
+		case "ENDSEC":
+			inEntities = false
+		case "VERTEX":
+			if inEntities && polyline != nil {
+				current = &dxfEntity{Type: g.Value}
+				polyline.Vertices = append(polyline.Vertices, current)
+			}
+		case "SEQEND":
+			polyline = nil
+		default:
+			if inEntities {
+				current = &dxfEntity{Type: g.Value}
+				entities = append(entities, current)
+				if g.Value == "POLYLINE" {
+					polyline = current
+				}
+			}
+		}
+	}
+	return entities
+}
+
+func (p DXFParser) ParseDXF(dxf string, layers []string, logger *util.HfdLog) (path.Path, error) {
+	groups, err := readDXFGroups(dxf)
+	if err != nil {
+		return nil, err
+	}
+
+	draw := path.NewDraw()
+	for _, e := range readDXFEntities(groups) {
+		if !dxfLayerMatch(e.Layer(), layers) {
+			continue
+		}
+		segments, err := dxfEntityToSegments(e)
+		if err != nil {
+			return nil, err
+		}
+		if segments == nil {
+			logger.Infof("Skipping unsupported dxf entity %s", e.Type)
+			continue
+		}
+		if len(segments) == 0 {
+			continue
+		}
+		if !draw.CurrentPosition().EqualsPrecision(segments[0].Start(), dom.AppContext().Precision()) {
+			draw.MoveTo(segments[0].Start())
+		}
+		draw.AddSegments(segments)
+	}
+	return flipDXFPath(draw.Path())
+}
+
+func dxfLayerMatch(layer string, layers []string) bool {
+	if len(layers) == 0 {
+		return true
+	}
+	for _, l := range layers {
+		if strings.EqualFold(l, layer) {
+			return true
+		}
+	}
+	return false
+}
+
+// flips the y axis, keeping the path in the same location
+func flipDXFPath(pth path.Path) (path.Path, error) {
+	so := dom.AppContext().SegmentOperators()
+	if path.IsEmptyPath(pth) {
+		return pth, nil
+	}
+	tl, br, err := path.BoundingBoxTrimWhitespace(pth, so)
+	if err != nil {
+		return pth, err
+	}
+	pt := func(p path.Point) path.Point {
+		return path.NewPoint(p.X, tl.Y+br.Y-p.Y)
+	}
+	segments := []path.Segment{}
+	for _, seg := range pth.Segments() {
+		s, err := so.TransformSegment(seg, pt)
+		if err != nil {
+			return pth, err
+		}
+		segments = append(segments, s...)
+	}
+	return path.NewPathFromSegments(segments), nil
+}
+
+// converts the entity to segments (in dxf coordinates).
+// returns nil if the entity is not supported
+func dxfEntityToSegments(e *dxfEntity) ([]path.Segment, error) {
+	segments := []path.Segment{}
+	switch e.Type {
+	case "LINE":
+		segments = append(segments, path.LineSegment{
+			StartPoint: e.Point(10),
+			EndPoint:   e.Point(11),
+		})
+	case "LWPOLYLINE":
+		vertices := []path.Point{}
+		bulges := []float64{}
+		for _, g := range e.Groups {
+			f, err := strconv.ParseFloat(g.Value, 64)
+			if err != nil {
+				continue
+			}
+			switch g.Code {
+			case 10:
+				vertices = append(vertices, path.NewPoint(f, 0))
+				bulges = append(bulges, 0)
+			case 20:
+				if len(vertices) > 0 {
+					vertices[len(vertices)-1].Y = f
+				}
+			case 42:
+				if len(bulges) > 0 {
+					bulges[len(bulges)-1] = f
+				}
+			}
+		}
+		segments = polylineSegments(vertices, bulges, e.Int(70, 0)&1 == 1)
+	case "POLYLINE":
+		vertices := []path.Point{}
+		bulges := []float64{}
+		for _, v := range e.Vertices {
+			vertices = append(vertices, v.Point(10))
+			bulges = append(bulges, v.Float64(42, 0))
+		}
+		segments = polylineSegments(vertices, bulges, e.Int(70, 0)&1 == 1)
+	case "ARC":
+		start := path.DegreesToRadians(e.Float64(50, 0))
+		end := path.DegreesToRadians(e.Float64(51, 360))
+		sweep := end - start
+		for sweep <= 0 {
+			sweep += 2 * math.Pi
+		}
+		segments = curvesToSegments(path.ArcToCurves(e.Point(10), e.Float64(40, 0), start, sweep))
+	case "CIRCLE":
+		segments = curvesToSegments(path.ArcToCurves(e.Point(10), e.Float64(40, 0), 0, 2*math.Pi))
+	case "ELLIPSE":
+		major := e.Point(11)
+		rx := math.Sqrt(major.X*major.X + major.Y*major.Y)
+		ry := rx * e.Float64(40, 1)
+		start := e.Float64(41, 0)
+		end := e.Float64(42, 2*math.Pi)
+		sweep := end - start
+		for sweep <= 0 {
+			sweep += 2 * math.Pi
+		}
+		segments = curvesToSegments(path.EllipticalArcToCurves(
+			e.Point(10), rx, ry, math.Atan2(major.Y, major.X), start, sweep))
+	case "SPLINE":
+		s, err := splineSegments(e)
+		if err != nil {
+			return nil, err
+		}
+		segments = s
+	default:
+		return nil, nil
+	}
+
+	// entities with a negative extrusion direction are mirrored on the x axis
+	if e.Float64(230, 1) < 0 {
+		so := dom.AppContext().SegmentOperators()
+		mirrored := []path.Segment{}
+		for _, s := range segments {
+			m, err := so.TransformSegment(s, func(p path.Point) path.Point {
+				return path.NewPoint(-p.X, p.Y)
+			})
+			if err != nil {
+				return nil, err
+			}
+			mirrored = append(mirrored, m...)
+		}
+		segments = mirrored
+	}
+	return segments, nil
+}
+
+func curvesToSegments(curves []path.CurveSegment) []path.Segment {
+	segments := []path.Segment{}
+	for _, c := range curves {
+		segments = append(segments, c)
+	}
+	return segments
+}
+
+// creates the segments for a polyline, bulge is the tangent of 1/4 the
+// included angle of the arc between a vertex and the next vertex.
+func polylineSegments(vertices []path.Point, bulges []float64, closed bool) []path.Segment {
+	segments := []path.Segment{}
+	if closed && len(vertices) > 1 {
+		vertices = append(vertices, vertices[0])
+	}
+	for i := 1; i < len(vertices); i++ {
+		start := vertices[i-1]
+		end := vertices[i]
+		bulge := bulges[i-1]
+		if bulge == 0 || start.Equals(end) {
+			segments = append(segments, path.LineSegment{
+				StartPoint: start,
+				EndPoint:   end,
+			})
+			continue
+		}
+		sweep := 4 * math.Atan(bulge)
+		chord := path.Distance(start, end)
+		// distance from the middle of the chord to the center, along the
+		// left hand normal of the chord
+		h := (chord / 2) / math.Tan(sweep/2)
+		center := path.NewPoint(
+			(start.X+end.X)/2-h*(end.Y-start.Y)/chord,
+			(start.Y+end.Y)/2+h*(end.X-start.X)/chord,
+		)
+		radius := path.Distance(center, start)
+		startAngle := math.Atan2(start.Y-center.Y, start.X-center.X)
+		curves := path.ArcToCurves(center, radius, startAngle, sweep)
+		// make sure the endpoints are exact
+		curves[0].StartPoint = start
+		curves[len(curves)-1].EndPoint = end
+		segments = append(segments, curvesToSegments(curves)...)
+	}
+	return segments
+}
+
+// homogeneous point used for (possibly rational) spline evaluation
+type hPoint struct {
+	X, Y, W float64
+}
+
+func (h hPoint) lerp(o hPoint, a float64) hPoint {
+	return hPoint{
+		X: (1-a)*h.X + a*o.X,
+		Y: (1-a)*h.Y + a*o.Y,
+		W: (1-a)*h.W + a*o.W,
+	}
+}
+
+func (h hPoint) point() path.Point {
+	return path.NewPoint(h.X/h.W, h.Y/h.W)
+}
+
+// evaluates the blossom of the spline in knot span k with the
+// given arguments (there must be degree args). When all the args are
+// the same value this is the point on the curve (de Boor's algorithm).
+func splineBlossom(degree, k int, knots []float64, ctrl []hPoint, args []float64) hPoint {
+	d := make([]hPoint, degree+1)
+	for j := 0; j <= degree; j++ {
+		d[j] = ctrl[j+k-degree]
+	}
+	for r := 1; r <= degree; r++ {
+		for j := degree; j >= r; j-- {
+			left := knots[j+k-degree]
+			right := knots[j+1+k-r]
+			a := 0.0
+			if right != left {
+				a = (args[r-1] - left) / (right - left)
+			}
+			d[j] = d[j-1].lerp(d[j], a)
+		}
+	}
+	return d[degree]
+}
+
+// converts a spline entity to segments.  Non rational splines of
+// degree 3 or less are converted exactly into bezier curves, anything else
+// is approximated with lines.
+func splineSegments(e *dxfEntity) ([]path.Segment, error) {
+	degree := e.Int(71, 3)
+	knots := e.Floats(40)
+	points := e.Points(10)
+	weights := e.Floats(41)
+
+	if len(points) == 0 {
+		// only fit points, draw lines through them
+		fit := e.Points(11)
+		return polylineSegments(fit, make([]float64, len(fit)), false), nil
+	}
+	if degree < 1 || len(knots) != len(points)+degree+1 {
+		return nil, fmt.Errorf("Error parsing dxf, invalid spline with degree %d, %d knots and %d control points",
+			degree, len(knots), len(points))
+	}
+
+	rational := false
+	ctrl := []hPoint{}
+	for i, p := range points {
+		w := 1.0
+		if len(weights) == len(points) {
+			w = weights[i]
+		}
+		if w != 1 {
+			rational = true
+		}
+		ctrl = append(ctrl, hPoint{X: p.X * w, Y: p.Y * w, W: w})
+	}
+
+	repeat := func(a float64, n int) []float64 {
+		v := []float64{}
+		for i := 0; i < n; i++ {
+			v = append(v, a)
+		}
+		return v
+	}
+
+	segments := []path.Segment{}
+	for k := degree; k < len(points); k++ {
+		a := knots[k]
+		b := knots[k+1]
+		if a >= b {
+			continue
+		}
+		if rational || degree > 3 {
+			prev := splineBlossom(degree, k, knots, ctrl, repeat(a, degree)).point()
+			for i := 1; i <= dxfSplineSamples; i++ {
+				t := a + (b-a)*float64(i)/float64(dxfSplineSamples)
+				p := splineBlossom(degree, k, knots, ctrl, repeat(t, degree)).point()
+				segments = append(segments, path.LineSegment{StartPoint: prev, EndPoint: p})
+				prev = p
+			}
+			continue
+		}
+
+		// the bezier control points are the blossoms f(a..a, b..b)
+		bez := []path.Point{}
+		for i := 0; i <= degree; i++ {
+			args := append(repeat(a, degree-i), repeat(b, i)...)
+			bez = append(bez, splineBlossom(degree, k, knots, ctrl, args).point())
+		}
+		switch degree {
+		case 1:
+			segments = append(segments, path.LineSegment{StartPoint: bez[0], EndPoint: bez[1]})
+		case 2:
+			// elevate to cubic
+			segments = append(segments, path.CurveSegment{
+				StartPoint:        bez[0],
+				ControlPointStart: path.NewPoint(bez[0].X+(2.0/3.0)*(bez[1].X-bez[0].X), bez[0].Y+(2.0/3.0)*(bez[1].Y-bez[0].Y)),
+				ControlPointEnd:   path.NewPoint(bez[2].X+(2.0/3.0)*(bez[1].X-bez[2].X), bez[2].Y+(2.0/3.0)*(bez[1].Y-bez[2].Y)),
+				EndPoint:          bez[2],
+			})
+		case 3:
+			segments = append(segments, path.CurveSegment{
+				StartPoint:        bez[0],
+				ControlPointStart: bez[1],
+				ControlPointEnd:   bez[2],
+				EndPoint:          bez[3],
+			})
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+// joins the group codes and values into dxf text
+func testDXF(entities ...string) string {
+	return strings.Join(append(append(
+		[]string{"0", "SECTION", "2", "ENTITIES"}, entities...),
+		"0", "ENDSEC", "0", "EOF"), "\n")
+}
+
+func TestDXFLine(t *testing.T) {
+	dxf := testDXF(
+		"0", "LINE", "8", "cut", "10", "0", "20", "0", "30", "0", "11", "2", "21", "1", "31", "0",
+		"0", "LINE", "8", "cut", "10", "2", "20", "1", "30", "0", "11", "3", "21", "1", "31", "0",
+		"0", "LINE", "8", "other", "10", "5", "20", "5", "30", "0", "11", "6", "21", "6", "31", "0",
+	)
+	p, err := DXFParser{}.ParseDXF(dxf, []string{"CUT"}, util.NewLog())
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	// the y axis is flipped
+	expected := "M 0.000 1.000 L 2.000 0.000 L 3.000 0.000"
+	actual := path.SvgString(p, 3)
+	if expected != actual {
+		t.Errorf("Expected: %s\nActual: %s", expected, actual)
+	}
+}
+
+func TestDXFLWPolylineBulge(t *testing.T) {
+	// a 2x1 slot with half circles on the ends
+	dxf := testDXF(
+		"0", "LWPOLYLINE", "8", "0", "90", "4", "70", "1",
+		"10", "0", "20", "0",
+		"10", "2", "20", "0", "42", "1",
+		"10", "2", "20", "1",
+		"10", "0", "20", "1", "42", "1",
+	)
+	p, err := DXFParser{}.ParseDXF(dxf, []string{}, util.NewLog())
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	tl, br, err := path.BoundingBoxTrimWhitespace(p, path.NewSegmentOperators())
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	expected := "(X: -0.500, Y: 0.000) (X: 2.500, Y: 1.000)"
+	actual := tl.StringPrecision(3) + " " + br.StringPrecision(3)
+	if expected != actual {
+		t.Errorf("Expected: %s\nActual: %s", expected, actual)
+	}
+	if !path.PathCursor(p).EqualsPrecision(path.NewPoint(0, 1), 3) {
+		t.Errorf("Expected closed polyline to end at the start, got %s", path.SvgString(p, 3))
+	}
+}
+
+func TestDXFCircle(t *testing.T) {
+	dxf := testDXF(
+		"0", "CIRCLE", "8", "0", "10", "5", "20", "5", "30", "0", "40", "2",
+	)
+	p, err := DXFParser{}.ParseDXF(dxf, []string{}, util.NewLog())
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	if len(p.Segments()) != 5 {
+		t.Errorf("Expected a move and 4 curves, got %s", path.SvgString(p, 3))
+	}
+	tl, br, _ := path.BoundingBoxTrimWhitespace(p, path.NewSegmentOperators())
+	expected := "(X: 3.000, Y: 3.000) (X: 7.000, Y: 7.000)"
+	actual := tl.StringPrecision(3) + " " + br.StringPrecision(3)
+	if expected != actual {
+		t.Errorf("Expected: %s\nActual: %s", expected, actual)
+	}
+}
+
+func TestDXFSpline(t *testing.T) {
+	// a clamped cubic spline with a single span is a bezier curve,
+	// flipped around the bounding box of the curve (not the control points)
+	dxf := testDXF(
+		"0", "SPLINE", "8", "0", "70", "8", "71", "3", "72", "8", "73", "4",
+		"40", "0", "40", "0", "40", "0", "40", "0",
+		"40", "1", "40", "1", "40", "1", "40", "1",
+		"10", "0", "20", "0", "30", "0",
+		"10", "1", "20", "2", "30", "0",
+		"10", "3", "20", "2", "30", "0",
+		"10", "4", "20", "0", "30", "0",
+	)
+	p, err := DXFParser{}.ParseDXF(dxf, []string{}, util.NewLog())
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	expected := "M 0.000 1.500 C 1.000 -0.500 3.000 -0.500 4.000 1.500"
+	actual := path.SvgString(p, 3)
+	if expected != actual {
+		t.Errorf("Expected: %s\nActual: %s", expected, actual)
+	}
+}
+
+func TestDXFSplineMultipleSpans(t *testing.T) {
+	// uniform quadratic b-spline, should be split into two curves
+	dxf := testDXF(
+		"0", "SPLINE", "8", "0", "71", "2",
+		"40", "0", "40", "0", "40", "0", "40", "1", "40", "2", "40", "2", "40", "2",
+		"10", "0", "20", "0",
+		"10", "1", "20", "1",
+		"10", "2", "20", "0",
+		"10", "3", "20", "1",
+	)
+	p, err := DXFParser{}.ParseDXF(dxf, []string{}, util.NewLog())
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	segs := p.Segments()
+	if len(segs) != 3 {
+		t.Errorf("Expected a move and 2 curves, got %s", path.SvgString(p, 3))
+	}
+	if !path.PathCursor(p).EqualsPrecision(path.NewPoint(3, 0), 3) {
+		t.Errorf("Expected spline to end at the last control point, got %s", path.SvgString(p, 3))
+	}
+}
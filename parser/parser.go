@@ -39,11 +39,15 @@ func InitContext() {
 		dom.ScaleTransformFactory{},
 		dom.SliceTransformFactory{},
 		dom.RotateScaleTransformFactory{},
+		dom.BooleanTransformFactory{},
+		dom.FilletTransformFactory{},
+		dom.DogboneTransformFactory{},
 	}
 
 	pf := []dom.PartTransformerFactory{
 		dom.PartSplitterTransformerFactory{},
 		dom.PartLatheTransformerFactory{},
+		dom.TabsPartTransformerFactory{},
 	}
 	docParser := NewDocumentParser()
 	dom.AppContext().Init(
@@ -52,6 +56,7 @@ func InitContext() {
 		docParser,
 		docParser,
 		SVGParser{},
+		DXFParser{},
 	)
 }
 
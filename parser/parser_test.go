@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"fmt"
 	"io/ioutil"
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/dustismo/heavyfishdesign/dom"
@@ -157,6 +159,361 @@ func TestScaleWidthOnlyDoesNotUsePartHeightParam(t *testing.T) {
 	}
 }
 
+// a hidden component is cut out of the panel, and not drawn on its own
+func TestBooleanSubtractOperand(t *testing.T) {
+	InitContext()
+	rc := dom.RenderContext{}
+	json := `{
+		"params": {},
+		"parts": [{
+			"components": [{
+				"type": "draw",
+				"transforms": [
+					{ "type": "subtract", "operand": "notch" }
+				],
+				"commands": [
+					{ "command": "move", "to": "0, 0" },
+					{ "command": "rectangle", "width": 10, "height": 10 }
+				]
+			},
+			{
+				"type": "draw",
+				"id": "notch",
+				"hidden": true,
+				"commands": [
+					{ "command": "move", "to": "5, 5" },
+					{ "command": "rectangle", "width": 10, "height": 10 }
+				]
+			}]
+		}]
+	}`
+	dm, err := dynmap.ParseJSON(json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := dom.ParseDocument(dm, util.NewLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pth, _, err := doc.Parts[0].Render(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	so := path.NewSegmentOperators()
+	tl, br, err := path.BoundingBoxTrimWhitespace(pth, so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tl.EqualsPrecision(path.NewPoint(0, 0), 3) || !br.EqualsPrecision(path.NewPoint(10, 10), 3) {
+		t.Errorf("Expected the notch to stay inside the panel, got %s %s", tl.StringRounded(), br.StringRounded())
+	}
+	if l := path.PathLength(pth); math.Abs(l-40) > .001 {
+		t.Errorf("Expected a path length of 40, got %f: %s", l, path.SvgString(pth, 3))
+	}
+	if c := len(path.SplitPathOnMove(pth)); c != 1 {
+		t.Errorf("Expected a single contour, got %d: %s", c, path.SvgString(pth, 3))
+	}
+}
+
+// dogbones on the notches of a finger joint socket, the notch sides only
+// have a relief at the bottom so the overcut can use most of their length
+func TestDogboneFingerJoint(t *testing.T) {
+	InitContext()
+	rc := dom.RenderContext{}
+	render := func(depth float64, dogbone string) path.Path {
+		json := fmt.Sprintf(`{
+			"params": {
+				"finger_width": 0.5,
+				"finger_height": %f,
+				"finger_space": 0.5
+			},
+			"parts": [{
+				"transforms": [
+					{ "type": "join", "close_path": true }
+					%s
+				],
+				"components": [{
+					"type": "repeat_edge",
+					"padding_left": 0.25,
+					"padding_right": 0.25,
+					"from": "0,0",
+					"to": "5,0",
+					"repeatable": {
+						"type": "draw",
+						"commands": [
+							{ "command": "rel_line", "to": { "x": "finger_space / 2", "y": 0 } },
+							{ "command": "rel_line", "to": { "x": 0, "y": "finger_height" } },
+							{ "command": "rel_line", "to": { "x": "finger_width", "y": 0 } },
+							{ "command": "rel_line", "to": { "x": 0, "y": "0-finger_height" } },
+							{ "command": "rel_line", "to": { "x": "finger_space / 2", "y": 0 } }
+						]
+					},
+					"left": {
+						"type": "draw",
+						"commands": [
+							{ "command": "move", "to": "0,0" },
+							{ "command": "rel_line", "to": { "x": "left_width", "y": 0 } }
+						]
+					},
+					"right": {
+						"type": "draw",
+						"commands": [
+							{ "command": "move", "to": "0,0" },
+							{ "command": "rel_line", "to": { "x": "right_width", "y": 0 } }
+						]
+					}
+				},
+				{
+					"type": "draw",
+					"commands": [
+						{ "command": "move", "to": "5, 0" },
+						{ "command": "line", "to": "5, 3" },
+						{ "command": "line", "to": "0, 3" },
+						{ "command": "line", "to": "0, 0" }
+					]
+				}]
+			}]
+		}`, depth, dogbone)
+		dm, err := dynmap.ParseJSON(json)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := dom.ParseDocument(dm, util.NewLog())
+		if err != nil {
+			t.Fatal(err)
+		}
+		pth, _, err := doc.Parts[0].Render(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pth
+	}
+
+	tests := []struct {
+		depth float64
+		style string
+		// the offset from the corner to the arc center for a 0.25 bit,
+		// toward the opening of the notch
+		center path.Point
+	}{
+		{0.197, "bisector", path.NewPoint(.125/math.Sqrt2, -.125/math.Sqrt2)},
+		{0.4, "tbone_vertical", path.NewPoint(0, -.125)},
+	}
+	for _, test := range tests {
+		// the inside corners are at the bottom of each notch
+		corners := []path.Point{}
+		for _, seg := range render(test.depth, "").Segments() {
+			if _, ok := seg.(path.LineSegment); ok && math.Abs(seg.End().Y-test.depth) < .001 && math.Abs(seg.Start().Y) < .001 {
+				corners = append(corners, seg.End())
+			}
+			if _, ok := seg.(path.LineSegment); ok && math.Abs(seg.Start().Y-test.depth) < .001 && math.Abs(seg.End().Y) < .001 {
+				corners = append(corners, seg.Start())
+			}
+		}
+		if len(corners) == 0 || len(corners)%2 != 0 {
+			t.Fatalf("Expected notches, got %d corners", len(corners))
+		}
+
+		pth := render(test.depth, fmt.Sprintf(`, { "type": "dogbone", "diameter": 0.25, "style": "%s" }`, test.style))
+		arcs := []path.ArcSegment{}
+		for _, seg := range pth.Segments() {
+			if a, ok := seg.(path.ArcSegment); ok {
+				arcs = append(arcs, a)
+			}
+		}
+		if len(arcs) != len(corners) {
+			t.Errorf("Expected a %s dogbone in each of the %d notch corners of a %.3f deep notch, got %d: %s",
+				test.style, len(corners), test.depth, len(arcs), path.SvgString(pth, 3))
+			continue
+		}
+		for _, corner := range corners {
+			found := false
+			for _, a := range arcs {
+				dx := a.Center.X - corner.X
+				if math.Abs(math.Abs(dx)-test.center.X) < .001 && math.Abs(a.Center.Y-corner.Y-test.center.Y) < .001 {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected a %s dogbone at %s: %s", test.style, corner.StringRounded(), path.SvgString(pth, 3))
+			}
+		}
+	}
+}
+
+// parts of each material are laid out on their own sheets, with the
+// params of the material
+func TestMaterialPlanSet(t *testing.T) {
+	InitContext()
+	rect := func(id, material string) string {
+		return `{
+			"id": "` + id + `",
+			"material": "` + material + `",
+			"components": [{
+				"type": "draw",
+				"commands": [
+					{"command": "line", "to": {"x": 3, "y": 0}},
+					{"command": "line", "to": {"x": 3, "y": "material_thickness * 10"}},
+					{"command": "line", "to": {"x": 0, "y": "material_thickness * 10"}},
+					{"command": "line", "to": {"x": 0, "y": 0}}
+				]
+			}]
+		}`
+	}
+	json := `{
+		"params": {
+			"material_width": 10,
+			"material_height": 10,
+			"material_thickness": 0.2,
+			"layout_random_passes": 0,
+			"materials": {
+				"acrylic": {
+					"material_width": 4,
+					"material_height": 3,
+					"material_thickness": 0.125
+				}
+			}
+		},
+		"parts": [` + rect("bezel", "acrylic") + `,` + rect("carcass", "") + `,` + rect("bezel2", "acrylic") + `]
+	}`
+	dm, err := dynmap.ParseJSON(json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := dom.ParseDocument(dm, util.NewLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := dom.RenderContext{}
+	rendered, err := doc.Parts[0].RenderPart(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(rendered[0].Height-1.25) > 1e-9 {
+		t.Errorf("Expected the bezel to use the acrylic thickness, height %f", rendered[0].Height)
+	}
+
+	planset := dom.NewPlanSet(doc)
+	err = planset.Init(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"acrylic 4x3", " 10x10"}
+	actual := []string{}
+	for _, d := range planset.SVGDocuments() {
+		actual = append(actual, fmt.Sprintf("%s %gx%g", d.Material, d.Width, d.Height))
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected documents %v got %v", expected, actual)
+	}
+}
+
+// a material group can list its own stock
+func TestMaterialStock(t *testing.T) {
+	InitContext()
+	rect := func(id, material string) string {
+		return `{
+			"id": "` + id + `",
+			"material": "` + material + `",
+			"components": [{
+				"type": "draw",
+				"commands": [
+					{"command": "move", "to": "0, 0"},
+					{"command": "rectangle", "width": 3, "height": 2}
+				]
+			}]
+		}`
+	}
+	json := `{
+		"params": {
+			"doc_padding": 0,
+			"layout_random_passes": 0,
+			"stock": [{"name": "ply", "width": 20, "height": 20}],
+			"materials": {
+				"acrylic": {"stock": [{"name": "offcut", "width": 6, "height": 5}]}
+			}
+		},
+		"parts": [` + rect("bezel", "acrylic") + `,` + rect("carcass", "") + `]
+	}`
+	dm, err := dynmap.ParseJSON(json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := dom.ParseDocument(dm, util.NewLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	planset := dom.NewPlanSet(doc)
+	err = planset.Init(dom.RenderContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"acrylic offcut", " ply"}
+	actual := []string{}
+	for _, d := range planset.SVGDocuments() {
+		actual = append(actual, fmt.Sprintf("%s %s", d.Material, d.Stock.Name))
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected documents %v got %v", expected, actual)
+	}
+	if used := planset.StockReport().MustDynMapSlice("used", nil); len(used) != 2 {
+		t.Errorf("Expected both stock lists used, got %d", len(used))
+	}
+}
+
+// the document tabs are added to every part that does not opt out or
+// have its own tabs
+func TestTabsPlanSet(t *testing.T) {
+	InitContext()
+	rect := func(id, extra string) string {
+		return `{
+			"id": "` + id + `",` + extra + `
+			"components": [{
+				"type": "draw",
+				"commands": [
+					{"command": "move", "to": "0, 0"},
+					{"command": "rectangle", "width": 3, "height": 2}
+				]
+			}]
+		}`
+	}
+	json := `{
+		"params": {
+			"material_width": 10,
+			"material_height": 10,
+			"layout_random_passes": 0,
+			"tabs": {"width": 0.1, "count": 2, "score_marks": true}
+		},
+		"parts": [` +
+		rect("tabbed", "") + `,` +
+		rect("untabbed", `"tabs": false,`) + `,` +
+		rect("own_tabs", `"part_transformers": [{"type": "tabs", "width": 0.1, "count": 3}],`) + `]
+	}`
+	dm, err := dynmap.ParseJSON(json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := dom.ParseDocument(dm, util.NewLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	planset := dom.NewPlanSet(doc)
+	err = planset.Init(dom.RenderContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := planset.Report().Total
+	if total.Pierces[dom.Cut] != 6 {
+		t.Errorf("Expected 6 cut pierces, got %d", total.Pierces[dom.Cut])
+	}
+	if total.Pierces[dom.Score] != 2 {
+		t.Errorf("Expected 2 tab marks, got %d", total.Pierces[dom.Score])
+	}
+	if l := total.Length[dom.Cut]; math.Abs(l-(30-.5)) > .001 {
+		t.Errorf("Expected cut length %f, got %f", 30-.5, l)
+	}
+}
+
 func PartRenderEquals(p *dom.Part, rc dom.RenderContext, expected string, t *testing.T) bool {
 	r, _, _ := p.Render(rc)
 	actual := path.SvgString(r, 3)
@@ -232,9 +232,31 @@ func elementToPathNoTransforms(elem *Element, log *util.HfdLog) (path.Path, erro
 		draw.Rect(w, h)
 		return draw.Path(), nil
 
-	case "elipse":
+	case "ellipse":
 		// <ellipse cx="75" cy="75" rx="20" ry="5" stroke="red" fill="transparent" stroke-width="5"/>
-		return nil, fmt.Errorf("error, 'elipse' is not supported in svg parsing. yet...")
+		draw := path.NewDraw()
+		cx, ok := elem.Attributes.GetFloat64("cx")
+		if !ok {
+			return nil, fmt.Errorf("Ellipse must have 'cx' property")
+		}
+		cy, ok := elem.Attributes.GetFloat64("cy")
+		if !ok {
+			return nil, fmt.Errorf("Ellipse must have 'cy' property")
+		}
+		rx, ok := elem.Attributes.GetFloat64("rx")
+		if !ok {
+			return nil, fmt.Errorf("Ellipse must have 'rx' property")
+		}
+		ry, ok := elem.Attributes.GetFloat64("ry")
+		if !ok {
+			return nil, fmt.Errorf("Ellipse must have 'ry' property")
+		}
+		draw.MoveTo(path.NewPoint(
+			cx-rx,
+			cy-ry,
+		))
+		draw.Ellipse(rx, ry)
+		return draw.Path(), nil
 	case "polyline":
 		// <polyline points="60 110 65 120 70 115 75 130 80 125 85 140 90 135 95 150 100 145" stroke="orange" fill="transparent" stroke-width="5"/>
 		return nil, fmt.Errorf("error, 'polyline' is not supported in svg parsing. yet...")
@@ -176,3 +176,21 @@ func parse(svg string, validate bool) (*Element, error) {
 	element, err := Parse(strings.NewReader(svg), validate)
 	return element, err
 }
+
+func TestSvgEllipse(t *testing.T) {
+	svg := `<svg width="100px" height="100px" version="1.1" xmlns="http://www.w3.org/2000/svg"><ellipse cx="50" cy="40" rx="20" ry="10" style="fill:none;stroke:black;stroke-width:1px;"/></svg>`
+	p, err := SVGParser{}.ParseSVG(svg, util.NewLog())
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	tl, br, err := path.BoundingBoxTrimWhitespace(p, path.NewSegmentOperators())
+	if err != nil {
+		t.Errorf("Error %s", err.Error())
+	}
+	if !tl.EqualsPrecision(path.NewPoint(30, 30), 3) || !br.EqualsPrecision(path.NewPoint(70, 50), 3) {
+		t.Errorf("Unexpected bounding box %s %s", tl.StringPrecision(3), br.StringPrecision(3))
+	}
+	if !strings.Contains(path.SvgString(p, 3), "A 20.000 10.000") {
+		t.Errorf("Expected the ellipse to be an arc, got %s", path.SvgString(p, 3))
+	}
+}
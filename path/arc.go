@@ -0,0 +1,433 @@
+package path
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dustismo/heavyfishdesign/bezier"
+)
+
+// Approximates an elliptical arc with cubic bezier curves.
+// center is the center of the ellipse, rx and ry are the radii and
+// rotation is the angle (in radians) of the x axis of the ellipse.
+// startAngle and sweep are in radians and are the parametric angle of the ellipse,
+// a positive sweep moves from the x axis toward the y axis.
+// The arc is split into curves of at most 90 degrees.
+func EllipticalArcToCurves(center Point, rx, ry, rotation, startAngle, sweep float64) []CurveSegment {
+	curves := []CurveSegment{}
+	if sweep == 0 || rx == 0 || ry == 0 {
+		return curves
+	}
+	n := int(math.Ceil(math.Abs(sweep)/(math.Pi/2) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	delta := sweep / float64(n)
+	// length of the control point tangents for a unit circle
+	k := (4.0 / 3.0) * math.Tan(delta/4)
+
+	cosR := math.Cos(rotation)
+	sinR := math.Sin(rotation)
+	// the point on the (unrotated) ellipse, rotated and moved to center
+	toPoint := func(x, y float64) Point {
+		return NewPoint(
+			center.X+x*cosR-y*sinR,
+			center.Y+x*sinR+y*cosR,
+		)
+	}
+
+	a := startAngle
+	for i := 0; i < n; i++ {
+		b := a + delta
+		cosA, sinA := math.Cos(a), math.Sin(a)
+		cosB, sinB := math.Cos(b), math.Sin(b)
+		curves = append(curves, CurveSegment{
+			StartPoint:        toPoint(rx*cosA, ry*sinA),
+			ControlPointStart: toPoint(rx*(cosA-k*sinA), ry*(sinA+k*cosA)),
+			ControlPointEnd:   toPoint(rx*(cosB+k*sinB), ry*(sinB-k*cosB)),
+			EndPoint:          toPoint(rx*cosB, ry*sinB),
+		})
+		a = b
+	}
+	return curves
+}
+
+// replaces the arcs in the segments with the curves that approximate them,
+// for outputs that do not support arcs.
+func ArcsToCurves(segments []Segment) []Segment {
+	ret := []Segment{}
+	for _, seg := range segments {
+		a, ok := seg.(ArcSegment)
+		if !ok {
+			ret = append(ret, seg)
+			continue
+		}
+		for _, c := range a.ToCurves() {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Approximates a circular arc with cubic bezier curves.
+// angles are in radians
+func ArcToCurves(center Point, radius, startAngle, sweep float64) []CurveSegment {
+	return EllipticalArcToCurves(center, radius, radius, 0, startAngle, sweep)
+}
+
+// A circular arc (or straight line) that approximates part of a curve.
+// see FitCurveArcs
+type FittedArc struct {
+	Start  Point
+	End    Point
+	Center Point
+	// true if the arc sweeps clockwise, as drawn with the y axis down (svg)
+	Clockwise bool
+	// true if this section is straight, Center and Clockwise are meaningless
+	IsLine bool
+}
+
+// Returns the center and radius of the circle that passes through the three
+// points. ok is false if the points are colinear
+func CircleThroughPoints(a, b, c Point) (center Point, radius float64, ok bool) {
+	d := 2 * (a.X*(b.Y-c.Y) + b.X*(c.Y-a.Y) + c.X*(a.Y-b.Y))
+	if math.Abs(d) < 1e-12 {
+		return center, 0, false
+	}
+	a2 := a.X*a.X + a.Y*a.Y
+	b2 := b.X*b.X + b.Y*b.Y
+	c2 := c.X*c.X + c.Y*c.Y
+	center = NewPoint(
+		(a2*(b.Y-c.Y)+b2*(c.Y-a.Y)+c2*(a.Y-b.Y))/d,
+		(a2*(c.X-b.X)+b2*(a.X-c.X)+c2*(b.X-a.X))/d,
+	)
+	return center, Distance(center, a), true
+}
+
+// Approximates the curve with a list of circular arcs and lines, such that
+// none stray farther than tolerance from the curve.  Useful for output
+// formats (like gcode) that support arcs but not bezier curves.
+func FitCurveArcs(curve Curve, tolerance float64) []FittedArc {
+	return fitCurveArcs(bCC(curve), tolerance, 0, []FittedArc{})
+}
+
+func fitCurveArcs(c bezier.CubicCurve, tolerance float64, depth int, collect []FittedArc) []FittedArc {
+	start := bpP(c.Start)
+	end := bpP(c.End)
+	if curveFlatness(c) <= tolerance {
+		return append(collect, FittedArc{Start: start, End: end, IsLine: true})
+	}
+	mid := bpP(bezier.FindPoint(c, .5))
+	center, radius, ok := CircleThroughPoints(start, mid, end)
+	if ok {
+		fits := true
+		for _, t := range []float64{.125, .25, .375, .625, .75, .875} {
+			if math.Abs(Distance(center, bpP(bezier.FindPoint(c, t)))-radius) > tolerance {
+				fits = false
+				break
+			}
+		}
+		// which way the curve turns decides the arc direction
+		cross := (mid.X-start.X)*(end.Y-mid.Y) - (mid.Y-start.Y)*(end.X-mid.X)
+		if fits {
+			return append(collect, FittedArc{
+				Start:     start,
+				End:       end,
+				Center:    center,
+				Clockwise: cross > 0,
+			})
+		}
+	}
+	if depth >= flattenMaxDepth {
+		return append(collect, FittedArc{Start: start, End: end, IsLine: true})
+	}
+	left, right := bezier.SplitCurve(c, .5)
+	collect = fitCurveArcs(left, tolerance, depth+1, collect)
+	return fitCurveArcs(right, tolerance, depth+1, collect)
+}
+
+// An elliptical (or circular) arc.  The arc is part of the ellipse with the
+// given center and radii, whose x axis is rotated by Rotation.  StartAngle
+// and Sweep are the parametric angles of the arc, a positive sweep moves
+// from the x axis toward the y axis (clockwise as drawn with the y axis
+// down).  All angles are in radians.
+type ArcSegment struct {
+	StartPoint Point
+	EndPoint   Point
+	Center     Point
+	RadiusX    float64
+	RadiusY    float64
+	Rotation   float64
+	StartAngle float64
+	Sweep      float64
+}
+
+// creates the arc, the start and end points are calculated from the angles
+func NewArcSegment(center Point, rx, ry, rotation, startAngle, sweep float64) ArcSegment {
+	a := ArcSegment{
+		Center:     center,
+		RadiusX:    rx,
+		RadiusY:    ry,
+		Rotation:   rotation,
+		StartAngle: startAngle,
+		Sweep:      sweep,
+	}
+	a.StartPoint = a.PointAtAngle(startAngle)
+	a.EndPoint = a.PointAtAngle(startAngle + sweep)
+	if a.IsFull() {
+		a.EndPoint = a.StartPoint
+	}
+	return a
+}
+
+// creates an arc from the parameters of an svg arc command.
+// see https://www.w3.org/TR/SVG/implnote.html#ArcConversionEndpointToCenter
+// Returns a line if either radius is 0, and nil if start and end are the same
+// point (svg draws nothing in either case).  Radii that are too small to
+// reach the end are scaled up.
+func NewArcSegmentFromSvg(start Point, rx, ry, rotationDegrees float64, largeArc, sweep bool, end Point) Segment {
+	if start.Equals(end) {
+		return nil
+	}
+	rx = math.Abs(rx)
+	ry = math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return LineSegment{
+			StartPoint: start,
+			EndPoint:   end,
+		}
+	}
+	rotation := DegreesToRadians(rotationDegrees)
+	cosR, sinR := math.Cos(rotation), math.Sin(rotation)
+	// the midpoint, in the coordinates of the ellipse axes
+	dx := (start.X - end.X) / 2
+	dy := (start.Y - end.Y) / 2
+	x1 := cosR*dx + sinR*dy
+	y1 := -sinR*dx + cosR*dy
+
+	lambda := (x1*x1)/(rx*rx) + (y1*y1)/(ry*ry)
+	if lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	center := NewPoint(
+		cosR*cx1-sinR*cy1+(start.X+end.X)/2,
+		sinR*cx1+cosR*cy1+(start.Y+end.Y)/2,
+	)
+	startAngle := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	endAngle := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx)
+	delta := endAngle - startAngle
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+	return ArcSegment{
+		StartPoint: start,
+		EndPoint:   end,
+		Center:     center,
+		RadiusX:    rx,
+		RadiusY:    ry,
+		Rotation:   rotation,
+		StartAngle: startAngle,
+		Sweep:      delta,
+	}
+}
+
+func (a ArcSegment) Start() Point {
+	return a.StartPoint
+}
+
+func (a ArcSegment) End() Point {
+	return a.EndPoint
+}
+
+func (a ArcSegment) EndAngle() float64 {
+	return a.StartAngle + a.Sweep
+}
+
+func (a ArcSegment) Clone() Segment {
+	c := a
+	c.StartPoint = a.StartPoint.Clone()
+	c.EndPoint = a.EndPoint.Clone()
+	c.Center = a.Center.Clone()
+	return c
+}
+
+// true if both radii are the same
+func (a ArcSegment) IsCircle() bool {
+	return math.Abs(a.RadiusX-a.RadiusY) <= 1e-9*math.Max(a.RadiusX, a.RadiusY)
+}
+
+// true if the arc goes all the way around the ellipse
+func (a ArcSegment) IsFull() bool {
+	return math.Abs(a.Sweep) >= 2*math.Pi-1e-9
+}
+
+// the point on the ellipse at the parametric angle
+func (a ArcSegment) PointAtAngle(angle float64) Point {
+	x := a.RadiusX * math.Cos(angle)
+	y := a.RadiusY * math.Sin(angle)
+	cosR, sinR := math.Cos(a.Rotation), math.Sin(a.Rotation)
+	return NewPoint(
+		a.Center.X+x*cosR-y*sinR,
+		a.Center.Y+x*sinR+y*cosR,
+	)
+}
+
+// converts the point to the coordinates where the ellipse is the unit circle
+func (a ArcSegment) toUnit(p Point) Point {
+	cosR, sinR := math.Cos(a.Rotation), math.Sin(a.Rotation)
+	dx := p.X - a.Center.X
+	dy := p.Y - a.Center.Y
+	return NewPoint(
+		(cosR*dx+sinR*dy)/a.RadiusX,
+		(-sinR*dx+cosR*dy)/a.RadiusY,
+	)
+}
+
+// the parametric angle of the point, for points not on the ellipse
+// this is the angle of the closest point along the line to the center
+func (a ArcSegment) AngleOf(p Point) float64 {
+	u := a.toUnit(p)
+	return math.Atan2(u.Y, u.X)
+}
+
+// the angle swept from the start of the arc to the given angle, moving in
+// the direction of the arc.  Has the same sign as the sweep.
+func (a ArcSegment) sweepTo(angle float64) float64 {
+	s := math.Mod(angle-a.StartAngle, 2*math.Pi)
+	if a.Sweep >= 0 {
+		if s < 0 {
+			s += 2 * math.Pi
+		}
+		return s
+	}
+	if s > 0 {
+		s -= 2 * math.Pi
+	}
+	return s
+}
+
+// checks if the point is on the arc, the point is expected to be
+// on the ellipse.
+func (a ArcSegment) containsPoint(p Point, precision int) bool {
+	if a.IsFull() || p.EqualsPrecision(a.StartPoint, precision) || p.EqualsPrecision(a.EndPoint, precision) {
+		return true
+	}
+	return math.Abs(a.sweepTo(a.AngleOf(p))) <= math.Abs(a.Sweep)
+}
+
+// the arc with a new start point, on the ellipse, the end stays the same.
+// ok is false if the new start is not before the end
+func (a ArcSegment) withStart(p Point) (ArcSegment, bool) {
+	angle := a.AngleOf(p)
+	sweep := a.Sweep + math.Remainder(a.StartAngle-angle, 2*math.Pi)
+	a.StartPoint = p
+	a.StartAngle = angle
+	ok := sweep*a.Sweep > 0
+	a.Sweep = sweep
+	return a, ok
+}
+
+// the arc with a new end point, on the ellipse, the start stays the same.
+// ok is false if the new end is not after the start
+func (a ArcSegment) withEnd(p Point) (ArcSegment, bool) {
+	sweep := a.Sweep + math.Remainder(a.AngleOf(p)-a.EndAngle(), 2*math.Pi)
+	a.EndPoint = p
+	ok := sweep*a.Sweep > 0
+	a.Sweep = sweep
+	return a, ok
+}
+
+// the parametric angles where the line through p1 and p2 crosses
+// the ellipse, along with the position on the line (0 at p1, 1 at p2)
+func (a ArcSegment) lineCrossings(p1, p2 Point) (angles, positions []float64) {
+	q1 := a.toUnit(p1)
+	q2 := a.toUnit(p2)
+	dx := q2.X - q1.X
+	dy := q2.Y - q1.Y
+	qa := dx*dx + dy*dy
+	qb := 2 * (q1.X*dx + q1.Y*dy)
+	qc := q1.X*q1.X + q1.Y*q1.Y - 1
+	if qa == 0 {
+		return angles, positions
+	}
+	disc := qb*qb - 4*qa*qc
+	if disc < -1e-12 {
+		return angles, positions
+	}
+	roots := []float64{-qb / (2 * qa)}
+	if disc > 1e-12 {
+		sq := math.Sqrt(disc)
+		roots = []float64{(-qb - sq) / (2 * qa), (-qb + sq) / (2 * qa)}
+	}
+	for _, u := range roots {
+		angles = append(angles, math.Atan2(q1.Y+u*dy, q1.X+u*dx))
+		positions = append(positions, u)
+	}
+	return angles, positions
+}
+
+// Approximates the arc with cubic bezier curves, the first and last
+// curves start and end exactly at the arc start and end.
+func (a ArcSegment) ToCurves() []CurveSegment {
+	curves := EllipticalArcToCurves(a.Center, a.RadiusX, a.RadiusY, a.Rotation, a.StartAngle, a.Sweep)
+	if len(curves) > 0 {
+		curves[0].StartPoint = a.StartPoint
+		curves[len(curves)-1].EndPoint = a.EndPoint
+	}
+	return curves
+}
+
+// the arc as svg arc commands.  A full ellipse is written as two arcs, since
+// a single arc cannot start and end at the same point.
+func (a ArcSegment) SvgString(numDecimals int) string {
+	arc := func(sweep float64, end Point) string {
+		large := 0
+		// half circles are common, do not let rounding pick the flag
+		if math.Abs(sweep) > math.Pi+1e-9 {
+			large = 1
+		}
+		positive := 0
+		if sweep > 0 {
+			positive = 1
+		}
+		return fmt.Sprintf(precisionStr("A %.3f %.3f %.3f %d %d %.3f %.3f", numDecimals),
+			a.RadiusX,
+			a.RadiusY,
+			a.Rotation*180/math.Pi,
+			large,
+			positive,
+			end.X,
+			end.Y)
+	}
+	if a.IsFull() {
+		half := a.Sweep / 2
+		return arc(half, a.PointAtAngle(a.StartAngle+half)) + " " + arc(half, a.EndPoint)
+	}
+	return arc(a.Sweep, a.EndPoint)
+}
+
+func (a ArcSegment) UniqueString(numDecimals int) string {
+	return fmt.Sprintf(precisionStr("ARC (%.3f, %.3f) (%.3f, %.3f) (%.3f, %.3f) %.3f %.3f %.3f %.3f", numDecimals),
+		a.Start().X,
+		a.Start().Y,
+		a.Center.X,
+		a.Center.Y,
+		a.End().X,
+		a.End().Y,
+		a.RadiusX,
+		a.RadiusY,
+		a.Rotation,
+		a.Sweep,
+	)
+}
@@ -0,0 +1,194 @@
+package path
+
+import (
+	"math"
+	"testing"
+)
+
+func TestArcToCurves(t *testing.T) {
+	curves := ArcToCurves(NewPoint(0, 0), 10, 0, math.Pi)
+	if len(curves) != 2 {
+		t.Errorf("Expected 2 curves, got %d", len(curves))
+	}
+	end := curves[len(curves)-1].End()
+	if !end.EqualsPrecision(NewPoint(-10, 0), 3) {
+		t.Errorf("Expected end at (-10, 0), got %s", end.StringPrecision(3))
+	}
+}
+
+func TestFitCurveArcs(t *testing.T) {
+	// a quarter circle sweeping clockwise (y axis down)
+	curve := ArcToCurves(NewPoint(0, 0), 10, 0, math.Pi/2)[0]
+	arcs := FitCurveArcs(curve, .01)
+	if len(arcs) != 1 {
+		t.Errorf("Expected a single arc, got %d", len(arcs))
+	}
+	a := arcs[0]
+	if a.IsLine || !a.Clockwise {
+		t.Errorf("Expected a clockwise arc")
+	}
+	if !a.Center.EqualsPrecision(NewPoint(0, 0), 2) {
+		t.Errorf("Expected center at (0, 0), got %s", a.Center.StringPrecision(3))
+	}
+
+	// an s curve cannot be a single arc
+	sCurve := CurveSegment{
+		StartPoint:        NewPoint(0, 0),
+		ControlPointStart: NewPoint(5, 5),
+		ControlPointEnd:   NewPoint(5, -5),
+		EndPoint:          NewPoint(10, 0),
+	}
+	arcs = FitCurveArcs(sCurve, .01)
+	if len(arcs) < 2 {
+		t.Errorf("Expected the s curve to be split")
+	}
+	if !arcs[len(arcs)-1].End.Equals(sCurve.EndPoint) {
+		t.Errorf("Expected the last arc to end at the curve end")
+	}
+}
+
+func TestArcSegmentFromSvg(t *testing.T) {
+	// the top half of a circle, clockwise with the y axis down
+	seg := NewArcSegmentFromSvg(NewPoint(0, 0), 10, 10, 0, false, true, NewPoint(20, 0))
+	arc, ok := seg.(ArcSegment)
+	if !ok {
+		t.Fatalf("Expected an arc, got %+v", seg)
+	}
+	if !arc.Center.EqualsPrecision(NewPoint(10, 0), 6) {
+		t.Errorf("Expected center at (10, 0), got %s", arc.Center.StringPrecision(3))
+	}
+	if !PrecisionEquals(arc.Sweep, math.Pi, 6) {
+		t.Errorf("Expected a sweep of pi, got %f", arc.Sweep)
+	}
+	if !arc.PointAtAngle(arc.StartAngle+arc.Sweep/2).EqualsPrecision(NewPoint(10, -10), 6) {
+		t.Errorf("Expected the arc to pass through (10, -10)")
+	}
+
+	// radii that are too small are scaled up
+	arc = NewArcSegmentFromSvg(NewPoint(0, 0), 1, 1, 0, false, true, NewPoint(20, 0)).(ArcSegment)
+	if !PrecisionEquals(arc.RadiusX, 10, 6) {
+		t.Errorf("Expected the radius to be scaled to 10, got %f", arc.RadiusX)
+	}
+
+	if _, ok := NewArcSegmentFromSvg(NewPoint(0, 0), 0, 10, 0, false, true, NewPoint(20, 0)).(LineSegment); !ok {
+		t.Errorf("Expected a zero radius to be a line")
+	}
+}
+
+func TestArcSegmentOperators(t *testing.T) {
+	ops := NewSegmentOperators()
+	// quarter circle from (10, 0) to (0, 10) around the origin
+	arc := NewArcSegment(NewPoint(0, 0), 10, 10, 0, 0, math.Pi/2)
+
+	tl, br, err := ops.BoundingBox(NewArcSegment(NewPoint(0, 0), 10, 10, 0, -math.Pi/4, math.Pi/2))
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	if !PrecisionEquals(br.X, 10, 6) || !PrecisionEquals(tl.X, 10*math.Cos(math.Pi/4), 6) {
+		t.Errorf("Unexpected bounding box %s %s", tl.StringPrecision(3), br.StringPrecision(3))
+	}
+
+	rev, err := ops.Reverse(arc)
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	r := rev.(ArcSegment)
+	if !r.PointAtAngle(r.StartAngle).EqualsPrecision(arc.EndPoint, 6) ||
+		!r.PointAtAngle(r.EndAngle()).EqualsPrecision(arc.StartPoint, 6) {
+		t.Errorf("Reversed arc does not match, %+v", r)
+	}
+
+	// the line x = y crosses the arc at 45 degrees
+	line := LineSegment{StartPoint: NewPoint(0, 0), EndPoint: NewPoint(20, 20)}
+	points, err := ops.Intersect(arc, line)
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	expected := NewPoint(10*math.Cos(math.Pi/4), 10*math.Sin(math.Pi/4))
+	if len(points) != 1 || !points[0].EqualsPrecision(expected, 6) {
+		t.Errorf("Expected intersection at %s, got %+v", expected.StringPrecision(3), points)
+	}
+
+	// circles crossing at (0, 10) and (8, -6), only the first is on the arc
+	other := NewArcSegment(NewPoint(20, 10), 20, 20, 0, math.Pi/2, math.Pi/2+.3)
+	points, err = ops.Intersect(NewArcSegment(NewPoint(0, 0), 10, 10, 0, 0, 2*math.Pi), other)
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	if len(points) != 1 || !points[0].EqualsPrecision(NewPoint(0, 10), 6) {
+		t.Errorf("Expected intersection at (0, 10), got %+v", points)
+	}
+
+	segs, err := ops.Split(arc, expected)
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	if len(segs) != 2 || !PrecisionEquals(segs[0].(ArcSegment).Sweep, math.Pi/4, 6) ||
+		!segs[1].Start().EqualsPrecision(expected, 6) {
+		t.Errorf("Unexpected split %+v", segs)
+	}
+
+	// the offset is exact, and on the same side as the curve offset
+	segs, err = ops.Offset(arc, 2)
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	if len(segs) != 1 || !PrecisionEquals(segs[0].(ArcSegment).RadiusX, 8, 6) {
+		t.Errorf("Expected an arc with radius 8, got %+v", segs)
+	}
+	curves, err := ops.Offset(arc.ToCurves()[0], 2)
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	if !curves[0].Start().EqualsPrecision(segs[0].Start(), 2) {
+		t.Errorf("Expected the arc and curve offsets to match, %s %s",
+			curves[0].Start().StringPrecision(3), segs[0].Start().StringPrecision(3))
+	}
+
+	// rotating keeps the arc
+	segs, err = ops.TransformSegment(arc, func(p Point) Point {
+		return Rotate(90, p)
+	})
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	if _, ok := segs[0].(ArcSegment); !ok || len(segs) != 1 {
+		t.Errorf("Expected the rotated arc to be an arc, got %+v", segs)
+	}
+	if !segs[0].End().EqualsPrecision(Rotate(90, arc.EndPoint), 6) ||
+		!segs[0].(ArcSegment).PointAtAngle(segs[0].(ArcSegment).EndAngle()).EqualsPrecision(segs[0].End(), 6) {
+		t.Errorf("Rotated arc does not match, %+v", segs[0])
+	}
+
+	// mirroring keeps the arc, but reverses the direction
+	segs, _ = ops.TransformSegment(arc, func(p Point) Point {
+		return NewPoint(-p.X, p.Y)
+	})
+	m := segs[0].(ArcSegment)
+	if m.Sweep > 0 || !m.PointAtAngle(m.EndAngle()).EqualsPrecision(NewPoint(0, 10), 6) {
+		t.Errorf("Mirrored arc does not match, %+v", m)
+	}
+
+	// non uniform scaling converts to curves
+	segs, _ = ops.TransformSegment(arc, func(p Point) Point {
+		return NewPoint(p.X*2, p.Y)
+	})
+	if _, ok := segs[0].(CurveSegment); !ok || !segs[len(segs)-1].End().EqualsPrecision(NewPoint(0, 10), 6) {
+		t.Errorf("Expected the scaled arc to be curves, got %+v", segs)
+	}
+	if _, err := ops.TransformPoints(arc, func(p Point) Point {
+		return NewPoint(p.X*2, p.Y)
+	}); err == nil {
+		t.Errorf("Expected an error transforming the arc to a single segment")
+	}
+
+	// lines are joined to the arc where they meet the circle
+	joined, err := ops.Join(LineSegment{StartPoint: NewPoint(8, -10), EndPoint: NewPoint(8, -8)}, arc)
+	if err != nil {
+		t.Errorf("Error %v", err)
+	}
+	if len(joined) != 2 || !joined[0].End().EqualsPrecision(NewPoint(8, -6), 6) ||
+		!joined[1].Start().EqualsPrecision(NewPoint(8, -6), 6) {
+		t.Errorf("Unexpected join %+v", joined)
+	}
+}
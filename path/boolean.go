@@ -0,0 +1,539 @@
+package path
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/dustismo/heavyfishdesign/bezier"
+)
+
+// Boolean operations on closed paths.
+// Each move separated section of a path is a closed contour, contours inside
+// an odd number of other contours are holes.  Both paths are split wherever
+// their segments cross, then each piece is kept or dropped depending on
+// whether it is inside the other path, and the kept pieces are joined back
+// into closed contours.  Pieces that both paths share (touching edges) are
+// kept once or dropped depending on the operation.
+
+type BooleanOperation string
+
+const (
+	BooleanUnion     BooleanOperation = "union"
+	BooleanSubtract  BooleanOperation = "subtract"
+	BooleanIntersect BooleanOperation = "intersect"
+	BooleanXor       BooleanOperation = "xor"
+)
+
+var BooleanOperations = []BooleanOperation{BooleanUnion, BooleanSubtract, BooleanIntersect, BooleanXor}
+
+func ParseBooleanOperation(str string) (BooleanOperation, error) {
+	for _, op := range BooleanOperations {
+		if string(op) == str {
+			return op, nil
+		}
+	}
+	return BooleanUnion, fmt.Errorf("Error, unknown boolean operation %s", str)
+}
+
+// where a piece of one path is relative to the other path
+type pieceLocation int
+
+const (
+	pieceOutside pieceLocation = iota
+	pieceInside
+	// on the edge of the other path, going the same direction
+	pieceSame
+	// on the edge of the other path, going the opposite direction
+	pieceOpposite
+)
+
+// Combines the two closed paths.  Subtract removes b from a.
+// precision is the number of decimal places points need to match in
+// to be considered the same.
+func Boolean(op BooleanOperation, a, b Path, so SegmentOperators, precision int) (Path, error) {
+	tolerance := math.Pow(10, -float64(precision))
+	contoursA, err := booleanContours(a, so, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	contoursB, err := booleanContours(b, so, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	contoursA, contoursB, err = splitContours(contoursA, contoursB, so, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	locationsA := classifyPieces(contoursA, contoursB, tolerance)
+	locationsB := classifyPieces(contoursB, contoursA, tolerance)
+
+	// which pieces to keep, and if they should be reversed
+	keepA := map[pieceLocation]bool{}
+	keepB := map[pieceLocation]bool{}
+	switch op {
+	case BooleanUnion:
+		keepA = map[pieceLocation]bool{pieceOutside: false, pieceSame: false}
+		keepB = map[pieceLocation]bool{pieceOutside: false}
+	case BooleanIntersect:
+		keepA = map[pieceLocation]bool{pieceInside: false, pieceSame: false}
+		keepB = map[pieceLocation]bool{pieceInside: false}
+	case BooleanSubtract:
+		keepA = map[pieceLocation]bool{pieceOutside: false, pieceOpposite: false}
+		keepB = map[pieceLocation]bool{pieceInside: true}
+	case BooleanXor:
+		keepA = map[pieceLocation]bool{pieceOutside: false, pieceInside: true}
+		keepB = map[pieceLocation]bool{pieceOutside: false, pieceInside: true}
+	}
+
+	pieces := []Segment{}
+	// pieces in different groups are not joined unless there is no
+	// other choice, xor keeps a outside b apart from b outside a where
+	// they touch
+	groups := []int{}
+	collect := func(source int, contours [][]Segment, locations [][]pieceLocation, keep map[pieceLocation]bool) error {
+		for i, c := range contours {
+			for j, seg := range c {
+				reverse, ok := keep[locations[i][j]]
+				if !ok {
+					continue
+				}
+				if reverse {
+					seg, err = so.Reverse(seg)
+					if err != nil {
+						return err
+					}
+				}
+				group := 0
+				if op == BooleanXor {
+					group = source
+					if locations[i][j] == pieceInside {
+						group = 1 - source
+					}
+				}
+				pieces = append(pieces, seg)
+				groups = append(groups, group)
+			}
+		}
+		return nil
+	}
+	if err := collect(0, contoursA, locationsA, keepA); err != nil {
+		return nil, err
+	}
+	if err := collect(1, contoursB, locationsB, keepB); err != nil {
+		return nil, err
+	}
+
+	contours, err := stitchContours(pieces, groups, so, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	d := NewDraw()
+	for _, contour := range contours {
+		d.MoveTo(contour[0].Start())
+		d.AddSegments(contour)
+	}
+	return d.Path(), nil
+}
+
+// the closed contours of the path, outer contours and holes go in
+// opposite directions.
+func booleanContours(p Path, so SegmentOperators, tolerance float64) ([][]Segment, error) {
+	contours := [][]Segment{}
+	for _, section := range SplitPathOnMove(p) {
+		contour := []Segment{}
+		for _, seg := range section.Segments() {
+			if IsMove(seg) || isZeroLength(seg, tolerance) {
+				continue
+			}
+			contour = append(contour, seg)
+		}
+		if len(contour) == 0 {
+			continue
+		}
+		start, end := contour[0].Start(), Tail(contour).End()
+		if Distance(start, end) > tolerance {
+			return nil, fmt.Errorf("Error, boolean operations need closed paths, section from %s to %s is open",
+				start.StringRounded(), end.StringRounded())
+		}
+		contours = append(contours, contour)
+	}
+
+	polygons := contourPolygons(contours, tolerance)
+	for i, contour := range contours {
+		depth := 0
+		sample := segmentMidpoint(contour[0])
+		for j, polygon := range polygons {
+			if i != j && pointInPolygons(sample, [][]Point{polygon}) {
+				depth++
+			}
+		}
+		if (PolygonArea(polygons[i]) > 0) == (depth%2 == 0) {
+			continue
+		}
+		reversed := []Segment{}
+		for k := len(contour) - 1; k >= 0; k-- {
+			seg, err := so.Reverse(contour[k])
+			if err != nil {
+				return nil, err
+			}
+			reversed = append(reversed, seg)
+		}
+		contours[i] = reversed
+	}
+	return contours, nil
+}
+
+func isZeroLength(seg Segment, tolerance float64) bool {
+	if a, ok := seg.(ArcSegment); ok && a.IsFull() {
+		return false
+	}
+	if c, ok := seg.(CurveSegment); ok {
+		return Distance(c.StartPoint, c.EndPoint) <= tolerance &&
+			Distance(c.StartPoint, c.ControlPointStart) <= tolerance &&
+			Distance(c.StartPoint, c.ControlPointEnd) <= tolerance
+	}
+	return Distance(seg.Start(), seg.End()) <= tolerance
+}
+
+// splits the segments of both sets of contours wherever they cross
+func splitContours(contoursA, contoursB [][]Segment, so SegmentOperators, tolerance float64) ([][]Segment, [][]Segment, error) {
+	// the crossing points for each segment, by contour and segment index
+	cutsA := make([][][]Point, len(contoursA))
+	cutsB := make([][][]Point, len(contoursB))
+	for i, c := range contoursA {
+		cutsA[i] = make([][]Point, len(c))
+	}
+	for i, c := range contoursB {
+		cutsB[i] = make([][]Point, len(c))
+	}
+	for i, ca := range contoursA {
+		for j, segA := range ca {
+			tlA, brA, err := so.BoundingBox(segA)
+			if err != nil {
+				return nil, nil, err
+			}
+			for k, cb := range contoursB {
+				for l, segB := range cb {
+					tlB, brB, err := so.BoundingBox(segB)
+					if err != nil {
+						return nil, nil, err
+					}
+					// touching boxes count, edges can overlap
+					if tlA.X > brB.X+tolerance || tlB.X > brA.X+tolerance ||
+						tlA.Y > brB.Y+tolerance || tlB.Y > brA.Y+tolerance {
+						continue
+					}
+					points, err := so.Intersect(segA, segB)
+					if err != nil {
+						return nil, nil, err
+					}
+					// overlapping edges do not cross, so split where
+					// one ends on the other
+					for _, p := range []Point{segB.Start(), segB.End()} {
+						if pointOnSegment(segA, p, tolerance) {
+							cutsA[i][j] = append(cutsA[i][j], p)
+						}
+					}
+					for _, p := range []Point{segA.Start(), segA.End()} {
+						if pointOnSegment(segB, p, tolerance) {
+							cutsB[k][l] = append(cutsB[k][l], p)
+						}
+					}
+					for _, p := range points {
+						// crossings at the end of a segment are exactly that point
+						p = NewPoint(p.X, p.Y)
+						for _, end := range []Point{segA.Start(), segA.End(), segB.Start(), segB.End()} {
+							if Distance(p, end) <= tolerance {
+								p = end
+								break
+							}
+						}
+						cutsA[i][j] = append(cutsA[i][j], p)
+						cutsB[k][l] = append(cutsB[k][l], p)
+					}
+				}
+			}
+		}
+	}
+
+	split := func(contours [][]Segment, cuts [][][]Point) ([][]Segment, error) {
+		ret := [][]Segment{}
+		for i, c := range contours {
+			contour := []Segment{}
+			for j, seg := range c {
+				segs, err := splitSegment(seg, cuts[i][j], so, tolerance)
+				if err != nil {
+					return nil, err
+				}
+				contour = append(contour, segs...)
+			}
+			ret = append(ret, contour)
+		}
+		return ret, nil
+	}
+	a, err := split(contoursA, cutsA)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := split(contoursB, cutsB)
+	return a, b, err
+}
+
+// splits the segment at each of the points, the pieces end exactly on
+// the points
+func splitSegment(seg Segment, points []Point, so SegmentOperators, tolerance float64) ([]Segment, error) {
+	params := map[Point]float64{}
+	cuts := []Point{}
+	for _, p := range points {
+		if Distance(p, seg.Start()) <= tolerance || Distance(p, seg.End()) <= tolerance {
+			continue
+		}
+		params[p] = segmentParam(seg, p)
+		cuts = append(cuts, p)
+	}
+	sort.Slice(cuts, func(i, j int) bool {
+		return params[cuts[i]] < params[cuts[j]]
+	})
+
+	ret := []Segment{}
+	rest := seg
+	for _, p := range cuts {
+		if Distance(p, rest.Start()) <= tolerance || Distance(p, rest.End()) <= tolerance {
+			continue
+		}
+		splitAt := p
+		if c, ok := rest.(CurveSegment); ok {
+			// the curve operators use t when it is set
+			splitAt.t = curveParam(c, p)
+		}
+		segs, err := so.Split(rest, splitAt)
+		if err != nil {
+			return nil, err
+		}
+		if len(segs) != 2 {
+			continue
+		}
+		first := setSegmentEnd(segs[0], p)
+		rest, err = SetSegmentStart(segs[1], p)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, first)
+	}
+	return append(ret, rest), nil
+}
+
+// the position of the point along the segment, 0 at the start and 1 at the end
+func segmentParam(seg Segment, p Point) float64 {
+	switch s := seg.(type) {
+	case CurveSegment:
+		return curveParam(s, p)
+	case ArcSegment:
+		if s.Sweep == 0 {
+			return 0
+		}
+		return math.Abs(s.sweepTo(s.AngleOf(p)) / s.Sweep)
+	}
+	start, end := seg.Start(), seg.End()
+	l := Distance(start, end)
+	if l == 0 {
+		return 0
+	}
+	return ((p.X-start.X)*(end.X-start.X) + (p.Y-start.Y)*(end.Y-start.Y)) / (l * l)
+}
+
+// the t value of the point on the curve closest to p
+func curveParam(c CurveSegment, p Point) float64 {
+	curve := bCC(c)
+	steps := 100
+	best := 0.0
+	bestDistance := math.MaxFloat64
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		if d := Distance(bpP(bezier.FindPoint(curve, t)), p); d < bestDistance {
+			best, bestDistance = t, d
+		}
+	}
+	// narrow down around the closest sample
+	lo := math.Max(0, best-1/float64(steps))
+	hi := math.Min(1, best+1/float64(steps))
+	for i := 0; i < 50; i++ {
+		m1 := lo + (hi-lo)/3
+		m2 := hi - (hi-lo)/3
+		if Distance(bpP(bezier.FindPoint(curve, m1)), p) < Distance(bpP(bezier.FindPoint(curve, m2)), p) {
+			hi = m2
+		} else {
+			lo = m1
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// the point at the position along the segment, see segmentParam
+func segmentPoint(seg Segment, t float64) Point {
+	switch s := seg.(type) {
+	case CurveSegment:
+		return bpP(bezier.FindPoint(bCC(s), t))
+	case ArcSegment:
+		return s.PointAtAngle(s.StartAngle + s.Sweep*t)
+	}
+	start, end := seg.Start(), seg.End()
+	return NewPoint(start.X+(end.X-start.X)*t, start.Y+(end.Y-start.Y)*t)
+}
+
+// checks if the point lies on the segment
+func pointOnSegment(seg Segment, p Point, tolerance float64) bool {
+	t := segmentParam(seg, p)
+	if t < 0 || t > 1 {
+		return false
+	}
+	return Distance(segmentPoint(seg, t), p) <= tolerance
+}
+
+// sets the end point of the segment
+func setSegmentEnd(seg Segment, end Point) Segment {
+	switch s := seg.(type) {
+	case LineSegment:
+		s.EndPoint = end
+		return s
+	case CurveSegment:
+		s.EndPoint = end
+		return s
+	case ArcSegment:
+		s.EndPoint = end
+		return s
+	}
+	return seg
+}
+
+// the point halfway along the segment
+func segmentMidpoint(seg Segment) Point {
+	return segmentPoint(seg, .5)
+}
+
+// finds where each piece is relative to the other contours
+func classifyPieces(contours, other [][]Segment, tolerance float64) [][]pieceLocation {
+	polygons := contourPolygons(other, tolerance)
+	near := tolerance * 10
+	locations := [][]pieceLocation{}
+	for _, c := range contours {
+		locs := []pieceLocation{}
+		for _, seg := range c {
+			mid := segmentMidpoint(seg)
+			loc := pieceOutside
+			if pointInPolygons(mid, polygons) {
+				loc = pieceInside
+			}
+		shared:
+			for _, oc := range other {
+				for _, o := range oc {
+					if Distance(mid, segmentMidpoint(o)) > near {
+						continue
+					}
+					if Distance(seg.Start(), o.Start()) <= near && Distance(seg.End(), o.End()) <= near {
+						loc = pieceSame
+						break shared
+					}
+					if Distance(seg.Start(), o.End()) <= near && Distance(seg.End(), o.Start()) <= near {
+						loc = pieceOpposite
+						break shared
+					}
+				}
+			}
+			locs = append(locs, loc)
+		}
+		locations = append(locations, locs)
+	}
+	return locations
+}
+
+// the contours as polygons, curves are flattened to within tolerance
+func contourPolygons(contours [][]Segment, tolerance float64) [][]Point {
+	polygons := [][]Point{}
+	for _, c := range contours {
+		points := []Point{}
+		for _, polyline := range FlattenPath(NewPathFromSegments(c), tolerance) {
+			points = append(points, polyline...)
+		}
+		polygons = append(polygons, points)
+	}
+	return polygons
+}
+
+// checks if the point is inside the polygons, using the even-odd rule
+func pointInPolygons(p Point, polygons [][]Point) bool {
+	inside := false
+	for _, pts := range polygons {
+		if crossesOdd(p, pts) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// joins the pieces end to end into closed contours, preferring pieces
+// in the same group.  It is an error if the pieces cannot be closed.
+func stitchContours(pieces []Segment, groups []int, so SegmentOperators, tolerance float64) ([][]Segment, error) {
+	used := make([]bool, len(pieces))
+	contours := [][]Segment{}
+	for i := range pieces {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		contour := []Segment{pieces[i]}
+		start := pieces[i].Start()
+		end := pieces[i].End()
+		group := groups[i]
+		for Distance(start, end) > tolerance {
+			next := -1
+			reverse := false
+			for j := range pieces {
+				if !used[j] && Distance(pieces[j].Start(), end) <= tolerance {
+					if next < 0 || groups[j] == group {
+						next = j
+					}
+					if groups[j] == group {
+						break
+					}
+				}
+			}
+			if next < 0 {
+				// the pieces should all go the same direction, but
+				// accept one going backwards rather than leave a gap
+				for j := range pieces {
+					if !used[j] && Distance(pieces[j].End(), end) <= tolerance {
+						next = j
+						reverse = true
+						break
+					}
+				}
+			}
+			if next < 0 {
+				break
+			}
+			used[next] = true
+			seg := pieces[next]
+			if reverse {
+				if r, err := so.Reverse(seg); err == nil {
+					seg = r
+				}
+			}
+			if s, err := SetSegmentStart(seg, end); err == nil {
+				seg = s
+			}
+			contour = append(contour, seg)
+			end = seg.End()
+		}
+		if Distance(start, end) > tolerance {
+			return nil, fmt.Errorf("Error, boolean result does not close, contour from %s ends at %s",
+				start.StringRounded(), end.StringRounded())
+		}
+		if len(contour) > 1 {
+			contour[len(contour)-1] = setSegmentEnd(contour[len(contour)-1], start)
+		}
+		contours = append(contours, contour)
+	}
+	return contours, nil
+}
@@ -0,0 +1,140 @@
+package path
+
+import (
+	"math"
+	"testing"
+)
+
+func rectPath(x, y, w, h float64) Path {
+	d := NewDraw()
+	d.MoveTo(NewPoint(x, y))
+	d.Rect(w, h)
+	return d.Path()
+}
+
+// the area inside the path, holes are subtracted
+func booleanArea(t *testing.T, p Path, so SegmentOperators) float64 {
+	contours, err := booleanContours(p, so, .001)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	area := 0.0
+	for _, polygon := range contourPolygons(contours, .001) {
+		area += PolygonArea(polygon)
+	}
+	return area
+}
+
+func TestBooleanRects(t *testing.T) {
+	so := NewSegmentOperators()
+	a := rectPath(0, 0, 10, 10)
+	b := rectPath(5, 5, 10, 10)
+
+	tests := []struct {
+		op       BooleanOperation
+		area     float64
+		contours int
+	}{
+		{BooleanUnion, 175, 1},
+		{BooleanIntersect, 25, 1},
+		{BooleanSubtract, 75, 1},
+		{BooleanXor, 150, 2},
+	}
+	for _, test := range tests {
+		p, err := Boolean(test.op, a, b, so, 3)
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		if area := booleanArea(t, p, so); math.Abs(area-test.area) > .01 {
+			t.Errorf("Expected %s area %f, got %f: %s", test.op, test.area, area, SvgString(p, 2))
+		}
+		if c := len(SplitPathOnMove(p)); c != test.contours {
+			t.Errorf("Expected %s to have %d contours, got %d", test.op, test.contours, c)
+		}
+	}
+}
+
+func TestBooleanSharedEdge(t *testing.T) {
+	so := NewSegmentOperators()
+	a := rectPath(0, 0, 10, 10)
+	b := rectPath(10, 2, 5, 5)
+
+	p, err := Boolean(BooleanUnion, a, b, so, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if area := booleanArea(t, p, so); math.Abs(area-125) > .01 {
+		t.Errorf("Expected union area 125, got %f: %s", area, SvgString(p, 2))
+	}
+	if c := len(SplitPathOnMove(p)); c != 1 {
+		t.Errorf("Expected a single contour, got %d: %s", c, SvgString(p, 2))
+	}
+}
+
+func TestBooleanHole(t *testing.T) {
+	so := NewSegmentOperators()
+	panel := rectPath(0, 0, 20, 20)
+	d := NewDraw()
+	d.MoveTo(NewPoint(5, 5))
+	d.Ellipse(5, 5)
+	window := d.Path()
+
+	p, err := Boolean(BooleanSubtract, panel, window, so, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	expected := 400 - math.Pi*25
+	if area := booleanArea(t, p, so); math.Abs(area-expected) > .1 {
+		t.Errorf("Expected area %f, got %f: %s", expected, area, SvgString(p, 2))
+	}
+
+	// a circle crossing the edge is cut off
+	d = NewDraw()
+	d.MoveTo(NewPoint(15, 5))
+	d.Circle(5)
+	p, err = Boolean(BooleanIntersect, panel, d.Path(), so, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	expected = math.Pi * 25 / 2
+	if area := booleanArea(t, p, so); math.Abs(area-expected) > .2 {
+		t.Errorf("Expected area %f, got %f: %s", expected, area, SvgString(p, 2))
+	}
+
+	open := NewDraw()
+	open.MoveTo(NewPoint(0, 0))
+	open.LineTo(NewPoint(10, 10))
+	if _, err := Boolean(BooleanUnion, panel, open.Path(), so, 3); err == nil {
+		t.Errorf("Expected an error for an open path")
+	}
+}
+
+func TestStitchOpenContour(t *testing.T) {
+	so := NewSegmentOperators()
+	pieces := []Segment{
+		LineSegment{StartPoint: NewPoint(0, 0), EndPoint: NewPoint(10, 0)},
+		LineSegment{StartPoint: NewPoint(10, 0), EndPoint: NewPoint(10, 10)},
+		LineSegment{StartPoint: NewPoint(10, 10), EndPoint: NewPoint(0, 10)},
+	}
+	if _, err := stitchContours(pieces, []int{0, 0, 0}, so, .001); err == nil {
+		t.Errorf("Expected an error for pieces that do not close")
+	}
+	pieces = append(pieces, LineSegment{StartPoint: NewPoint(0, 10), EndPoint: NewPoint(0, 0)})
+	contours, err := stitchContours(pieces, []int{0, 0, 0, 0}, so, .001)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if len(contours) != 1 || len(contours[0]) != 4 {
+		t.Errorf("Expected one contour of 4 pieces, got %v", contours)
+	}
+}
+
+func TestParseBooleanOperation(t *testing.T) {
+	op, err := ParseBooleanOperation("subtract")
+	if err != nil || op != BooleanSubtract {
+		t.Errorf("Expected subtract, got %s", op)
+	}
+	if _, err := ParseBooleanOperation("merge"); err == nil {
+		t.Errorf("Expected an error for an unknown operation")
+	}
+}
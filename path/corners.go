@@ -0,0 +1,275 @@
+package path
+
+import (
+	"math"
+
+	"github.com/dustismo/heavyfishdesign/bezier"
+)
+
+// Corner fillets and chamfers.
+// A corner is where one segment ends and the next starts going a different
+// direction.  Both segments are trimmed back from the corner and the gap is
+// closed with a tangent arc (a fillet) or a straight line (a chamfer).
+// Corners between two lines get an exact arc, other corners get a bezier
+// curve tangent to both segments.  A segment is never trimmed by more than
+// half its length, so the radius of a fillet is reduced where the segments
+// are too short.
+// Convex corners turn the same way as the contour winds, concave corners
+// turn the other way, for instance the inside corners of a notch.
+
+type CornerSelection int
+
+const (
+	AllCorners CornerSelection = iota
+	ConvexCorners
+	ConcaveCorners
+)
+
+type CornerOptions struct {
+	// the fillet radius, or the chamfer distance from the corner
+	Radius  float64
+	Chamfer bool
+	Corners CornerSelection
+	// only corners where the path turns by at least MinAngle and at most
+	// MaxAngle degrees are changed.  A MaxAngle of 0 is no maximum
+	MinAngle float64
+	MaxAngle float64
+}
+
+// a corner that is being rounded, the segment before the corner ends
+// at param end, the segment after starts at param start
+type roundedCorner struct {
+	end   float64
+	start float64
+	seg   Segment
+}
+
+// Rounds or chamfers the corners of the path.
+func FilletCorners(p Path, options CornerOptions, precision int) (Path, error) {
+	if options.Radius <= 0 {
+		return p, nil
+	}
+	tolerance := math.Pow(10, -float64(precision))
+	d := NewDraw()
+	for _, section := range SplitPathOnMove(p) {
+		segs := []Segment{}
+		for _, seg := range section.Segments() {
+			if IsMove(seg) || isZeroLength(seg, tolerance) {
+				continue
+			}
+			segs = append(segs, seg)
+		}
+		if len(segs) == 0 {
+			continue
+		}
+		for _, seg := range filletSection(segs, options, tolerance) {
+			if len(d.Path().Segments()) == 0 || !d.CurrentPosition().Equals(seg.Start()) {
+				d.MoveTo(seg.Start())
+			}
+			d.AddSegment(seg)
+		}
+	}
+	return d.Path(), nil
+}
+
+func filletSection(segs []Segment, options CornerOptions, tolerance float64) []Segment {
+	n := len(segs)
+	closed := Distance(segs[0].Start(), segs[n-1].End()) <= tolerance
+	corners := n - 1
+	if closed {
+		corners = n
+	}
+	polygon := []Point{}
+	for _, polyline := range FlattenPath(NewPathFromSegments(segs), tolerance) {
+		polygon = append(polygon, polyline...)
+	}
+	winding := PolygonArea(polygon)
+
+	// the corner after each segment, if any
+	after := make([]*roundedCorner, n)
+	for k := 0; k < corners; k++ {
+		next := (k + 1) % n
+		if c, ok := roundCorner(segs[k], segs[next], winding, options, tolerance); ok {
+			after[k] = c
+		}
+	}
+
+	ret := []Segment{}
+	for i, seg := range segs {
+		start, end := 0.0, 1.0
+		before := i - 1
+		if before < 0 && closed {
+			before = n - 1
+		}
+		if before >= 0 && after[before] != nil {
+			start = after[before].start
+		}
+		if after[i] != nil {
+			end = after[i].end
+		}
+		ret = append(ret, subSegment(seg, start, end))
+		if after[i] != nil {
+			ret = append(ret, after[i].seg)
+		}
+	}
+	return ret
+}
+
+// finds the fillet or chamfer between the two segments, returns false
+// if the corner should be left alone
+func roundCorner(seg, next Segment, winding float64, options CornerOptions, tolerance float64) (*roundedCorner, bool) {
+	corner := seg.End()
+	if Distance(corner, next.Start()) > tolerance {
+		return nil, false
+	}
+	u1 := unitVector(segmentTangent(seg, 1))
+	u2 := unitVector(segmentTangent(next, 0))
+	turn := math.Atan2(u1.X*u2.Y-u1.Y*u2.X, u1.X*u2.X+u1.Y*u2.Y)
+	angle := math.Abs(turn)
+	// smooth joins and paths that double back have no corner to round
+	if angle < 1e-6 || angle > math.Pi-1e-6 {
+		return nil, false
+	}
+	convex := turn*winding > 0
+	if (options.Corners == ConvexCorners && !convex) || (options.Corners == ConcaveCorners && convex) {
+		return nil, false
+	}
+	degrees := angle * 180 / math.Pi
+	if degrees < options.MinAngle || (options.MaxAngle > 0 && degrees > options.MaxAngle) {
+		return nil, false
+	}
+
+	distance := options.Radius
+	if !options.Chamfer {
+		distance = options.Radius * math.Tan(angle/2)
+	}
+	distance = math.Min(distance, math.Min(SegmentLength(seg), SegmentLength(next))/2)
+	if distance <= tolerance {
+		return nil, false
+	}
+
+	c := &roundedCorner{
+		end:   paramAtDistance(seg, distance, true),
+		start: paramAtDistance(next, distance, false),
+	}
+	t1 := segmentPoint(seg, c.end)
+	t2 := segmentPoint(next, c.start)
+	_, line1 := seg.(LineSegment)
+	_, line2 := next.(LineSegment)
+	switch {
+	case options.Chamfer:
+		c.seg = LineSegment{StartPoint: t1, EndPoint: t2}
+	case line1 && line2:
+		radius := distance / math.Tan(angle/2)
+		c.seg = NewArcSegmentFromSvg(t1, radius, radius, 0, false, turn > 0, t2)
+	default:
+		c.seg = tangentCurve(t1, unitVector(segmentTangent(seg, c.end)), t2, unitVector(segmentTangent(next, c.start)))
+	}
+	if c.seg == nil {
+		return nil, false
+	}
+	return c, true
+}
+
+// the param of the point on the segment that is distance from the
+// corner, measured along the segment.  The corner is at the end of the
+// segment or at the start
+func paramAtDistance(seg Segment, distance float64, fromEnd bool) float64 {
+	t := 0.0
+	if _, ok := seg.(LineSegment); ok {
+		t = distance / SegmentLength(seg)
+	} else {
+		// the length from the corner grows moving away from it, even
+		// when the distance does not
+		near, far := 0.0, 1.0
+		for i := 0; i < 40; i++ {
+			mid := (near + far) / 2
+			sub := subSegment(seg, 0, mid)
+			if fromEnd {
+				sub = subSegment(seg, 1-mid, 1)
+			}
+			if SegmentLength(sub) < distance {
+				near = mid
+			} else {
+				far = mid
+			}
+		}
+		t = (near + far) / 2
+	}
+	if fromEnd {
+		return 1 - t
+	}
+	return t
+}
+
+// the direction of the segment at the param, not normalized
+func segmentTangent(seg Segment, t float64) Point {
+	switch s := seg.(type) {
+	case LineSegment:
+		return NewPoint(s.EndPoint.X-s.StartPoint.X, s.EndPoint.Y-s.StartPoint.Y)
+	case CurveSegment:
+		d := bezier.Derivative(bCC(s), t)
+		if math.Hypot(d.X, d.Y) > 1e-9 {
+			return NewPoint(d.X, d.Y)
+		}
+	}
+	// estimate from nearby points
+	p1 := segmentPoint(seg, math.Max(0, t-1e-4))
+	p2 := segmentPoint(seg, math.Min(1, t+1e-4))
+	return NewPoint(p2.X-p1.X, p2.Y-p1.Y)
+}
+
+func unitVector(v Point) Point {
+	l := math.Hypot(v.X, v.Y)
+	if l == 0 {
+		return v
+	}
+	return NewPoint(v.X/l, v.Y/l)
+}
+
+// a curve from start to end, leaving start in direction u1 and arriving
+// at end in direction u2.  This approximates a circular arc when the
+// distances from each point to where the tangents meet are equal.
+func tangentCurve(start, u1, end, u2 Point) Segment {
+	angle := math.Abs(math.Atan2(u1.X*u2.Y-u1.Y*u2.X, u1.X*u2.X+u1.Y*u2.Y))
+	chord := Distance(start, end)
+	if chord == 0 {
+		return nil
+	}
+	if angle < 1e-6 {
+		return LineSegment{StartPoint: start, EndPoint: end}
+	}
+	radius := chord / (2 * math.Sin(angle/2))
+	k := 4.0 / 3.0 * math.Tan(angle/4) * radius
+	return CurveSegment{
+		StartPoint:        start,
+		ControlPointStart: NewPoint(start.X+u1.X*k, start.Y+u1.Y*k),
+		ControlPointEnd:   NewPoint(end.X-u2.X*k, end.Y-u2.Y*k),
+		EndPoint:          end,
+	}
+}
+
+// the section of the segment between the params
+func subSegment(seg Segment, start, end float64) Segment {
+	if start == 0 && end == 1 {
+		return seg
+	}
+	switch s := seg.(type) {
+	case CurveSegment:
+		c := bCC(s)
+		if end < 1 {
+			c, _ = bezier.SplitCurve(c, end)
+		}
+		if start > 0 {
+			_, c = bezier.SplitCurve(c, start/end)
+		}
+		return bcC(c).(CurveSegment)
+	case ArcSegment:
+		s.StartPoint = segmentPoint(seg, start)
+		s.EndPoint = segmentPoint(seg, end)
+		s.StartAngle = s.StartAngle + s.Sweep*start
+		s.Sweep = s.Sweep * (end - start)
+		return s
+	}
+	return LineSegment{StartPoint: segmentPoint(seg, start), EndPoint: segmentPoint(seg, end)}
+}
@@ -0,0 +1,172 @@
+package path
+
+import (
+	"math"
+	"testing"
+)
+
+func countSegments(p Path) (lines, curves, arcs int) {
+	for _, seg := range p.Segments() {
+		switch seg.(type) {
+		case LineSegment:
+			lines++
+		case CurveSegment:
+			curves++
+		case ArcSegment:
+			arcs++
+		}
+	}
+	return lines, curves, arcs
+}
+
+func TestFilletSquare(t *testing.T) {
+	so := NewSegmentOperators()
+	square := rectPath(0, 0, 10, 10)
+
+	p, err := FilletCorners(square, CornerOptions{Radius: 1}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	lines, _, arcs := countSegments(p)
+	if lines != 4 || arcs != 4 {
+		t.Errorf("Expected 4 lines and 4 arcs, got %d and %d: %s", lines, arcs, SvgString(p, 3))
+	}
+	expected := 32 + 2*math.Pi
+	if l := PathLength(p); math.Abs(l-expected) > .001 {
+		t.Errorf("Expected length %f, got %f: %s", expected, l, SvgString(p, 3))
+	}
+	// the arcs bulge out toward the corners, but stay inside the square
+	tl, br, err := BoundingBoxTrimWhitespace(p, so)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if !tl.EqualsPrecision(NewPoint(0, 0), 3) || !br.EqualsPrecision(NewPoint(10, 10), 3) {
+		t.Errorf("Expected bounding box 0,0 to 10,10, got %s %s", tl.StringRounded(), br.StringRounded())
+	}
+
+	p, err = FilletCorners(square, CornerOptions{Radius: 1, Chamfer: true}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	expected = 32 + 4*math.Sqrt2
+	if l := PathLength(p); math.Abs(l-expected) > .001 {
+		t.Errorf("Expected chamfer length %f, got %f: %s", expected, l, SvgString(p, 3))
+	}
+
+	// the radius is reduced to fit
+	p, err = FilletCorners(square, CornerOptions{Radius: 20}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	expected = 10 * math.Pi
+	if l := PathLength(p); math.Abs(l-expected) > .001 {
+		t.Errorf("Expected a circle of length %f, got %f: %s", expected, l, SvgString(p, 3))
+	}
+}
+
+func TestFilletCornerSelection(t *testing.T) {
+	// an L shape, one of the corners is concave
+	d := NewDraw()
+	d.MoveTo(NewPoint(0, 0))
+	d.LineTo(NewPoint(10, 0))
+	d.LineTo(NewPoint(10, 5))
+	d.LineTo(NewPoint(5, 5))
+	d.LineTo(NewPoint(5, 10))
+	d.LineTo(NewPoint(0, 10))
+	d.LineTo(NewPoint(0, 0))
+	l := d.Path()
+
+	tests := []struct {
+		options CornerOptions
+		arcs    int
+	}{
+		{CornerOptions{Radius: 1}, 6},
+		{CornerOptions{Radius: 1, Corners: ConvexCorners}, 5},
+		{CornerOptions{Radius: 1, Corners: ConcaveCorners}, 1},
+		{CornerOptions{Radius: 1, MinAngle: 100}, 0},
+		{CornerOptions{Radius: 1, MaxAngle: 45}, 0},
+	}
+	for _, test := range tests {
+		p, err := FilletCorners(l, test.options, 3)
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		if _, _, arcs := countSegments(p); arcs != test.arcs {
+			t.Errorf("Expected %d arcs for %+v, got %d: %s", test.arcs, test.options, arcs, SvgString(p, 3))
+		}
+	}
+
+	// the concave corner at 5,5 bulges away from the inside of the L
+	p, _ := FilletCorners(l, CornerOptions{Radius: 1, Corners: ConcaveCorners}, 3)
+	for _, seg := range p.Segments() {
+		if a, ok := seg.(ArcSegment); ok {
+			if !a.Center.EqualsPrecision(NewPoint(6, 6), 3) {
+				t.Errorf("Expected the arc centered at 6,6, got %s", a.Center.StringRounded())
+			}
+		}
+	}
+}
+
+func TestFilletCurves(t *testing.T) {
+	// a D shape, a line closed by a curve
+	d := NewDraw()
+	d.MoveTo(NewPoint(0, 0))
+	d.LineTo(NewPoint(0, 10))
+	d.CurveTo(NewPoint(8, 10), NewPoint(8, 0), NewPoint(0, 0))
+
+	p, err := FilletCorners(d.Path(), CornerOptions{Radius: 1}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	lines, curves, _ := countSegments(p)
+	if lines != 1 || curves != 3 {
+		t.Errorf("Expected 1 line and 3 curves, got %d and %d: %s", lines, curves, SvgString(p, 3))
+	}
+	// every join is smooth
+	segs := TrimMove(p.Segments())
+	for i, seg := range segs {
+		next := segs[(i+1)%len(segs)]
+		if !seg.End().EqualsPrecision(next.Start(), 3) {
+			t.Errorf("Expected segment %d to end where the next starts", i)
+		}
+		u1 := unitVector(segmentTangent(seg, 1))
+		u2 := unitVector(segmentTangent(next, 0))
+		if math.Abs(u1.X*u2.Y-u1.Y*u2.X) > .001 || u1.X*u2.X+u1.Y*u2.Y < 0 {
+			t.Errorf("Expected a smooth join after segment %d: %s", i, SvgString(p, 3))
+		}
+	}
+}
+
+func TestFilletLargeArc(t *testing.T) {
+	// a 300 degree arc into a line, the far side of the arc is never
+	// more than the diameter from the corner
+	arc := NewArcSegment(NewPoint(0, 0), 1, 1, 0, 0, 5*math.Pi/3)
+	d := NewDraw()
+	d.MoveTo(arc.Start())
+	d.AddSegment(arc)
+	d.LineTo(NewPoint(arc.End().X, arc.End().Y-20))
+
+	p, err := FilletCorners(d.Path(), CornerOptions{Radius: 2}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	segs := TrimMove(p.Segments())
+	if len(segs) != 3 {
+		t.Fatalf("Expected an arc, fillet and line, got %s", SvgString(p, 3))
+	}
+	// the fillet is limited to half the arc
+	expected := SegmentLength(arc) / 2
+	if _, ok := segs[0].(ArcSegment); !ok {
+		t.Errorf("Expected the arc to be kept: %s", SvgString(p, 3))
+	} else if l := SegmentLength(segs[0]); math.Abs(l-expected) > .001 {
+		t.Errorf("Expected the arc trimmed to length %f, got %f: %s", expected, l, SvgString(p, 3))
+	}
+	if l := SegmentLength(segs[2]); math.Abs(l-(20-expected)) > .001 {
+		t.Errorf("Expected the line trimmed to length %f, got %f: %s", 20-expected, l, SvgString(p, 3))
+	}
+	for i := 0; i < 2; i++ {
+		if !segs[i].End().EqualsPrecision(segs[i+1].Start(), 3) {
+			t.Errorf("Expected segment %d to end where the next starts: %s", i, SvgString(p, 3))
+		}
+	}
+}
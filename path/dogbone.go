@@ -0,0 +1,211 @@
+package path
+
+import (
+	"math"
+)
+
+// Dogbone relief for inside corners.
+// A round cutter can not reach into an inside corner of the material, it
+// leaves a radius the size of the tool.  A dogbone cuts a little further so
+// the tool can reach the corner, the path follows a circle the size of the
+// tool that passes through the corner.  The circle is centered on the
+// bisector of the corner, or for a T-bone it is moved horizontally or
+// vertically so the overcut runs along only one of the edges.
+// A relief that runs past the end of a line, or into the relief at the
+// other end of it, is left out.
+// Only corners between two lines on closed contours are changed.  Inside
+// corners of holes are found as well, a contour inside an odd number of
+// other contours is a hole.
+
+type DogboneStyle int
+
+const (
+	DogboneBisector DogboneStyle = iota
+	DogboneHorizontal
+	DogboneVertical
+)
+
+// Adds dogbones to the inside corners of the closed contours in the path,
+// for a tool of the given diameter.
+func Dogbone(p Path, style DogboneStyle, diameter float64, precision int) (Path, error) {
+	if diameter <= 0 {
+		return p, nil
+	}
+	tolerance := math.Pow(10, -float64(precision))
+	sections := [][]Segment{}
+	for _, section := range SplitPathOnMove(p) {
+		segs := []Segment{}
+		for _, seg := range section.Segments() {
+			if IsMove(seg) || isZeroLength(seg, tolerance) {
+				continue
+			}
+			segs = append(segs, seg)
+		}
+		if len(segs) > 0 {
+			sections = append(sections, segs)
+		}
+	}
+
+	// only closed contours are used to find holes
+	closed := make([]bool, len(sections))
+	polygons := make([][]Point, len(sections))
+	for i, segs := range sections {
+		closed[i] = Distance(segs[0].Start(), Tail(segs).End()) <= tolerance
+		for _, polyline := range FlattenPath(NewPathFromSegments(segs), tolerance) {
+			polygons[i] = append(polygons[i], polyline...)
+		}
+	}
+
+	d := NewDraw()
+	for i, segs := range sections {
+		if closed[i] {
+			others := [][]Point{}
+			for j, polygon := range polygons {
+				if i != j && closed[j] {
+					others = append(others, polygon)
+				}
+			}
+			hole := pointInPolygons(segmentMidpoint(segs[0]), others)
+			segs = dogboneSection(segs, style, diameter/2, PolygonArea(polygons[i]), hole, tolerance)
+		}
+		d.MoveTo(segs[0].Start())
+		d.AddSegments(segs)
+	}
+	return d.Path(), nil
+}
+
+// the arc cutting into a corner, and how far it goes back along the line
+// before and after the corner
+type dogboneRelief struct {
+	arc   Segment
+	back1 float64
+	back2 float64
+}
+
+func dogboneSection(segs []Segment, style DogboneStyle, radius, winding float64, hole bool, tolerance float64) []Segment {
+	n := len(segs)
+	// the relief after each segment, if any
+	after := make([]*dogboneRelief, n)
+	for k := range segs {
+		next := (k + 1) % n
+		after[k] = dogboneCorner(segs[k], segs[next], style, radius, winding, hole, tolerance)
+	}
+	// a relief has to fit on the line beside the relief at the other end, if
+	// both do not fit each gets half the line.  Dropping one can make room
+	// for another, so keep going until nothing changes
+	fits := func(back, length float64, other *dogboneRelief, otherBack float64) bool {
+		if other == nil {
+			return back <= length+tolerance
+		}
+		return back+otherBack <= length+tolerance || back <= length/2
+	}
+	for changed := true; changed; {
+		changed = false
+		for k, r := range after {
+			if r == nil {
+				continue
+			}
+			next := (k + 1) % n
+			before := after[(k+n-1)%n]
+			beforeBack := 0.0
+			if before != nil {
+				beforeBack = before.back2
+			}
+			afterNext := after[next]
+			afterNextBack := 0.0
+			if afterNext != nil {
+				afterNextBack = afterNext.back1
+			}
+			if !fits(r.back1, SegmentLength(segs[k]), before, beforeBack) || !fits(r.back2, SegmentLength(segs[next]), afterNext, afterNextBack) {
+				after[k] = nil
+				changed = true
+			}
+		}
+	}
+
+	ret := []Segment{}
+	for i, seg := range segs {
+		before := after[(i+n-1)%n]
+		if before != nil {
+			seg, _ = SetSegmentStart(seg, before.arc.End())
+		}
+		if after[i] != nil {
+			seg = setSegmentEnd(seg, after[i].arc.Start())
+		}
+		if !isZeroLength(seg, tolerance) {
+			ret = append(ret, seg)
+		}
+		if after[i] != nil {
+			ret = append(ret, after[i].arc)
+		}
+	}
+	return ret
+}
+
+// the relief cutting into the corner between the two segments, or nil if
+// this is not an inside corner
+func dogboneCorner(seg, next Segment, style DogboneStyle, radius, winding float64, hole bool, tolerance float64) *dogboneRelief {
+	l1, ok1 := seg.(LineSegment)
+	l2, ok2 := next.(LineSegment)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	corner := l1.EndPoint
+	if Distance(corner, l2.StartPoint) > tolerance {
+		return nil
+	}
+	u1 := unitVector(segmentTangent(l1, 1))
+	u2 := unitVector(segmentTangent(l2, 0))
+	turn := u1.X*u2.Y - u1.Y*u2.X
+	if math.Abs(turn) < 1e-6 {
+		return nil
+	}
+	// the material is outside of a hole
+	inside := (turn*winding < 0) != hole
+	if !inside {
+		return nil
+	}
+
+	// the direction away from the material, along the bisector
+	away := unitVector(NewPoint(u2.X-u1.X, u2.Y-u1.Y))
+	dir := away
+	switch style {
+	case DogboneHorizontal:
+		if math.Abs(away.X) > 1e-6 {
+			dir = NewPoint(math.Copysign(1, away.X), 0)
+		}
+	case DogboneVertical:
+		if math.Abs(away.Y) > 1e-6 {
+			dir = NewPoint(0, math.Copysign(1, away.Y))
+		}
+	}
+	center := NewPoint(corner.X+dir.X*radius, corner.Y+dir.Y*radius)
+
+	// where the circle crosses each line, going back from the corner
+	back1 := 2 * radius * (dir.X*-u1.X + dir.Y*-u1.Y)
+	back2 := 2 * radius * (dir.X*u2.X + dir.Y*u2.Y)
+	start := corner
+	if back1 > tolerance {
+		start = NewPoint(corner.X-u1.X*back1, corner.Y-u1.Y*back1)
+	}
+	end := corner
+	if back2 > tolerance {
+		end = NewPoint(corner.X+u2.X*back2, corner.Y+u2.Y*back2)
+	}
+
+	// go around the side of the circle in the material
+	startAngle := math.Atan2(start.Y-center.Y, start.X-center.X)
+	endAngle := math.Atan2(end.Y-center.Y, end.X-center.X)
+	sweep := math.Mod(endAngle-startAngle+4*math.Pi, 2*math.Pi)
+	if sweep < 1e-9 {
+		sweep = 2 * math.Pi
+	}
+	mid := startAngle + sweep/2
+	if math.Cos(mid)*away.X+math.Sin(mid)*away.Y > 0 {
+		sweep -= 2 * math.Pi
+	}
+	arc := NewArcSegment(center, radius, radius, 0, startAngle, sweep)
+	arc.StartPoint = start
+	arc.EndPoint = end
+	return &dogboneRelief{arc: arc, back1: back1, back2: back2}
+}
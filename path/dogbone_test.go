@@ -0,0 +1,101 @@
+package path
+
+import (
+	"math"
+	"testing"
+)
+
+func lShapePath() Path {
+	d := NewDraw()
+	d.MoveTo(NewPoint(0, 0))
+	d.LineTo(NewPoint(10, 0))
+	d.LineTo(NewPoint(10, 5))
+	d.LineTo(NewPoint(5, 5))
+	d.LineTo(NewPoint(5, 10))
+	d.LineTo(NewPoint(0, 10))
+	d.LineTo(NewPoint(0, 0))
+	return d.Path()
+}
+
+func TestDogboneStyles(t *testing.T) {
+	tests := []struct {
+		style  DogboneStyle
+		center Point
+		length float64
+	}{
+		{DogboneBisector, NewPoint(5+.5/math.Sqrt2, 5+.5/math.Sqrt2), 40 - math.Sqrt2 + math.Pi/2},
+		{DogboneHorizontal, NewPoint(5.5, 5), 40 - 1 + math.Pi/2},
+		{DogboneVertical, NewPoint(5, 5.5), 40 - 1 + math.Pi/2},
+	}
+	for _, test := range tests {
+		p, err := Dogbone(lShapePath(), test.style, 1, 3)
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		_, _, arcs := countSegments(p)
+		if arcs != 1 {
+			t.Errorf("Expected 1 arc for style %d, got %d: %s", test.style, arcs, SvgString(p, 3))
+		}
+		for _, seg := range p.Segments() {
+			a, ok := seg.(ArcSegment)
+			if !ok {
+				continue
+			}
+			if !a.Center.EqualsPrecision(test.center, 3) {
+				t.Errorf("Expected style %d centered at %s, got %s", test.style, test.center.StringRounded(), a.Center.StringRounded())
+			}
+			// the arc reaches the corner
+			if !a.containsPoint(NewPoint(5, 5), 3) {
+				t.Errorf("Expected style %d to pass through the corner: %s", test.style, SvgString(p, 3))
+			}
+		}
+		if l := PathLength(p); math.Abs(l-test.length) > .001 {
+			t.Errorf("Expected style %d length %f, got %f: %s", test.style, test.length, l, SvgString(p, 3))
+		}
+		// the overcut is into the material, not outside the part
+		tl, br, err := BoundingBoxTrimWhitespace(p, NewSegmentOperators())
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		if !tl.EqualsPrecision(NewPoint(0, 0), 3) || !br.EqualsPrecision(NewPoint(10, 10), 3) {
+			t.Errorf("Expected bounding box 0,0 to 10,10, got %s %s", tl.StringRounded(), br.StringRounded())
+		}
+	}
+}
+
+func TestDogboneHole(t *testing.T) {
+	d := NewDraw()
+	d.MoveTo(NewPoint(0, 0))
+	d.Rect(20, 20)
+	d.MoveTo(NewPoint(5, 5))
+	d.Rect(10, 10)
+
+	p, err := Dogbone(d.Path(), DogboneBisector, 1, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	_, _, arcs := countSegments(p)
+	if arcs != 4 {
+		t.Errorf("Expected a dogbone in each corner of the hole, got %d: %s", arcs, SvgString(p, 3))
+	}
+	for _, seg := range p.Segments() {
+		if a, ok := seg.(ArcSegment); ok {
+			if a.Center.X < 5 || a.Center.X > 15 || a.Center.Y < 5 || a.Center.Y > 15 {
+				t.Errorf("Expected the dogbone centered in the hole, got %s", a.Center.StringRounded())
+			}
+		}
+	}
+
+	// open paths are left alone
+	open := NewDraw()
+	open.MoveTo(NewPoint(0, 0))
+	open.LineTo(NewPoint(10, 0))
+	open.LineTo(NewPoint(10, 10))
+	p, err = Dogbone(open.Path(), DogboneBisector, 1, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if _, _, arcs := countSegments(p); arcs != 0 {
+		t.Errorf("Expected no dogbones on an open path, got %d", arcs)
+	}
+}
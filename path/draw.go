@@ -165,6 +165,20 @@ func (d *Draw) CurveTo(controlPointStart, controlPointEnd, point Point) {
 	d.path.AddSegments(seg)
 }
 
+// draws a relative elliptical arc, see ArcTo
+func (d *Draw) RelArcTo(rx, ry, rotation float64, largeArc, sweep bool, dxdy Point) {
+	d.ArcTo(rx, ry, rotation, largeArc, sweep, d.ToAbsPosition(dxdy))
+}
+
+// draws an elliptical arc, with the same parameters as the svg arc command.
+// rotation is in degrees
+func (d *Draw) ArcTo(rx, ry, rotation float64, largeArc, sweep bool, point Point) {
+	seg := NewArcSegmentFromSvg(d.CurrentPosition(), rx, ry, rotation, largeArc, sweep, point)
+	if seg != nil {
+		d.path.AddSegments(seg)
+	}
+}
+
 // Relative line to
 func (d *Draw) RelLineTo(dxdy Point) {
 	point := d.ToAbsPosition(dxdy)
@@ -240,6 +254,16 @@ func (d *Draw) Circle(r float64) {
 	d.RelCurveTo(NewPoint(0, -ctrl), NewPoint(r-ctrl, -r), NewPoint(r, -r))   // top left
 }
 
+// Draws an ellipse at the current location, as a single exact arc.
+// origin is top left, like Circle
+func (d *Draw) Ellipse(rx, ry float64) {
+	current := d.CurrentPosition()
+	center := NewPoint(current.X+rx, current.Y+ry)
+	arc := NewArcSegment(center, rx, ry, 0, -math.Pi/2, 2*math.Pi)
+	d.MoveTo(arc.StartPoint)
+	d.path.AddSegments(arc)
+}
+
 // draws a rectangle from the current location, ending in the current location
 func (d *Draw) Rect(w, h float64) {
 	d.RelLineTo(NewPoint(w, 0))
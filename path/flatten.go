@@ -0,0 +1,129 @@
+package path
+
+import (
+	"math"
+
+	"github.com/dustismo/heavyfishdesign/bezier"
+)
+
+// max number of times we will subdivide a curve when flattening
+const flattenMaxDepth = 16
+
+// Converts the curve into a list of points, such that the polyline
+// through those points is never farther than tolerance from the
+// actual curve.  The start and end points of the curve are included.
+func FlattenCurve(curve Curve, tolerance float64) []Point {
+	c := bCC(curve)
+	points := []Point{bpP(c.Start)}
+	return flattenCurve(c, tolerance, 0, points)
+}
+
+func flattenCurve(c bezier.CubicCurve, tolerance float64, depth int, collect []Point) []Point {
+	if depth >= flattenMaxDepth || curveFlatness(c) <= tolerance {
+		return append(collect, bpP(c.End))
+	}
+	left, right := bezier.SplitCurve(c, .5)
+	collect = flattenCurve(left, tolerance, depth+1, collect)
+	return flattenCurve(right, tolerance, depth+1, collect)
+}
+
+// the max distance of the control points from the line between
+// the start and end of the curve.
+func curveFlatness(c bezier.CubicCurve) float64 {
+	return math.Max(
+		pointLineDistance(bpP(c.StartControl), bpP(c.Start), bpP(c.End)),
+		pointLineDistance(bpP(c.EndControl), bpP(c.Start), bpP(c.End)),
+	)
+}
+
+// the distance from point p to the line that passes through a and b.
+// if a and b are the same point, then this is the distance from p to a
+func pointLineDistance(p, a, b Point) float64 {
+	l := Distance(a, b)
+	if l == 0 {
+		return Distance(p, a)
+	}
+	return math.Abs((b.X-a.X)*(a.Y-p.Y)-(a.X-p.X)*(b.Y-a.Y)) / l
+}
+
+// Converts the path into a list of polylines, one for each
+// continuous (move separated) section of the path.  Curves and arcs
+// are flattened to within the given tolerance.
+func FlattenPath(p Path, tolerance float64) [][]Point {
+	polylines := [][]Point{}
+	for _, pth := range SplitPathOnMove(p) {
+		points := []Point{}
+		for _, seg := range pth.Segments() {
+			if IsMove(seg) {
+				continue
+			}
+			if len(points) == 0 {
+				points = append(points, seg.Start())
+			}
+			switch s := seg.(type) {
+			case CurveSegment:
+				points = append(points, FlattenCurve(s, tolerance)[1:]...)
+			case ArcSegment:
+				for _, c := range s.ToCurves() {
+					points = append(points, FlattenCurve(c, tolerance)[1:]...)
+				}
+			default:
+				points = append(points, seg.End())
+			}
+		}
+		if len(points) > 1 {
+			polylines = append(polylines, points)
+		}
+	}
+	return polylines
+}
+
+// The signed area of the polygon, positive if the points are clockwise
+// (in y down coordinates)
+func PolygonArea(pts []Point) float64 {
+	area := 0.0
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		area += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return area / 2
+}
+
+// Checks where the point is relative to the polygon: 1 if inside, 0 if
+// on the edge and -1 if outside.
+func PointInPolygon(p Point, pts []Point) int {
+	for i := range pts {
+		if pointSegmentDistance(p, pts[i], pts[(i+1)%len(pts)]) < 1e-7 {
+			return 0
+		}
+	}
+	if crossesOdd(p, pts) {
+		return 1
+	}
+	return -1
+}
+
+// true if a ray from p crosses the polygon edges an odd number of times
+func crossesOdd(p Point, pts []Point) bool {
+	inside := false
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		if (a.Y > p.Y) != (b.Y > p.Y) &&
+			p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// the distance from point p to the line segment a b
+func pointSegmentDistance(p, a, b Point) float64 {
+	dx := b.X - a.X
+	dy := b.Y - a.Y
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return Distance(p, a)
+	}
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l2))
+	return Distance(p, NewPoint(a.X+t*dx, a.Y+t*dy))
+}
@@ -0,0 +1,61 @@
+package path
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFlattenCurve(t *testing.T) {
+	// a quarter circle of radius 10 centered at 0,0
+	ctrl := (4 * (math.Sqrt(2) - 1) / 3) * 10
+	curve := CurveSegment{
+		StartPoint:        NewPoint(10, 0),
+		ControlPointStart: NewPoint(10, ctrl),
+		ControlPointEnd:   NewPoint(ctrl, 10),
+		EndPoint:          NewPoint(0, 10),
+	}
+	tolerance := .01
+	points := FlattenCurve(curve, tolerance)
+	if len(points) < 3 {
+		t.Errorf("Expected the curve to be subdivided, got %d points", len(points))
+	}
+	if !points[0].Equals(curve.Start()) || !points[len(points)-1].Equals(curve.End()) {
+		t.Errorf("Expected flattened curve to begin and end on the curve endpoints: %+v", points)
+	}
+
+	// the midpoint of every chord should be close to the circle
+	for i := 1; i < len(points); i++ {
+		mid := NewPoint((points[i-1].X+points[i].X)/2, (points[i-1].Y+points[i].Y)/2)
+		d := 10 - Distance(NewPoint(0, 0), mid)
+		if d > tolerance {
+			t.Errorf("Chord midpoint %s is %.5f from the curve", mid.StringPrecision(3), d)
+		}
+	}
+}
+
+func TestFlattenStraightCurve(t *testing.T) {
+	curve := CurveSegment{
+		StartPoint:        NewPoint(0, 0),
+		ControlPointStart: NewPoint(1, 1),
+		ControlPointEnd:   NewPoint(2, 2),
+		EndPoint:          NewPoint(3, 3),
+	}
+	points := FlattenCurve(curve, .01)
+	if len(points) != 2 {
+		t.Errorf("Expected 2 points, got %+v", points)
+	}
+}
+
+func TestFlattenPath(t *testing.T) {
+	p, err := ParsePathFromSvg("M 0 0 L 1 0 L 1 1 M 2 2 L 3 3")
+	if err != nil {
+		t.Errorf("Error %s", err)
+	}
+	polylines := FlattenPath(p, .01)
+	if len(polylines) != 2 {
+		t.Errorf("Expected 2 polylines, got %d", len(polylines))
+	}
+	if len(polylines[0]) != 3 || len(polylines[1]) != 2 {
+		t.Errorf("Unexpected polylines %+v", polylines)
+	}
+}
@@ -108,6 +108,9 @@ func SetSegmentStart(segment Segment, start Point) (Segment, error) {
 	case CurveSegment:
 		s.StartPoint = start
 		return s, nil
+	case ArcSegment:
+		s.StartPoint = start
+		return s, nil
 	}
 	return segment, fmt.Errorf("Unable to set segment start %+v", segment)
 }
@@ -31,6 +31,8 @@ const (
 	relClosePath          SvgCommand = 'z' // for parsing only, always use closePath
 	qCurveCommand         SvgCommand = 'Q'
 	relQCurveCommand      SvgCommand = 'q'
+	arcCommand            SvgCommand = 'A'
+	relArcCommand         SvgCommand = 'a'
 )
 
 // parses a path from the SVG style string
@@ -155,6 +157,18 @@ func next(index int, items []string, d *Draw) (int, error) {
 			NewPoint(vals[2], vals[3]), // point
 		)
 		return index + 5, nil
+	case arcCommand, relArcCommand:
+		// A rx ry x-axis-rotation large-arc-flag sweep-flag x y
+		vals, err := getNextFloats(items, index+1, index+2, index+3, index+4, index+5, index+6, index+7)
+		if err != nil {
+			return index + 8, err
+		}
+		point := NewPoint(vals[5], vals[6])
+		if op == relArcCommand {
+			point = d.ToAbsPosition(point)
+		}
+		d.ArcTo(vals[0], vals[1], vals[2], vals[3] != 0, vals[4] != 0, point)
+		return index + 8, nil
 	case ClosePath:
 		// do nothing. maybe figure out something later?
 		fmt.Printf("Warning, skipping Z close path in svg parsing\n")
@@ -49,3 +49,19 @@ func TestPathParserDecimals(t *testing.T) {
 		t.Errorf("Expected: %s\nActual: %s", expectedStr, actualStr)
 	}
 }
+
+func TestPathParserArc(t *testing.T) {
+	path := "M 0 0 A 10 10 0 0 1 20 0 a 10 10 0 1 0 -10 0"
+	p, err := ParsePathFromSvg(path)
+
+	if err != nil {
+		t.Errorf("Error parsing path %s", err)
+	}
+
+	expectedStr := "M 0.000 0.000 A 10.000 10.000 0.000 0 1 20.000 0.000 A 10.000 10.000 0.000 1 0 10.000 0.000"
+	actualStr := SvgString(p, 3)
+
+	if expectedStr != actualStr {
+		t.Errorf("Expected: %s\nActual: %s", expectedStr, actualStr)
+	}
+}
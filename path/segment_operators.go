@@ -2,6 +2,7 @@ package path
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/dustismo/heavyfishdesign/bezier"
 )
@@ -19,8 +20,13 @@ type SegmentOperators interface {
 	// Joins the two disjoint segments into on continuous path
 	// possibly returns multiple segments
 	Join(segment1, segment2 Segment) ([]Segment, error)
-	// transforms all the points associated with the segment
+	// transforms all the points associated with the segment.  Returns an
+	// error for an arc the transform changes the shape of, since that can
+	// not be a single segment.  See TransformSegment
 	TransformPoints(segment Segment, pt PointTransform) (Segment, error)
+	// transforms the segment.  Arcs are converted to curves unless the
+	// transform keeps their shape, so this can return multiple segments
+	TransformSegment(segment Segment, pt PointTransform) ([]Segment, error)
 }
 
 type PointTransform func(p Point) Point
@@ -68,30 +74,106 @@ func NewBezierCurveOperators() BezierCurveOperators {
 }
 
 func (do DefaultSegmentOperators) TransformPoints(s Segment, pt PointTransform) (Segment, error) {
+	if a, ok := s.(ArcSegment); ok {
+		arc, ok := transformArc(a, pt)
+		if !ok {
+			return nil, fmt.Errorf("Error unable to transform arc %+v into a single segment, the transform changes its shape", s)
+		}
+		return arc, nil
+	}
+	segs, err := do.TransformSegment(s, pt)
+	if err != nil {
+		return nil, err
+	}
+	return segs[0], nil
+}
+
+func (do DefaultSegmentOperators) TransformSegment(s Segment, pt PointTransform) ([]Segment, error) {
 	switch seg := s.(type) {
 	case MoveSegment:
-		return MoveSegment{
+		return []Segment{MoveSegment{
 			StartPoint: pt(seg.Start()),
 			EndPoint:   pt(seg.End()),
-		}, nil
+		}}, nil
 
 	case LineSegment:
-		return LineSegment{
+		return []Segment{LineSegment{
 			StartPoint: pt(seg.Start()),
 			EndPoint:   pt(seg.End()),
-		}, nil
+		}}, nil
 
 	case CurveSegment:
-		return CurveSegment{
+		return []Segment{CurveSegment{
 			StartPoint:        pt(seg.Start()),
 			EndPoint:          pt(seg.End()),
 			ControlPointStart: pt(seg.ControlPointStart),
 			ControlPointEnd:   pt(seg.ControlPointEnd),
-		}, nil
+		}}, nil
+	case ArcSegment:
+		if arc, ok := transformArc(seg, pt); ok {
+			return []Segment{arc}, nil
+		}
+		// the transform changes the shape of the arc, so use curves
+		ret := []Segment{}
+		for _, c := range seg.ToCurves() {
+			ret = append(ret, CurveSegment{
+				StartPoint:        pt(c.Start()),
+				EndPoint:          pt(c.End()),
+				ControlPointStart: pt(c.ControlPointStart),
+				ControlPointEnd:   pt(c.ControlPointEnd),
+			})
+		}
+		return ret, nil
 	}
 	return nil, fmt.Errorf("Error unable to transform segment %+v", s)
 }
 
+// transforms the arc, if the transform is a similarity (only moves, rotates,
+// mirrors and uniformly scales).  ok is false for any other transform.
+func transformArc(a ArcSegment, pt PointTransform) (ArcSegment, bool) {
+	r := math.Max(a.RadiusX, a.RadiusY)
+	center := pt(a.Center)
+	// the images of the unit x and y axes
+	xAxis := pt(NewPoint(a.Center.X+r, a.Center.Y))
+	yAxis := pt(NewPoint(a.Center.X, a.Center.Y+r))
+	ax, ay := (xAxis.X-center.X)/r, (xAxis.Y-center.Y)/r
+	bx, by := (yAxis.X-center.X)/r, (yAxis.Y-center.Y)/r
+	scale := math.Hypot(ax, ay)
+	tolerance := 1e-9 * math.Max(1, scale)
+	if scale == 0 ||
+		math.Abs(ax*bx+ay*by) > tolerance ||
+		math.Abs(scale-math.Hypot(bx, by)) > tolerance {
+		return a, false
+	}
+	// make sure the transform is linear, by checking points on the arc
+	for _, angle := range []float64{a.StartAngle, a.StartAngle + a.Sweep/2, a.EndAngle()} {
+		p := a.PointAtAngle(angle)
+		dx, dy := p.X-a.Center.X, p.Y-a.Center.Y
+		expected := NewPoint(center.X+ax*dx+bx*dy, center.Y+ay*dx+by*dy)
+		if Distance(expected, pt(p)) > 1e-9*math.Max(1, r*scale) {
+			return a, false
+		}
+	}
+	angle := math.Atan2(ay, ax)
+	ret := ArcSegment{
+		StartPoint: pt(a.StartPoint),
+		EndPoint:   pt(a.EndPoint),
+		Center:     center,
+		RadiusX:    a.RadiusX * scale,
+		RadiusY:    a.RadiusY * scale,
+		Rotation:   a.Rotation + angle,
+		StartAngle: a.StartAngle,
+		Sweep:      a.Sweep,
+	}
+	if ax*by-ay*bx < 0 {
+		// mirrored, so the arc goes the other way
+		ret.Rotation = angle - a.Rotation
+		ret.StartAngle = -a.StartAngle
+		ret.Sweep = -a.Sweep
+	}
+	return ret, true
+}
+
 func (do DefaultSegmentOperators) Move(s Segment, amount Point) (Segment, error) {
 	pt := func(p Point) Point {
 		return NewPoint(p.X+amount.X, p.Y+amount.Y)
@@ -267,6 +349,127 @@ func (do DefaultSegmentOperators) JoinCurves(c1, c2 CurveSegment) ([]Segment, er
 	return []Segment{r1, r2}, nil
 }
 
+// the closest point to p
+func closestPoint(p Point, points []Point) Point {
+	closest := points[0]
+	for _, pnt := range points {
+		if Distance(p, pnt) < Distance(p, closest) {
+			closest = pnt
+		}
+	}
+	return closest
+}
+
+// joins the line and arc, by projecting the line until it meets the ellipse
+// the arc is on, then moving the start of the arc to that point
+func (do DefaultSegmentOperators) JoinLineAndArc(l LineSegment, a ArcSegment) ([]Segment, error) {
+	angles, _ := a.lineCrossings(l.StartPoint, l.EndPoint)
+	points := []Point{}
+	for _, angle := range angles {
+		points = append(points, a.PointAtAngle(angle))
+	}
+	if len(points) > 0 {
+		breakPoint := closestPoint(l.EndPoint, points)
+		if arc, ok := a.withStart(breakPoint); ok {
+			return []Segment{
+				LineSegment{
+					StartPoint: l.StartPoint,
+					EndPoint:   breakPoint,
+				},
+				arc,
+			}, nil
+		}
+	}
+	// they don't meet, so join them with a line
+	return []Segment{
+		l,
+		LineSegment{
+			StartPoint: l.EndPoint,
+			EndPoint:   a.StartPoint,
+		},
+		a,
+	}, nil
+}
+
+// joins the arc and line, by projecting the line until it meets the ellipse
+// the arc is on, then moving the end of the arc to that point
+func (do DefaultSegmentOperators) JoinArcAndLine(a ArcSegment, l LineSegment) ([]Segment, error) {
+	angles, _ := a.lineCrossings(l.StartPoint, l.EndPoint)
+	points := []Point{}
+	for _, angle := range angles {
+		points = append(points, a.PointAtAngle(angle))
+	}
+	if len(points) > 0 {
+		breakPoint := closestPoint(l.StartPoint, points)
+		if arc, ok := a.withEnd(breakPoint); ok {
+			return []Segment{
+				arc,
+				LineSegment{
+					StartPoint: breakPoint,
+					EndPoint:   l.EndPoint,
+				},
+			}, nil
+		}
+	}
+	return []Segment{
+		a,
+		LineSegment{
+			StartPoint: a.EndPoint,
+			EndPoint:   l.StartPoint,
+		},
+		l,
+	}, nil
+}
+
+// joins two circular arcs where their circles cross
+func (do DefaultSegmentOperators) JoinArcs(a1, a2 ArcSegment) ([]Segment, error) {
+	points := circleIntersections(a1.Center, a1.RadiusX, a2.Center, a2.RadiusX)
+	if len(points) > 0 {
+		gap := NewPoint((a1.EndPoint.X+a2.StartPoint.X)/2, (a1.EndPoint.Y+a2.StartPoint.Y)/2)
+		breakPoint := closestPoint(gap, points)
+		r1, ok1 := a1.withEnd(breakPoint)
+		r2, ok2 := a2.withStart(breakPoint)
+		if ok1 && ok2 {
+			return []Segment{r1, r2}, nil
+		}
+	}
+	return []Segment{
+		a1,
+		LineSegment{
+			StartPoint: a1.EndPoint,
+			EndPoint:   a2.StartPoint,
+		},
+		a2,
+	}, nil
+}
+
+// joins segments where one is an arc that cannot be joined exactly, by
+// converting the arc to curves and joining the curves next to the gap
+func (do DefaultSegmentOperators) joinArcCurves(s1, s2 Segment) ([]Segment, error) {
+	if a, ok := s1.(ArcSegment); ok {
+		curves := a.ToCurves()
+		segs, err := do.Join(curves[len(curves)-1], s2)
+		if err != nil {
+			return nil, err
+		}
+		ret := []Segment{}
+		for _, c := range curves[:len(curves)-1] {
+			ret = append(ret, c)
+		}
+		return append(ret, segs...), nil
+	}
+	a := s2.(ArcSegment)
+	curves := a.ToCurves()
+	ret, err := do.Join(s1, curves[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range curves[1:] {
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
 func (do DefaultSegmentOperators) Join(s1, s2 Segment) ([]Segment, error) {
 	if s1.End().EqualsPrecision(s2.Start(), do.Precision) {
 		ns2, err := SetSegmentStart(s2, s1.End())
@@ -283,6 +486,8 @@ func (do DefaultSegmentOperators) Join(s1, s2 Segment) ([]Segment, error) {
 			return do.JoinLines(seg1, seg2)
 		case CurveSegment:
 			return do.JoinLineAndCurve(seg1, seg2)
+		case ArcSegment:
+			return do.JoinLineAndArc(seg1, seg2)
 		}
 	case CurveSegment:
 		switch seg2 := s2.(type) {
@@ -290,6 +495,20 @@ func (do DefaultSegmentOperators) Join(s1, s2 Segment) ([]Segment, error) {
 			return do.JoinCurves(seg1, seg2)
 		case LineSegment:
 			return do.JoinCurveAndLine(seg1, seg2)
+		case ArcSegment:
+			return do.joinArcCurves(seg1, seg2)
+		}
+	case ArcSegment:
+		switch seg2 := s2.(type) {
+		case LineSegment:
+			return do.JoinArcAndLine(seg1, seg2)
+		case ArcSegment:
+			if seg1.IsCircle() && seg2.IsCircle() {
+				return do.JoinArcs(seg1, seg2)
+			}
+			return do.joinArcCurves(seg1, seg2)
+		case CurveSegment:
+			return do.joinArcCurves(seg1, seg2)
 		}
 	}
 	// simplest thing ever, draw a connecting line.  w00t
@@ -326,6 +545,11 @@ func (do DefaultSegmentOperators) Reverse(segment Segment) (Segment, error) {
 			ControlPointStart: seg.ControlPointEnd,
 			ControlPointEnd:   seg.ControlPointStart,
 		}, nil
+	case ArcSegment:
+		seg.StartPoint, seg.EndPoint = seg.EndPoint, seg.StartPoint
+		seg.StartAngle = seg.EndAngle()
+		seg.Sweep = -seg.Sweep
+		return seg, nil
 	}
 	return nil, fmt.Errorf("Error, unable to reverse segment %+v", segment)
 }
@@ -335,6 +559,8 @@ func (do DefaultSegmentOperators) BoundingBox(segment Segment) (topLeft, bottomR
 	switch seg := segment.(type) {
 	case CurveSegment:
 		return do.CurveOperators.BoundingBox(seg)
+	case ArcSegment:
+		return arcBoundingBox(seg)
 
 	default:
 		minX := seg.Start().X
@@ -365,6 +591,8 @@ func (do DefaultSegmentOperators) Intersect(s1, s2 Segment) ([]Point, error) {
 			return do.CurveOperators.IntersectLine(seg1, seg2)
 		case CurveSegment:
 			return do.CurveOperators.IntersectCurve(seg1, seg2)
+		case ArcSegment:
+			return do.intersectCurveAndArc(seg1, seg2, false)
 		case MoveSegment:
 			return []Point{}, nil
 		}
@@ -392,6 +620,19 @@ func (do DefaultSegmentOperators) Intersect(s1, s2 Segment) ([]Point, error) {
 			return []Point{intersection}, nil
 		case CurveSegment:
 			return do.CurveOperators.IntersectLine(seg2, seg1)
+		case ArcSegment:
+			return do.intersectArcAndLine(seg2, seg1), nil
+		case MoveSegment:
+			return []Point{}, nil
+		}
+	case ArcSegment:
+		switch seg2 := s2.(type) {
+		case LineSegment:
+			return do.intersectArcAndLine(seg1, seg2), nil
+		case ArcSegment:
+			return do.intersectArcs(seg1, seg2)
+		case CurveSegment:
+			return do.intersectCurveAndArc(seg2, seg1, true)
 		case MoveSegment:
 			return []Point{}, nil
 		}
@@ -401,6 +642,102 @@ func (do DefaultSegmentOperators) Intersect(s1, s2 Segment) ([]Point, error) {
 	return []Point{}, fmt.Errorf("Unable to intersect %+v and %+v", s1, s2)
 }
 
+// the points where the line crosses the arc
+func (do DefaultSegmentOperators) intersectArcAndLine(a ArcSegment, l LineSegment) []Point {
+	topL, bottomR, _ := do.BoundingBox(l)
+	angles, _ := a.lineCrossings(l.StartPoint, l.EndPoint)
+	points := []Point{}
+	for _, angle := range angles {
+		p := a.PointAtAngle(angle)
+		if PrecisionPointInBoundingBox(topL, bottomR, p, do.Precision) &&
+			a.containsPoint(p, do.Precision) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// the points where the arcs cross, exact for circular arcs, otherwise
+// the elliptical arc is converted to curves
+func (do DefaultSegmentOperators) intersectArcs(a1, a2 ArcSegment) ([]Point, error) {
+	if !a1.IsCircle() {
+		return do.intersectCurveAndArc(a2, a1, true)
+	}
+	if !a2.IsCircle() {
+		return do.intersectCurveAndArc(a1, a2, false)
+	}
+	points := []Point{}
+	for _, p := range circleIntersections(a1.Center, a1.RadiusX, a2.Center, a2.RadiusX) {
+		if a1.containsPoint(p, do.Precision) && a2.containsPoint(p, do.Precision) {
+			points = append(points, p)
+		}
+	}
+	return points, nil
+}
+
+// intersects the segment with the curves that approximate the arc.
+// points are returned in the order of the segment, the curve t values are
+// dropped if arcFirst since they belong to the segment.
+func (do DefaultSegmentOperators) intersectCurveAndArc(s Segment, a ArcSegment, arcFirst bool) ([]Point, error) {
+	points := []Point{}
+	for _, c := range a.ToCurves() {
+		pnts, err := do.Intersect(s, c)
+		if err != nil {
+			return points, err
+		}
+		for _, p := range pnts {
+			if arcFirst {
+				p = NewPoint(p.X, p.Y)
+			}
+			points = append(points, p)
+		}
+	}
+	return points, nil
+}
+
+// the points where two circles cross, tangent circles have a
+// single point.
+func circleIntersections(c1 Point, r1 float64, c2 Point, r2 float64) []Point {
+	d := Distance(c1, c2)
+	if d == 0 || d > r1+r2+1e-9 || d < math.Abs(r1-r2)-1e-9 {
+		return []Point{}
+	}
+	// distance from c1 to the line through the intersections
+	a := (r1*r1 - r2*r2 + d*d) / (2 * d)
+	h := math.Sqrt(math.Max(0, r1*r1-a*a))
+	mx := c1.X + a*(c2.X-c1.X)/d
+	my := c1.Y + a*(c2.Y-c1.Y)/d
+	if h < 1e-9 {
+		return []Point{NewPoint(mx, my)}
+	}
+	ox := h * (c2.Y - c1.Y) / d
+	oy := h * (c2.X - c1.X) / d
+	return []Point{
+		NewPoint(mx+ox, my-oy),
+		NewPoint(mx-ox, my+oy),
+	}
+}
+
+// the exact bounding box of the arc
+func arcBoundingBox(a ArcSegment) (topLeft, bottomRight Point, err error) {
+	points := []Point{a.StartPoint, a.EndPoint}
+	cosR, sinR := math.Cos(a.Rotation), math.Sin(a.Rotation)
+	// the angles where x and y are at their min and max
+	xAngle := math.Atan2(-a.RadiusY*sinR, a.RadiusX*cosR)
+	yAngle := math.Atan2(a.RadiusY*cosR, a.RadiusX*sinR)
+	for _, angle := range []float64{xAngle, xAngle + math.Pi, yAngle, yAngle + math.Pi} {
+		if a.IsFull() || math.Abs(a.sweepTo(angle)) <= math.Abs(a.Sweep) {
+			points = append(points, a.PointAtAngle(angle))
+		}
+	}
+	topLeft, bottomRight = points[0], points[0]
+	for _, p := range points[1:] {
+		topLeft = NewPoint(math.Min(topLeft.X, p.X), math.Min(topLeft.Y, p.Y))
+		bottomRight = NewPoint(math.Max(bottomRight.X, p.X), math.Max(bottomRight.Y, p.Y))
+	}
+	return topLeft, bottomRight, nil
+}
+
 func (do DefaultSegmentOperators) Split(segment Segment, point Point) (ret []Segment, err error) {
 	if point.EqualsPrecision(segment.Start(), 7) ||
 		point.EqualsPrecision(segment.End(), 7) {
@@ -445,6 +782,19 @@ func (do DefaultSegmentOperators) Split(segment Segment, point Point) (ret []Seg
 				EndPoint:   seg.End(),
 			},
 		)
+	case ArcSegment:
+		sweep := seg.sweepTo(seg.AngleOf(point))
+		if math.Abs(sweep) >= math.Abs(seg.Sweep) {
+			// the point is not on the arc
+			return []Segment{segment}, nil
+		}
+		first, second := seg, seg
+		first.EndPoint = point
+		first.Sweep = sweep
+		second.StartPoint = point
+		second.StartAngle = seg.StartAngle + sweep
+		second.Sweep = seg.Sweep - sweep
+		ret = append(ret, first, second)
 	}
 	return ret, err
 }
@@ -452,7 +802,9 @@ func (do DefaultSegmentOperators) Split(segment Segment, point Point) (ret []Seg
 func (do DefaultSegmentOperators) Offset(segment Segment, distance float64) (ret []Segment, err error) {
 	// if start and end are the same we just remove it
 	if segment.Start().EqualsPrecision(segment.End(), do.Precision) {
-		return []Segment{}, nil
+		if a, ok := segment.(ArcSegment); !ok || !a.IsFull() {
+			return []Segment{}, nil
+		}
 	}
 	switch seg := segment.(type) {
 	case CurveSegment:
@@ -490,6 +842,40 @@ func (do DefaultSegmentOperators) Offset(segment Segment, distance float64) (ret
 		ret = append(ret,
 			Parallel(seg, -distance),
 		)
+	case ArcSegment:
+		if !seg.IsCircle() {
+			// the offset of an ellipse is not an ellipse
+			for _, c := range seg.ToCurves() {
+				curves, err := do.CurveOperators.Offset(c, distance)
+				if err != nil {
+					return ret, err
+				}
+				for _, cv := range curves {
+					ret = append(ret,
+						CurveSegment{
+							StartPoint:        cv.Start(),
+							ControlPointStart: cv.ControlStart(),
+							EndPoint:          cv.End(),
+							ControlPointEnd:   cv.ControlEnd(),
+						},
+					)
+				}
+			}
+			break
+		}
+		// a positive sweep turns toward the center, so the offset
+		// is inside the circle (matching the line offset direction)
+		radius := seg.RadiusX + distance
+		if seg.Sweep > 0 {
+			radius = seg.RadiusX - distance
+		}
+		if PrecisionCompare(radius, 0, do.Precision) <= 0 {
+			// the arc collapses
+			return []Segment{}, nil
+		}
+		ret = append(ret,
+			NewArcSegment(seg.Center, radius, radius, seg.Rotation, seg.StartAngle, seg.Sweep),
+		)
 	}
 
 	// potentially need to adjust the first Move statement
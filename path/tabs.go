@@ -0,0 +1,236 @@
+package path
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Holding tabs.
+// A tab is a short gap left in the cut so the part stays attached to the
+// sheet.  Tabs are only placed on the outer closed contours, holes and
+// open paths are cut as is.  The tabs are spread evenly around the contour,
+// each is moved to the closest place on a line at least CornerClearance
+// from a corner, so tabs never land on curves or in corners.  A tab that
+// would end up next to another one is dropped.
+
+type TabOptions struct {
+	// the width of each gap
+	Width float64
+	// the number of tabs on each contour.  When 0 the count is the
+	// perimeter divided by Spacing, rounded up
+	Count   int
+	Spacing float64
+	// the closest a tab can be to a corner
+	CornerClearance float64
+}
+
+// a place along a contour, by distance from the start of the contour
+type tabRange struct {
+	segment int
+	// the distance to the start of the segment
+	offset float64
+	// the range the tab center can be in
+	min float64
+	max float64
+}
+
+// Leaves gaps for the tabs in the outer closed contours.  Returns the path
+// with the gaps, the gaps as lines to mark where the tabs are, and the
+// number of outer contours that have no line with room for a tab.  Those
+// are cut without tabs.
+func Tabs(p Path, options TabOptions, precision int) (Path, Path, int, error) {
+	if options.Width <= 0 {
+		return nil, nil, 0, fmt.Errorf("Error, tabs need a width greater than 0")
+	}
+	if options.Count <= 0 && options.Spacing <= 0 {
+		return nil, nil, 0, fmt.Errorf("Error, tabs need a count or a spacing")
+	}
+	tolerance := math.Pow(10, -float64(precision))
+	sections := [][]Segment{}
+	for _, section := range SplitPathOnMove(p) {
+		segs := []Segment{}
+		for _, seg := range section.Segments() {
+			if IsMove(seg) || isZeroLength(seg, tolerance) {
+				continue
+			}
+			segs = append(segs, seg)
+		}
+		if len(segs) > 0 {
+			sections = append(sections, segs)
+		}
+	}
+	closed := make([]bool, len(sections))
+	polygons := make([][]Point, len(sections))
+	for i, segs := range sections {
+		closed[i] = Distance(segs[0].Start(), Tail(segs).End()) <= tolerance
+		if closed[i] {
+			for _, polyline := range FlattenPath(NewPathFromSegments(segs), tolerance) {
+				polygons[i] = append(polygons[i], polyline...)
+			}
+		}
+	}
+
+	cut := NewDraw()
+	marks := NewDraw()
+	untabbed := 0
+	add := func(d *Draw, seg Segment) {
+		if len(d.Path().Segments()) == 0 || !d.CurrentPosition().Equals(seg.Start()) {
+			d.MoveTo(seg.Start())
+		}
+		d.AddSegment(seg)
+	}
+	for i, segs := range sections {
+		depth := 0
+		if closed[i] {
+			sample := segmentMidpoint(segs[0])
+			for j, polygon := range polygons {
+				if i != j && closed[j] && pointInPolygons(sample, [][]Point{polygon}) {
+					depth++
+				}
+			}
+		}
+		if !closed[i] || depth%2 == 1 {
+			for _, seg := range segs {
+				add(cut, seg)
+			}
+			continue
+		}
+		pieces, gaps := tabContour(segs, options, tolerance)
+		if len(gaps) == 0 {
+			untabbed++
+		}
+		for _, seg := range pieces {
+			add(cut, seg)
+		}
+		for _, seg := range gaps {
+			add(marks, seg)
+		}
+	}
+	return cut.Path(), marks.Path(), untabbed, nil
+}
+
+// splits the closed contour at the tabs, returns the pieces to cut
+// starting after the first tab, and the tabs.  There are no tabs if no
+// line has room for one
+func tabContour(segs []Segment, options TabOptions, tolerance float64) ([]Segment, []Segment) {
+	n := len(segs)
+	// a corner is at the start of each segment that does not continue
+	// smoothly from the one before
+	corner := make([]bool, n)
+	for i, seg := range segs {
+		prev := segs[(i+n-1)%n]
+		u1 := unitVector(segmentTangent(prev, 1))
+		u2 := unitVector(segmentTangent(seg, 0))
+		corner[i] = math.Abs(math.Atan2(u1.X*u2.Y-u1.Y*u2.X, u1.X*u2.X+u1.Y*u2.Y)) > 1e-3
+	}
+
+	perimeter := 0.0
+	ranges := []tabRange{}
+	half := options.Width / 2
+	for i, seg := range segs {
+		length := SegmentLength(seg)
+		if _, ok := seg.(LineSegment); ok {
+			r := tabRange{
+				segment: i,
+				offset:  perimeter,
+				min:     perimeter + half,
+				max:     perimeter + length - half,
+			}
+			if corner[i] {
+				r.min += options.CornerClearance
+			}
+			if corner[(i+1)%n] {
+				r.max -= options.CornerClearance
+			}
+			if r.min <= r.max {
+				ranges = append(ranges, r)
+			}
+		}
+		perimeter += length
+	}
+
+	count := options.Count
+	if count <= 0 {
+		count = int(math.Ceil(perimeter / options.Spacing))
+	}
+	// the tab centers on each segment
+	centers := map[int][]float64{}
+	placed := []float64{}
+	for t := 0; t < count && len(ranges) > 0; t++ {
+		ideal := (float64(t) + .5) * perimeter / float64(count)
+		best := 0.0
+		bestRange := ranges[0]
+		bestDistance := math.MaxFloat64
+		for _, r := range ranges {
+			c := math.Max(r.min, math.Min(r.max, ideal))
+			if d := math.Abs(c - ideal); d < bestDistance {
+				best, bestRange, bestDistance = c, r, d
+			}
+		}
+		overlaps := false
+		for _, c := range placed {
+			if math.Abs(c-best) < options.Width*2 {
+				overlaps = true
+			}
+		}
+		if overlaps {
+			continue
+		}
+		placed = append(placed, best)
+		centers[bestRange.segment] = append(centers[bestRange.segment], best-bestRange.offset)
+	}
+	if len(placed) == 0 {
+		return segs, []Segment{}
+	}
+
+	// the contour as lines and gaps, in order
+	type piece struct {
+		seg Segment
+		gap bool
+	}
+	pieces := []piece{}
+	for i, seg := range segs {
+		cs, ok := centers[i]
+		if !ok {
+			pieces = append(pieces, piece{seg: seg})
+			continue
+		}
+		sort.Float64s(cs)
+		length := SegmentLength(seg)
+		from := 0.0
+		for _, c := range cs {
+			start := segmentPoint(seg, from/length)
+			gapStart := segmentPoint(seg, (c-half)/length)
+			gapEnd := segmentPoint(seg, (c+half)/length)
+			if c-half-from > tolerance {
+				pieces = append(pieces, piece{seg: LineSegment{StartPoint: start, EndPoint: gapStart}})
+			}
+			pieces = append(pieces, piece{seg: LineSegment{StartPoint: gapStart, EndPoint: gapEnd}, gap: true})
+			from = c + half
+		}
+		if length-from > tolerance {
+			pieces = append(pieces, piece{seg: LineSegment{StartPoint: segmentPoint(seg, from/length), EndPoint: seg.End()}})
+		}
+	}
+
+	// start after the first gap, so each piece between tabs is continuous
+	first := 0
+	for i, p := range pieces {
+		if p.gap {
+			first = i
+			break
+		}
+	}
+	cut := []Segment{}
+	gaps := []Segment{}
+	for i := 1; i <= len(pieces); i++ {
+		p := pieces[(first+i)%len(pieces)]
+		if p.gap {
+			gaps = append(gaps, p.seg)
+		} else {
+			cut = append(cut, p.seg)
+		}
+	}
+	return cut, gaps
+}
@@ -0,0 +1,98 @@
+package path
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTabsSquare(t *testing.T) {
+	square := rectPath(0, 0, 10, 10)
+
+	tests := []struct {
+		options TabOptions
+		tabs    int
+	}{
+		{TabOptions{Width: .5, Count: 4, CornerClearance: 1}, 4},
+		{TabOptions{Width: .5, Spacing: 15, CornerClearance: 1}, 3},
+		{TabOptions{Width: .5, Count: 1}, 1},
+	}
+	for _, test := range tests {
+		cut, marks, untabbed, err := Tabs(square, test.options, 3)
+		if err != nil {
+			t.Fatalf("Error %s", err.Error())
+		}
+		expected := 40 - float64(test.tabs)*.5
+		if l := PathLength(cut); math.Abs(l-expected) > .001 {
+			t.Errorf("Expected cut length %f for %+v, got %f: %s", expected, test.options, l, SvgString(cut, 3))
+		}
+		if c := len(SplitPathOnMove(cut)); c != test.tabs {
+			t.Errorf("Expected %d pieces to cut for %+v, got %d: %s", test.tabs, test.options, c, SvgString(cut, 3))
+		}
+		if c := len(SplitPathOnMove(marks)); c != test.tabs {
+			t.Errorf("Expected %d tab marks for %+v, got %d: %s", test.tabs, test.options, c, SvgString(marks, 3))
+		}
+		if untabbed != 0 {
+			t.Errorf("Expected every contour tabbed for %+v, got %d untabbed", test.options, untabbed)
+		}
+	}
+
+	// no room for a tab between the corners
+	_, marks, untabbed, err := Tabs(square, TabOptions{Width: .5, Count: 2, CornerClearance: 5}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if untabbed != 1 || !IsEmptyPath(marks) {
+		t.Errorf("Expected the square untabbed, got %d untabbed: %s", untabbed, SvgString(marks, 3))
+	}
+
+	if _, _, _, err := Tabs(square, TabOptions{Width: .5}, 3); err == nil {
+		t.Errorf("Expected an error without a count or spacing")
+	}
+}
+
+func TestTabsPlacement(t *testing.T) {
+	// holes are not tabbed
+	d := NewDraw()
+	d.MoveTo(NewPoint(0, 0))
+	d.Rect(20, 20)
+	d.MoveTo(NewPoint(5, 5))
+	d.Rect(10, 10)
+	cut, marks, _, err := Tabs(d.Path(), TabOptions{Width: .5, Count: 2}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if l := PathLength(cut); math.Abs(l-119) > .001 {
+		t.Errorf("Expected cut length 119, got %f: %s", l, SvgString(cut, 3))
+	}
+	for _, seg := range TrimMove(marks.Segments()) {
+		if !IsMove(seg) && seg.Start().X > 0 && seg.Start().X < 20 && seg.Start().Y > 0 && seg.Start().Y < 20 {
+			t.Errorf("Expected the tabs on the outside, got %s", seg.Start().StringRounded())
+		}
+	}
+
+	// a D shape, the tabs stay on the line and away from the corners
+	d = NewDraw()
+	d.MoveTo(NewPoint(0, 0))
+	d.LineTo(NewPoint(0, 20))
+	d.CurveTo(NewPoint(8, 20), NewPoint(8, 0), NewPoint(0, 0))
+	cut, marks, _, err = Tabs(d.Path(), TabOptions{Width: .5, Count: 2, CornerClearance: 2}, 3)
+	if err != nil {
+		t.Fatalf("Error %s", err.Error())
+	}
+	if c := len(SplitPathOnMove(marks)); c != 2 {
+		t.Errorf("Expected 2 tabs, got %d: %s", c, SvgString(marks, 3))
+	}
+	for _, seg := range marks.Segments() {
+		if IsMove(seg) {
+			continue
+		}
+		for _, p := range []Point{seg.Start(), seg.End()} {
+			if math.Abs(p.X) > .001 || p.Y < 2-.001 || p.Y > 18+.001 {
+				t.Errorf("Expected the tab on the line away from the corners, got %s", p.StringRounded())
+			}
+		}
+	}
+	if _, curves, _ := countSegments(cut); curves != 1 {
+		t.Errorf("Expected the curve to be cut as is: %s", SvgString(cut, 3))
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"sort"
+
+	"github.com/dustismo/heavyfishdesign/bezier"
 )
 
 const MaxInt = int(^uint(0) >> 1)
@@ -445,11 +447,42 @@ func ShiftPath(deltaX float64, deltaY float64, path Path, so SegmentOperators) (
 	}
 	newPath := []Segment{}
 	for _, seg := range path.Segments() {
-		s, err := so.TransformPoints(seg, pt)
+		s, err := so.TransformSegment(seg, pt)
 		if err != nil {
 			return nil, err
 		}
-		newPath = append(newPath, s)
+		newPath = append(newPath, s...)
 	}
 	return NewPathFromSegments(newPath), nil
 }
+
+// the length of the segment, curves are measured along the curve.
+// moves have no length
+func SegmentLength(seg Segment) float64 {
+	if IsMove(seg) {
+		return 0
+	}
+	if a, ok := seg.(ArcSegment); ok {
+		if a.IsCircle() {
+			return a.RadiusX * math.Abs(a.Sweep)
+		}
+		length := 0.0
+		for _, c := range a.ToCurves() {
+			length += bezier.Length(bCC(c))
+		}
+		return length
+	}
+	if c, ok := seg.(Curve); ok {
+		return bezier.Length(bCC(c))
+	}
+	return Distance(seg.Start(), seg.End())
+}
+
+// the length of the path, not including moves
+func PathLength(p Path) float64 {
+	length := 0.0
+	for _, seg := range p.Segments() {
+		length += SegmentLength(seg)
+	}
+	return length
+}
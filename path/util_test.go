@@ -1,6 +1,7 @@
 package path
 
 import (
+	"math"
 	"testing"
 )
 
@@ -158,3 +159,14 @@ func TestLineIntersection(t *testing.T) {
 		t.Errorf("Expected: %s\nActual: %s", expected, actual)
 	}
 }
+
+func TestPathLength(t *testing.T) {
+	p, err := ParsePathFromSvg("M 0 0 L 3 4 M 10 10 C 10 10 20 20 20 20")
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	expected := 5 + 10*math.Sqrt2
+	if math.Abs(PathLength(p)-expected) > 1e-9 {
+		t.Errorf("Expected %f got %f", expected, PathLength(p))
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dustismo/heavyfishdesign/dom"
+	"github.com/dustismo/heavyfishdesign/dynmap"
+	"github.com/dustismo/heavyfishdesign/path"
+	"github.com/dustismo/heavyfishdesign/util"
+)
+
+// The browser preview app for the serve command.
+// The page lists the designs, builds a form from the document params and
+// re-renders every page of the plan set as the params are edited.  The
+// page listens on /events and re-renders when any file in the design
+// import graph changes on disk.
+type previewServer struct {
+	// directory to list the designs from
+	renderDir string
+}
+
+func (s *previewServer) register(mux *http.ServeMux) {
+	mux.Handle("/", http.HandlerFunc(s.page))
+	mux.Handle("/designs", http.HandlerFunc(s.designs))
+	mux.Handle("/params", http.HandlerFunc(s.params))
+	mux.Handle("/render", http.HandlerFunc(s.render))
+	mux.Handle("/events", http.HandlerFunc(s.events))
+}
+
+func writeJSON(w http.ResponseWriter, dm *dynmap.DynMap) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, dm.ToJSON())
+}
+
+func (s *previewServer) page(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprint(w, previewPage)
+}
+
+// lists the design files
+func (s *previewServer) designs(w http.ResponseWriter, req *http.Request) {
+	filenames, err := util.FileList(s.renderDir, FileExtension)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := dynmap.New()
+	resp.Put("designs", filenames)
+	writeJSON(w, resp)
+}
+
+// the document params, with their descriptions and data types
+func (s *previewServer) params(w http.ResponseWriter, req *http.Request) {
+	filename := req.URL.Query().Get("file")
+	doc, err := getDocument(filename, dynmap.New(), util.NewLog())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := dynmap.New()
+	resp.Put("params", doc.ParamInfos())
+	writeJSON(w, resp)
+}
+
+// renders every document in the plan set as svg.  Render errors are
+// returned in the response so the page can display them
+func (s *previewServer) render(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := dynmap.New()
+	params.UnmarshalUrlValues(req.Form)
+	filename := params.MustString("file", "")
+	params.Remove("file")
+
+	logger := util.NewLog()
+	logger.LogToStdOut = util.Fatal
+	documents := []string{}
+	var estimate *dynmap.DynMap
+	planset, err := renderPlanSet(filename, params, logger)
+	if err != nil {
+		logger.Errorf("Error during render: %s", err.Error())
+	} else {
+		context := dom.RenderContext{
+			Origin: path.NewPoint(0, 0),
+			Cursor: path.NewPoint(0, 0),
+			Log:    logger,
+		}
+		for _, svgDoc := range planset.SVGDocuments() {
+			buf := &bytes.Buffer{}
+			svgDoc.ShowKeepOuts = true
+			svgDoc.WriteSVG(context, buf)
+			documents = append(documents, buf.String())
+		}
+		e, err := planset.Estimate()
+		if err != nil {
+			logger.Errorf("Error during estimate: %s", err.Error())
+		} else {
+			estimate = e.ToDynMap()
+		}
+	}
+
+	errors := []string{}
+	for _, msg := range logger.Messages {
+		if msg.Level == util.Error {
+			errors = append(errors, strings.TrimSpace(msg.Message))
+		}
+	}
+	resp := dynmap.New()
+	resp.Put("documents", documents)
+	resp.Put("errors", errors)
+	if estimate != nil {
+		resp.Put("estimate", estimate)
+	}
+	writeJSON(w, resp)
+}
+
+// server sent events, sends a change event whenever any file
+// in the import graph of the design changes
+func (s *previewServer) events(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	params := dynmap.New()
+	params.UnmarshalUrlValues(req.URL.Query())
+	filename := params.MustString("file", "")
+	params.Remove("file")
+	watcher := util.NewFileWatcher()
+	watchImports := func() {
+		files, err := dom.ImportGraph(filename, params)
+		if err != nil {
+			files = []string{filename}
+		}
+		watcher.Add(files...)
+	}
+	watchImports()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+			changed := watcher.Changed()
+			if len(changed) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", strings.Join(changed, ","))
+			flusher.Flush()
+			// imports may have changed
+			watchImports()
+		}
+	}
+}
+
+const previewPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>heavyfishdesign preview</title>
+<style>
+	body { font-family: sans-serif; margin: 0; display: flex; height: 100vh; }
+	#controls { width: 300px; padding: 10px; overflow-y: auto; border-right: 1px solid #ccc; }
+	#controls label { display: block; margin-top: 8px; font-weight: bold; }
+	#controls .description { font-size: small; color: #666; }
+	#controls input[type=text], #controls input[type=number], select { width: 100%; box-sizing: border-box; }
+	#preview { flex: 1; padding: 10px; overflow-y: auto; background: #eee; }
+	#errors { color: #c00; white-space: pre-wrap; }
+	#estimate { font-size: small; margin-top: 10px; }
+	.page { background: white; margin-bottom: 10px; border: 1px solid #999; }
+	.page svg { width: 100%; height: auto; display: block; }
+</style>
+</head>
+<body>
+<div id="controls">
+	<select id="designs"></select>
+	<div id="params"></div>
+	<div id="estimate"></div>
+</div>
+<div id="preview">
+	<div id="errors"></div>
+	<div id="pages"></div>
+</div>
+<script>
+var file = new URLSearchParams(location.search).get("file");
+// params edited on the page, these survive reloads of the design
+var overrides = {};
+var events = null;
+var timer = null;
+
+function get(url) {
+	return fetch(url).then(function(r) {
+		if (!r.ok) {
+			return r.text().then(function(t) { throw new Error(t); });
+		}
+		return r.json();
+	});
+}
+
+function inputType(p) {
+	var t = (p.data_type || "").toLowerCase();
+	if (["float", "float64", "int", "number"].indexOf(t) >= 0) {
+		return "number";
+	}
+	if (["bool", "boolean"].indexOf(t) >= 0) {
+		return "checkbox";
+	}
+	if (typeof p.value === "number") {
+		return "number";
+	}
+	if (typeof p.value === "boolean") {
+		return "checkbox";
+	}
+	return "text";
+}
+
+function control(p) {
+	var div = document.createElement("div");
+	var label = document.createElement("label");
+	label.textContent = p.name;
+	div.appendChild(label);
+	var input = document.createElement("input");
+	input.type = inputType(p);
+	if (input.type === "number") {
+		input.step = "any";
+	}
+	var value = p.name in overrides ? overrides[p.name] : p.value;
+	if (input.type === "checkbox") {
+		input.checked = value === true || value === "true";
+	} else {
+		input.value = typeof value === "object" ? JSON.stringify(value) : value;
+	}
+	input.oninput = function() {
+		overrides[p.name] = input.type === "checkbox" ? input.checked : input.value;
+		scheduleRender();
+	};
+	div.appendChild(input);
+	if (p.description) {
+		var desc = document.createElement("div");
+		desc.className = "description";
+		desc.textContent = p.description;
+		div.appendChild(desc);
+	}
+	return div;
+}
+
+function loadParams() {
+	return get("params?file=" + encodeURIComponent(file)).then(function(resp) {
+		var params = document.getElementById("params");
+		params.innerHTML = "";
+		(resp.params || []).forEach(function(p) {
+			params.appendChild(control(p));
+		});
+	});
+}
+
+function scheduleRender() {
+	clearTimeout(timer);
+	timer = setTimeout(function() {
+		// the params can change the files the design uses
+		watch();
+		render().catch(showError);
+	}, 200);
+}
+
+// the design and the edited params
+function query() {
+	var q = new URLSearchParams();
+	q.set("file", file);
+	Object.keys(overrides).forEach(function(k) {
+		q.set(k, overrides[k]);
+	});
+	return q.toString();
+}
+
+function render() {
+	return get("render?" + query()).then(function(resp) {
+		document.getElementById("errors").textContent = (resp.errors || []).join("\n");
+		document.getElementById("pages").innerHTML = (resp.documents || []).map(function(svg) {
+			return "<div class=\"page\">" + svg + "</div>";
+		}).join("");
+		showEstimate(resp.estimate);
+	});
+}
+
+function duration(seconds) {
+	var s = Math.round(seconds);
+	var m = Math.floor(s / 60) % 60;
+	return Math.floor(s / 3600) + ":" + (m < 10 ? "0" : "") + m + ":" + (s % 60 < 10 ? "0" : "") + (s % 60);
+}
+
+function showEstimate(e) {
+	var div = document.getElementById("estimate");
+	if (!e) {
+		div.textContent = "";
+		return;
+	}
+	var t = e.total;
+	div.innerHTML = "<b>estimate (" + e.machine.name + ")</b><br>" +
+		t.sheets + " sheets, " + t.pierces + " pierces<br>" +
+		"time " + duration(t.time) + " (travel " + duration(t.travel_time) + ")<br>" +
+		"cost " + t.cost.toFixed(2) + " (material " + t.material_cost.toFixed(2) + ", machine " + t.machine_cost.toFixed(2) + ")";
+}
+
+function showError(err) {
+	document.getElementById("errors").textContent = err.message;
+}
+
+function watch() {
+	if (events) {
+		events.close();
+	}
+	events = new EventSource("events?" + query());
+	events.addEventListener("change", function() {
+		loadParams().then(render).catch(showError);
+	});
+}
+
+function selectDesign(f) {
+	file = f;
+	overrides = {};
+	history.replaceState(null, "", "?file=" + encodeURIComponent(file));
+	loadParams().then(render).catch(showError);
+	watch();
+}
+
+get("designs").then(function(resp) {
+	var select = document.getElementById("designs");
+	var designs = resp.designs || [];
+	if (file && designs.indexOf(file) < 0) {
+		designs.unshift(file);
+	}
+	designs.forEach(function(d) {
+		var option = document.createElement("option");
+		option.value = d;
+		option.textContent = d;
+		select.appendChild(option);
+	});
+	select.onchange = function() {
+		selectDesign(select.value);
+	};
+	if (!file) {
+		file = designs[0];
+	}
+	select.value = file;
+	selectDesign(file);
+}).catch(showError);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,25 @@
+package transforms
+
+import (
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// Combines the path with each of the operands in turn, all the paths
+// must be closed.  For subtract each operand is removed from the path.
+type BooleanTransform struct {
+	Operation        path.BooleanOperation
+	Operands         []path.Path
+	Precision        int
+	SegmentOperators path.SegmentOperators
+}
+
+func (bt BooleanTransform) PathTransform(p path.Path) (path.Path, error) {
+	var err error
+	for _, operand := range bt.Operands {
+		p, err = path.Boolean(bt.Operation, p, operand, bt.SegmentOperators, bt.Precision)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,17 @@
+package transforms
+
+import (
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// Adds dogbone or T-bone relief to the inside corners of closed paths,
+// so a round tool of the given diameter can cut all the way into them
+type DogboneTransform struct {
+	Diameter  float64
+	Style     path.DogboneStyle
+	Precision int
+}
+
+func (dt DogboneTransform) PathTransform(p path.Path) (path.Path, error) {
+	return path.Dogbone(p, dt.Style, dt.Diameter, dt.Precision)
+}
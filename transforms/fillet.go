@@ -0,0 +1,28 @@
+package transforms
+
+import (
+	"github.com/dustismo/heavyfishdesign/path"
+)
+
+// Rounds the corners of the path with arcs of the given radius, or cuts
+// them off at the given distance from the corner if Chamfer is set
+type FilletTransform struct {
+	Radius  float64
+	Chamfer bool
+	Corners path.CornerSelection
+	// limits on how sharply the path turns at the corner, in degrees.
+	// A MaxAngle of 0 is no maximum
+	MinAngle  float64
+	MaxAngle  float64
+	Precision int
+}
+
+func (ft FilletTransform) PathTransform(p path.Path) (path.Path, error) {
+	return path.FilletCorners(p, path.CornerOptions{
+		Radius:   ft.Radius,
+		Chamfer:  ft.Chamfer,
+		Corners:  ft.Corners,
+		MinAngle: ft.MinAngle,
+		MaxAngle: ft.MaxAngle,
+	}, ft.Precision)
+}
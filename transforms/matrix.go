@@ -16,15 +16,10 @@ func (mt MatrixTransform) TransformPoint(p path.Point) path.Point {
 }
 
 func (mt MatrixTransform) PathTransform(p path.Path) (path.Path, error) {
-	segments := []path.Segment{}
-
-	for _, seg := range p.Segments() {
-		s, err := mt.SegmentOperators.TransformPoints(seg, mt.TransformPoint)
-		if err != nil {
-			return nil, err
-		}
-		segments = append(segments, s)
-	}
+	return transformPathPoints(p, mt.TransformPoint, mt.SegmentOperators)
+}
 
-	return path.NewPathFromSegments(segments), nil
+// the matrix does not measure anything, so this is the same as PathTransform
+func (mt MatrixTransform) PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error) {
+	return mt.PathTransform(p)
 }
@@ -19,27 +19,34 @@ type MirrorTransform struct {
 }
 
 func (mt MirrorTransform) PathTransform(p path.Path) (path.Path, error) {
-	segments := []path.Segment{}
+	return mt.PathTransformWithReference(p, p)
+}
+
+// mirrors p across the reference path
+func (mt MirrorTransform) PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error) {
 	if len(mt.Handle) == 0 {
 		// handle should be TOP_LEFT by default..
 		mt.Handle = path.TopLeft
 	}
-	axisPoint, err := path.PointPathAttribute(mt.Handle, p, mt.SegmentOperators)
+	axisPoint, err := path.PointPathAttribute(mt.Handle, reference, mt.SegmentOperators)
 	if err != nil {
 		return p, err
 	}
 	// first move to 0,0 then mirror then move back.
-
-	p, err = MoveTransform{
-		Point:            path.NewPoint(0, 0),
-		Handle:           mt.Handle,
+	move := ShiftTransform{
+		DeltaX:           -axisPoint.X,
+		DeltaY:           -axisPoint.Y,
 		SegmentOperators: mt.SegmentOperators,
-	}.PathTransform(p)
-
+	}
+	p, err = move.PathTransform(p)
 	if err != nil {
 		return p, err
 	}
-	_, br, err := path.BoundingBoxWithWhitespace(p, mt.SegmentOperators)
+	reference, err = move.PathTransform(reference)
+	if err != nil {
+		return p, err
+	}
+	_, br, err := path.BoundingBoxWithWhitespace(reference, mt.SegmentOperators)
 	if err != nil {
 		return p, err
 	}
@@ -56,16 +63,11 @@ func (mt MirrorTransform) PathTransform(p path.Path) (path.Path, error) {
 		}
 		return newPoint
 	}
-	for _, seg := range p.Segments() {
-		s, err := mt.SegmentOperators.TransformPoints(seg, pt)
-		if err != nil {
-			return nil, err
-		}
-		segments = append(segments, s)
+	pth, err := transformPathPoints(p, pt, mt.SegmentOperators)
+	if err != nil {
+		return nil, err
 	}
 
-	pth := path.NewPathFromSegments(segments)
-
 	// now move back to the original origin
 	pth, err = ShiftTransform{
 		DeltaX:           axisPoint.X,
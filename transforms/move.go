@@ -14,11 +14,16 @@ type MoveTransform struct {
 }
 
 func (mt MoveTransform) PathTransform(p path.Path) (path.Path, error) {
+	return mt.PathTransformWithReference(p, p)
+}
+
+// moves p by the amount that moves the handle of the reference path
+func (mt MoveTransform) PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error) {
 	if len(mt.Handle) == 0 {
 		// handle should be TOP_LEFT by default..
 		mt.Handle = path.TopLeft
 	}
-	handle, err := path.PointPathAttribute(mt.Handle, p, mt.SegmentOperators)
+	handle, err := path.PointPathAttribute(mt.Handle, reference, mt.SegmentOperators)
 	if err != nil {
 		return p, err
 	}
@@ -0,0 +1,26 @@
+package transforms
+
+import "github.com/dustismo/heavyfishdesign/path"
+
+// A transform that only moves the points of the path (move, rotate, scale,
+// mirror..), it does not add or remove any segments.  Anything it measures
+// (the bounding box, a handle) can be measured on a different path, so
+// several paths can be transformed the same way and stay lined up.
+type PointPathTransform interface {
+	path.PathTransform
+	// transforms p, measuring the reference path
+	PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error)
+}
+
+// applies the point transform to every segment of the path
+func transformPathPoints(p path.Path, pt path.PointTransform, so path.SegmentOperators) (path.Path, error) {
+	segments := []path.Segment{}
+	for _, seg := range p.Segments() {
+		s, err := so.TransformSegment(seg, pt)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, s...)
+	}
+	return path.NewPathFromSegments(segments), nil
+}
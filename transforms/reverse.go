@@ -35,6 +35,11 @@ func (s SegmentReverse) SegmentTransform(segment path.Segment) path.Segment {
 			ControlPointStart: seg.ControlPointEnd,
 			ControlPointEnd:   seg.ControlPointStart,
 		}
+	case path.ArcSegment:
+		seg.StartPoint, seg.EndPoint = seg.EndPoint, seg.StartPoint
+		seg.StartAngle = seg.EndAngle()
+		seg.Sweep = -seg.Sweep
+		return seg
 	}
 	return segment
 }
@@ -12,12 +12,11 @@ type RotateTransform struct {
 
 func (rt RotateTransform) PathTransformWithAxis(pth path.Path, axisPoint path.Point) (path.Path, error) {
 
-	segments := []path.Segment{}
 	// first move to 0,0 then rotate then move back.
 
-	p, err := MoveTransform{
-		Point:            path.NewPoint(0, 0),
-		Handle:           rt.Axis,
+	p, err := ShiftTransform{
+		DeltaX:           -axisPoint.X,
+		DeltaY:           -axisPoint.Y,
 		SegmentOperators: rt.SegmentOperators,
 	}.PathTransform(pth)
 
@@ -27,15 +26,7 @@ func (rt RotateTransform) PathTransformWithAxis(pth path.Path, axisPoint path.Po
 	pt := func(point path.Point) path.Point {
 		return path.Rotate(rt.Degrees, point)
 	}
-	for _, seg := range p.Segments() {
-		s, err := rt.SegmentOperators.TransformPoints(seg, pt)
-		if err != nil {
-			return nil, err
-		}
-		segments = append(segments, s)
-	}
-
-	pth, err = path.NewPathFromSegments(segments), nil
+	pth, err = transformPathPoints(p, pt, rt.SegmentOperators)
 	if err != nil {
 		return pth, err
 	}
@@ -51,11 +42,16 @@ func (rt RotateTransform) PathTransformWithAxis(pth path.Path, axisPoint path.Po
 }
 
 func (rt RotateTransform) PathTransform(p path.Path) (path.Path, error) {
+	return rt.PathTransformWithReference(p, p)
+}
+
+// rotates p around the axis of the reference path
+func (rt RotateTransform) PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error) {
 	if len(rt.Axis) == 0 {
 		// handle should be TOP_LEFT by default..
 		rt.Axis = path.TopLeft
 	}
-	axisPoint, err := path.PointPathAttribute(rt.Axis, p, rt.SegmentOperators)
+	axisPoint, err := path.PointPathAttribute(rt.Axis, reference, rt.SegmentOperators)
 	if err != nil {
 		return p, err
 	}
@@ -20,13 +20,17 @@ type ScaleTransform struct {
 }
 
 func (st ScaleTransform) PathTransform(p path.Path) (path.Path, error) {
+	return st.PathTransformWithReference(p, p)
+}
 
+// scales p by the amount that scales the reference path
+func (st ScaleTransform) PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error) {
 	var xScale = st.ScaleX
 	var yScale = st.ScaleY
 	if st.Width > 0 || st.Height > 0 {
 		// Fit path bbox to target width and/or height. If only one dimension is set, the other
 		// axis uses the same scale factor (uniform); non-uniform scaling requires both set.
-		tl, br, err := path.BoundingBoxTrimWhitespace(p, st.SegmentOperators)
+		tl, br, err := path.BoundingBoxTrimWhitespace(reference, st.SegmentOperators)
 		if err != nil {
 			return p, err
 		}
@@ -48,7 +52,7 @@ func (st ScaleTransform) PathTransform(p path.Path) (path.Path, error) {
 		newX := math.Abs(st.EndPoint.X - st.StartPoint.X)
 		newY := math.Abs(st.EndPoint.Y - st.StartPoint.Y)
 
-		s, e := path.GetStartAndEnd(p.Segments())
+		s, e := path.GetStartAndEnd(reference.Segments())
 		oldX := math.Abs(e.X - s.X)
 		oldY := math.Abs(e.Y - s.Y)
 
@@ -65,20 +69,15 @@ func (st ScaleTransform) PathTransform(p path.Path) (path.Path, error) {
 		}
 	}
 
-	segs := []path.Segment{}
 	// function to do the scaling
 	pt := func(p path.Point) path.Point {
 		x := p.X * xScale
 		y := p.Y * yScale
 		return path.NewPoint(x, y)
 	}
-
-	for _, s := range p.Segments() {
-		seg, err := st.SegmentOperators.TransformPoints(s, pt)
-		if err != nil {
-			return p, err
-		}
-		segs = append(segs, seg)
+	pth, err := transformPathPoints(p, pt, st.SegmentOperators)
+	if err != nil {
+		return p, err
 	}
-	return path.NewPathFromSegments(segs), nil
+	return pth, nil
 }
@@ -13,13 +13,10 @@ func (st ShiftTransform) PathTransform(p path.Path) (path.Path, error) {
 	pt := func(p path.Point) path.Point {
 		return path.NewPoint(p.X+st.DeltaX, p.Y+st.DeltaY)
 	}
-	newPath := []path.Segment{}
-	for _, seg := range p.Segments() {
-		s, err := st.SegmentOperators.TransformPoints(seg, pt)
-		if err != nil {
-			return nil, err
-		}
-		newPath = append(newPath, s)
-	}
-	return path.NewPathFromSegments(newPath), nil
+	return transformPathPoints(p, pt, st.SegmentOperators)
+}
+
+// the shift does not measure anything, so this is the same as PathTransform
+func (st ShiftTransform) PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error) {
+	return st.PathTransform(p)
 }
@@ -10,7 +10,12 @@ type TrimWhitespaceTransform struct {
 // Note that you should typically call simplify before triming whitespace to avoid
 // things like M 0 0, M 10, 11
 func (tw TrimWhitespaceTransform) PathTransform(p path.Path) (path.Path, error) {
-	tl, _, err := path.BoundingBoxTrimWhitespace(p, tw.SegmentOperators)
+	return tw.PathTransformWithReference(p, p)
+}
+
+// moves p by the amount that trims the whitespace of the reference path
+func (tw TrimWhitespaceTransform) PathTransformWithReference(p path.Path, reference path.Path) (path.Path, error) {
+	tl, _, err := path.BoundingBoxTrimWhitespace(reference, tw.SegmentOperators)
 	if err != nil {
 		return p, err
 	}
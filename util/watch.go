@@ -0,0 +1,52 @@
+package util
+
+import (
+	"os"
+	"time"
+)
+
+// Watches a set of files for changes by polling the modification time.
+// Polling avoids depending on a platform specific notification library,
+// and design files are small enough that it is cheap.
+type FileWatcher struct {
+	modTimes map[string]time.Time
+}
+
+func NewFileWatcher() *FileWatcher {
+	return &FileWatcher{
+		modTimes: map[string]time.Time{},
+	}
+}
+
+func modTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		// missing files have the zero time, so they show up as
+		// changed when they are created
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// starts watching the files, files that are already watched are unchanged
+func (w *FileWatcher) Add(filenames ...string) {
+	for _, fn := range filenames {
+		if _, ok := w.modTimes[fn]; !ok {
+			w.modTimes[fn] = modTime(fn)
+		}
+	}
+}
+
+// returns the files that have been modified, created or removed since
+// they were added or since the last call to Changed
+func (w *FileWatcher) Changed() []string {
+	changed := []string{}
+	for fn, t := range w.modTimes {
+		mt := modTime(fn)
+		if !mt.Equal(t) {
+			w.modTimes[fn] = mt
+			changed = append(changed, fn)
+		}
+	}
+	return changed
+}